@@ -3,68 +3,150 @@ package cache
 import (
 	"context"
 	"log"
+	"math/rand/v2"
+	"sync"
 	"time"
 
 	"github.com/karlseguin/ccache"
 )
 
-// MemoryCache is a CCache implementation.
-type MemoryCache struct {
-	name          string
-	cCache        *ccache.Cache
-	defaultTTL    time.Duration
-	returnExpired bool
+// noExpiration is the ttl of the values saved without expiration, ccache needs one
+const noExpiration = 100 * 365 * 24 * time.Hour
+
+// MemoryCache is a CCache implementation of Typed.
+type MemoryCache[V any] struct {
+	name       string
+	cCache     *ccache.Cache
+	defaultTTL time.Duration
+	refresh    *RefreshConfig[V]
+	random     func() float64
+
+	// mu serializes the writes and guards refreshing
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+// entry is a value stored in the ccache with what the refreshes need to know of it
+type entry[V any] struct {
+	value V
+	ttl   time.Duration
+	// loadTime is the duration of the refresh that loaded the value, 0 if it was saved
+	loadTime time.Duration
 }
 
-// NewMemoryCache creates a new MemoryCache instance.
+// NewMemoryCache creates a new MemoryCache instance behind the untyped Spec.
 // name: name of the cache
 // size: max number of items in the cache
-// ttl: default ttl for items in the cache (in seconds)
+// ttl: default ttl for items in the cache, 0 stores them without expiration
 // returnExpired: if true, expired items will be returned. if false nil will be returned
 func NewMemoryCache(name string, size int, ttl time.Duration, returnExpired bool) Spec {
-	return &MemoryCache{
-		name:          name,
-		defaultTTL:    ttl,
-		cCache:        ccache.New(ccache.Configure().MaxSize(int64(size)).ItemsToPrune(100)),
-		returnExpired: returnExpired,
+	return newSpecAdapter[interface{}](NewTypedMemoryCache[interface{}](name, size, ttl), returnExpired)
+}
+
+// NewTypedMemoryCache creates a new MemoryCache of values of type V.
+// name: name of the cache
+// size: max number of items in the cache
+// ttl: default ttl for items in the cache, 0 stores them without expiration
+// Example:
+//
+//	users := cache.NewTypedMemoryCache[*User]("users", 1000, time.Minute)
+//	user, found, _ := users.Get(ctx, "user-1")
+func NewTypedMemoryCache[V any](name string, size int, ttl time.Duration) *MemoryCache[V] {
+	return &MemoryCache[V]{
+		name:       name,
+		defaultTTL: ttl,
+		cCache:     ccache.New(ccache.Configure().MaxSize(int64(size)).ItemsToPrune(100)),
+		random:     rand.Float64,
+		refreshing: make(map[string]bool),
+	}
+}
+
+// Get returns the value of key, found is false if the key is not in the cache or it expired. It never fails.
+// With WithRefresh, a value close to its expiration is returned and refreshed in the background
+func (impl *MemoryCache[V]) Get(ctx context.Context, key string) (value V, found bool, err error) {
+	item, e := impl.lookup(ctx, key)
+	if item == nil || item.Expired() {
+		return value, false, nil
+	}
+	if impl.refresh != nil && impl.shouldRefresh(e, item.Expires()) {
+		impl.startRefresh(key, item)
 	}
+	return e.value, true, nil
 }
 
-func (impl *MemoryCache) Get(ctx context.Context, key string) (expired bool, value interface{}) {
+// GetStale returns the value of key even if it expired, expired tells if it did
+func (impl *MemoryCache[V]) GetStale(ctx context.Context, key string) (value V, expired bool, found bool) {
+	item, e := impl.lookup(ctx, key)
+	if item == nil {
+		return value, false, false
+	}
+	return e.value, item.Expired(), true
+}
+
+func (impl *MemoryCache[V]) lookup(ctx context.Context, key string) (*ccache.Item, entry[V]) {
 	if ctx != nil {
 		log.Printf("cache.%s.Get: key=%s", impl.name, key)
 	}
 	item := impl.cCache.Get(key)
-	if item != nil && !item.Expired() {
-		res := item.Value()
-		return false, res
-	}
-	if impl.returnExpired && item != nil {
-		res := item.Value()
-		return item.Expired(), res
+	if item == nil {
+		return nil, entry[V]{}
 	}
-
-	return false, nil
+	e, _ := item.Value().(entry[V])
+	return item, e
 }
 
-func (impl *MemoryCache) Save(ctx context.Context, key string, item interface{}) {
+func (impl *MemoryCache[V]) Save(ctx context.Context, key string, value V) error {
 	if ctx != nil {
 		log.Printf("cache.%s.Save: key=%s", impl.name, key)
 	}
-	impl.cCache.Set(key, item, impl.defaultTTL)
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	impl.set(key, entry[V]{value: value, ttl: impl.defaultTTL})
+	return nil
 }
 
-func (impl *MemoryCache) SaveWithTTL(ctx context.Context, key string, item interface{}, ttl time.Duration) {
+// SaveWithTTL saves the value with a custom ttl, 0 stores it without expiration
+func (impl *MemoryCache[V]) SaveWithTTL(ctx context.Context, key string, value V, ttl time.Duration) error {
 	if ctx != nil {
 		log.Printf("cache.%s.SaveWithTTL: key=%s, ttl=%v", impl.name, key, ttl)
 	}
-	impl.cCache.Set(key, item, ttl)
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	impl.set(key, entry[V]{value: value, ttl: ttl})
+	return nil
+}
+
+// SaveIfAbsent saves the value with a custom ttl only if key is not in the cache or it expired, saved is false
+// if it was. It never fails
+func (impl *MemoryCache[V]) SaveIfAbsent(ctx context.Context, key string, value V, ttl time.Duration) (saved bool, err error) {
+	if ctx != nil {
+		log.Printf("cache.%s.SaveIfAbsent: key=%s, ttl=%v", impl.name, key, ttl)
+	}
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
+	if item := impl.cCache.Get(key); item != nil && !item.Expired() {
+		return false, nil
+	}
+	impl.set(key, entry[V]{value: value, ttl: ttl})
+	return true, nil
+}
+
+// set stores the entry until its ttl elapses, forever if it is not positive like in RedisCache.
+// impl.mu must be held, so a refresh does not overwrite a concurrent write
+func (impl *MemoryCache[V]) set(key string, e entry[V]) {
+	if e.ttl <= 0 {
+		e.ttl = noExpiration
+	}
+	impl.cCache.Set(key, e, e.ttl)
 }
 
 // Delete key value pair from the cache
-func (impl *MemoryCache) Delete(ctx context.Context, key string) {
+func (impl *MemoryCache[V]) Delete(ctx context.Context, key string) error {
 	if ctx != nil {
 		log.Printf("cache.%s.Delete: key=%s", impl.name, key)
 	}
+	impl.mu.Lock()
+	defer impl.mu.Unlock()
 	impl.cCache.Delete(key)
+	return nil
 }
@@ -0,0 +1,257 @@
+// Package cachetest provides an in-process stand-in for a Redis server, so the code using cache.RedisCache
+// can be tested without a real server.
+package cachetest
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// RedisServer speaks RESP and supports the commands used by cache.RedisCache: AUTH, SELECT, PING, GET, MGET,
+// SET with PX, EX and NX, and DEL. It is closed when the test ends.
+// Example:
+//
+//	server := cachetest.NewRedisServer(t, "")
+//	users := cache.NewTypedRedisCache[*User]("users", time.Minute, cache.RedisConfig{Addr: server.Addr()})
+type RedisServer struct {
+	ln       net.Listener
+	password string
+
+	mu      sync.Mutex
+	data    map[string]item
+	conns   []net.Conn
+	dials   int
+	batches [][]string
+}
+
+type item struct {
+	value   string
+	expires time.Time
+}
+
+// NewRedisServer starts a new RedisServer. With a password, the connections must AUTH before any other command
+func NewRedisServer(t *testing.T, password string) *RedisServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("cachetest: %v", err)
+	}
+	s := &RedisServer{ln: ln, password: password, data: make(map[string]item)}
+	go s.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		s.DropConns()
+	})
+	return s
+}
+
+// Addr returns the host:port of the server
+func (s *RedisServer) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Get returns the raw value of key and its remaining ttl, 0 if it never expires
+func (s *RedisServer) Get(key string) (value string, ttl time.Duration, found bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	it, ok := s.lookup(key)
+	if !ok {
+		return "", 0, false
+	}
+	if !it.expires.IsZero() {
+		ttl = time.Until(it.expires)
+	}
+	return it.value, ttl, true
+}
+
+// Dials returns the number of connections accepted by the server
+func (s *RedisServer) Dials() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dials
+}
+
+// Batches returns the names of the commands received, grouped by the pipelines they were sent in
+func (s *RedisServer) Batches() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.batches...)
+}
+
+// DropConns closes the open connections, like a server restart
+func (s *RedisServer) DropConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+func (s *RedisServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.dials++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *RedisServer) handle(conn net.Conn) {
+	r, w := bufio.NewReader(conn), bufio.NewWriter(conn)
+	authenticated := s.password == ""
+	var batch []string
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		batch = append(batch, name)
+		switch {
+		case name == "AUTH":
+			authenticated = args[len(args)-1] == s.password
+			if authenticated {
+				w.WriteString("+OK\r\n")
+			} else {
+				w.WriteString("-WRONGPASS invalid username-password pair\r\n")
+			}
+		case !authenticated:
+			w.WriteString("-NOAUTH Authentication required.\r\n")
+		default:
+			s.exec(w, name, args[1:])
+		}
+		// the replies of a pipeline are flushed once all its commands are read
+		if r.Buffered() == 0 {
+			s.mu.Lock()
+			s.batches = append(s.batches, batch)
+			s.mu.Unlock()
+			batch = nil
+			w.Flush()
+		}
+	}
+}
+
+func (s *RedisServer) exec(w *bufio.Writer, name string, args []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch name {
+	case "SELECT", "PING":
+		w.WriteString("+OK\r\n")
+	case "GET":
+		it, ok := s.lookup(args[0])
+		writeBulk(w, it.value, ok)
+	case "MGET":
+		w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+		for _, key := range args {
+			it, ok := s.lookup(key)
+			writeBulk(w, it.value, ok)
+		}
+	case "SET":
+		s.set(w, args)
+	case "DEL":
+		deleted := 0
+		for _, key := range args {
+			if _, ok := s.lookup(key); ok {
+				deleted++
+			}
+			delete(s.data, key)
+		}
+		w.WriteString(":" + strconv.Itoa(deleted) + "\r\n")
+	default:
+		w.WriteString("-ERR unknown command '" + name + "'\r\n")
+	}
+}
+
+func (s *RedisServer) set(w *bufio.Writer, args []string) {
+	if len(args) < 2 {
+		w.WriteString("-ERR wrong number of arguments for 'set' command\r\n")
+		return
+	}
+	it := item{value: args[1]}
+	nx := false
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "PX", "EX":
+			if i+1 == len(args) {
+				w.WriteString("-ERR syntax error\r\n")
+				return
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				w.WriteString("-ERR invalid expire time in 'set' command\r\n")
+				return
+			}
+			unit := time.Millisecond
+			if strings.ToUpper(args[i]) == "EX" {
+				unit = time.Second
+			}
+			it.expires = time.Now().Add(time.Duration(n) * unit)
+			i++
+		default:
+			w.WriteString("-ERR syntax error\r\n")
+			return
+		}
+	}
+	if _, exists := s.lookup(args[0]); nx && exists {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	s.data[args[0]] = it
+	w.WriteString("+OK\r\n")
+}
+
+// lookup returns the item of key if it did not expire. s.mu must be held
+func (s *RedisServer) lookup(key string) (item, bool) {
+	it, ok := s.data[key]
+	if !ok || (!it.expires.IsZero() && time.Now().After(it.expires)) {
+		return item{}, false
+	}
+	return it, true
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if line[0] != '*' || err != nil {
+		return nil, errors.New("expected an array")
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func writeBulk(w *bufio.Writer, value string, found bool) {
+	if !found {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(value)) + "\r\n" + value + "\r\n")
+}
@@ -0,0 +1,39 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Claim saves value under key with ttl if the key is not in the cache, so a single caller owns the key, like the lock
+// of a request. claimed is false if the key was in the cache, current is then its value read with As.
+// With an AtomicSpec the key is claimed with SaveIfAbsent, so the callers sharing a remote cache never claim the same
+// key. Other caches check and save the key under mu, that only serializes the callers of a process.
+// The errors of SaveIfAbsent are returned, the key is not claimed
+// Example:
+//
+//	current, claimed, err := cache.Claim(ctx, spec, &mu, "lock:"+id, &Lock{Owner: owner}, time.Minute)
+func Claim[T any](ctx context.Context, spec Spec, mu *sync.Mutex, key string, value T, ttl time.Duration) (current T, claimed bool, err error) {
+	if atomic, ok := spec.(AtomicSpec); ok {
+		saved, err := atomic.SaveIfAbsent(ctx, key, value, ttl)
+		if err != nil || saved {
+			return current, saved, err
+		}
+		_, found := spec.Get(ctx, key)
+		if current, ok := As[T](found); ok {
+			return current, false, nil
+		}
+		// the key was deleted after SaveIfAbsent, it was owned by another caller until then
+		return value, false, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	_, found := spec.Get(ctx, key)
+	if current, ok := As[T](found); ok {
+		return current, false, nil
+	}
+	spec.SaveWithTTL(ctx, key, value, ttl)
+	return current, true, nil
+}
@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type lock struct {
+	Owner int
+}
+
+// Test a single one of the concurrent callers claims a key, with and without an AtomicSpec
+func TestClaim(t *testing.T) {
+	specs := map[string]Spec{
+		"atomic": NewMemoryCache("locks", 10, time.Minute, false),
+		"mutex":  AsSpec[*lock](AsTyped[*lock](NewMemoryCache("locks", 10, time.Minute, false))),
+	}
+	_, isAtomic := specs["mutex"].(AtomicSpec)
+	assert.False(t, isAtomic)
+
+	for name, spec := range specs {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var mu sync.Mutex
+			var wins int32
+			var wg sync.WaitGroup
+			for i := 1; i <= 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					current, claimed, err := Claim(ctx, spec, &mu, "key", &lock{Owner: i}, time.Minute)
+					assert.Nil(t, err)
+					if claimed {
+						atomic.AddInt32(&wins, 1)
+					} else {
+						assert.NotNil(t, current)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), wins)
+
+			spec.Delete(ctx, "key")
+			_, claimed, _ := Claim(ctx, spec, &mu, "key", &lock{Owner: 99}, time.Minute)
+			assert.True(t, claimed)
+			current, claimed, _ := Claim(ctx, spec, &mu, "key", &lock{Owner: 100}, time.Minute)
+			assert.False(t, claimed)
+			assert.Equal(t, 99, current.Owner)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// failuresSize is the max number of loader errors remembered by a LoadingCache
+const failuresSize = 10000
+
+// LoadFunc loads the value of a key missing in the cache. ttl is the time the value is cached,
+// 0 uses the default ttl of the cache
+type LoadFunc[V any] func(ctx context.Context) (value V, ttl time.Duration, err error)
+
+// LoadingConfig configures a LoadingCache
+type LoadingConfig struct {
+	// NegativeTTL is the time the errors of the loaders are cached, so a failing backend is not called by every request
+	// for the key. 0 disables it
+	NegativeTTL time.Duration
+}
+
+// LoadingCache is a typed cache that loads the missing keys with GetOrLoad
+type LoadingCache[V any] struct {
+	Typed[V]
+	cfg      LoadingConfig
+	failures *MemoryCache[error]
+
+	mu    sync.Mutex
+	calls map[string]*loadCall[V]
+}
+
+// loadCall is a running load of a key and its waiting callers
+type loadCall[V any] struct {
+	done    chan struct{}
+	value   V
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// NewLoadingCache wraps typed with GetOrLoad
+// Example:
+//
+//	users := cache.NewLoadingCache[*User](cache.NewTypedMemoryCache[*User]("users", 1000, time.Minute),
+//		cache.LoadingConfig{NegativeTTL: 5 * time.Second})
+func NewLoadingCache[V any](typed Typed[V], cfg LoadingConfig) *LoadingCache[V] {
+	return &LoadingCache[V]{
+		Typed:    typed,
+		cfg:      cfg,
+		failures: NewTypedMemoryCache[error]("failures", failuresSize, cfg.NegativeTTL),
+		calls:    make(map[string]*loadCall[V]),
+	}
+}
+
+// GetOrLoad returns the value of key, calling load if it is not in the cache and saving its result.
+// Concurrent calls for the same key wait for a single load instead of stampeding the backend.
+// A caller whose ctx is done stops waiting and gets ctx.Err(), the load goes on for the other callers
+// and it is canceled when none is left. With NegativeTTL, the error of a load is returned without loading
+// again until it expires. A nil ctx is a context.Background()
+// Example:
+//
+//	user, err := users.GetOrLoad(ctx, id, func(ctx context.Context) (*User, time.Duration, error) {
+//		user, err := repository.Find(ctx, id)
+//		return user, 10 * time.Minute, err
+//	})
+func (c *LoadingCache[V]) GetOrLoad(ctx context.Context, key string, load LoadFunc[V]) (V, error) {
+	value, found, err := c.Get(ctx, key)
+	if err != nil {
+		log.Printf("cache: error getting key %s, loading it: %v", key, err)
+	}
+	if found {
+		return value, nil
+	}
+	if err, failed, _ := c.failures.Get(nil, key); failed {
+		return value, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return c.wait(ctx, key, c.start(ctx, key, load))
+}
+
+// start joins the running load of key or starts a new one
+func (c *LoadingCache[V]) start(ctx context.Context, key string, load LoadFunc[V]) *loadCall[V] {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	call, ok := c.calls[key]
+	if !ok {
+		// the load belongs to all the callers, it keeps the values of the first ctx but not its cancellation
+		loadCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		call = &loadCall[V]{done: make(chan struct{}), cancel: cancel}
+		c.calls[key] = call
+		go c.load(loadCtx, key, call, load)
+	}
+	call.waiters++
+	return call
+}
+
+func (c *LoadingCache[V]) wait(ctx context.Context, key string, call *loadCall[V]) (V, error) {
+	select {
+	case <-call.done:
+		return call.value, call.err
+	case <-ctx.Done():
+	}
+	c.mu.Lock()
+	call.waiters--
+	if call.waiters == 0 {
+		// nobody waits for the load, the next caller starts a new one
+		call.cancel()
+		if c.calls[key] == call {
+			delete(c.calls, key)
+		}
+	}
+	c.mu.Unlock()
+	var zero V
+	return zero, ctx.Err()
+}
+
+func (c *LoadingCache[V]) load(ctx context.Context, key string, call *loadCall[V], load LoadFunc[V]) {
+	defer call.cancel()
+	value, ttl, err := safeLoad(ctx, key, load)
+	switch {
+	case err == nil:
+		if ttl > 0 {
+			err = c.SaveWithTTL(ctx, key, value, ttl)
+		} else {
+			err = c.Save(ctx, key, value)
+		}
+		if err != nil {
+			log.Printf("cache: error saving key %s: %v", key, err)
+		}
+		err = nil
+	case c.cfg.NegativeTTL > 0 && ctx.Err() == nil:
+		c.failures.SaveWithTTL(nil, key, err, c.cfg.NegativeTTL)
+	}
+
+	c.mu.Lock()
+	call.value, call.err = value, err
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// safeLoad calls load, a panic is returned as an error instead of crashing the goroutine of the load
+func safeLoad[V any](ctx context.Context, key string, load LoadFunc[V]) (value V, ttl time.Duration, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cache: loader of key %s panicked: %v", key, r)
+		}
+	}()
+	return load(ctx)
+}
+
+// Delete deletes the key and the cached error of its loader
+func (c *LoadingCache[V]) Delete(ctx context.Context, key string) error {
+	c.failures.Delete(nil, key)
+	return c.Typed.Delete(ctx, key)
+}
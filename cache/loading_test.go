@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLoadingCache(cfg LoadingConfig) *LoadingCache[string] {
+	return NewLoadingCache[string](NewTypedMemoryCache[string]("loading", 100, time.Minute), cfg)
+}
+
+// Test concurrent callers of a missing key share a single load
+func TestGetOrLoadSingleflight(t *testing.T) {
+	c := newLoadingCache(LoadingConfig{})
+	var loads int32
+	release := make(chan struct{})
+	load := func(ctx context.Context) (string, time.Duration, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", 0, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 50)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.GetOrLoad(context.Background(), "key", load)
+			assert.Nil(t, err)
+			results <- v
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	for v := range results {
+		assert.Equal(t, "value", v)
+	}
+	v, found, _ := c.Get(context.Background(), "key")
+	assert.True(t, found)
+	assert.Equal(t, "value", v)
+}
+
+// Test the errors of the loader are cached with NegativeTTL and the panics are returned as errors
+func TestGetOrLoadNegativeCaching(t *testing.T) {
+	ctx := context.Background()
+	loads := 0
+	failing := func(ctx context.Context) (string, time.Duration, error) {
+		loads++
+		return "", 0, errors.New("backend down")
+	}
+
+	c := newLoadingCache(LoadingConfig{NegativeTTL: 50 * time.Millisecond})
+	for i := 0; i < 3; i++ {
+		_, err := c.GetOrLoad(ctx, "key", failing)
+		assert.EqualError(t, err, "backend down")
+	}
+	assert.Equal(t, 1, loads)
+	time.Sleep(60 * time.Millisecond)
+	c.GetOrLoad(ctx, "key", failing)
+	assert.Equal(t, 2, loads)
+	c.Delete(ctx, "key")
+	c.GetOrLoad(ctx, "key", failing)
+	assert.Equal(t, 3, loads)
+
+	c = newLoadingCache(LoadingConfig{})
+	c.GetOrLoad(ctx, "key", failing)
+	c.GetOrLoad(ctx, "key", failing)
+	assert.Equal(t, 5, loads)
+
+	_, err := c.GetOrLoad(ctx, "panic", func(ctx context.Context) (string, time.Duration, error) {
+		panic("boom")
+	})
+	assert.EqualError(t, err, "cache: loader of key panic panicked: boom")
+}
+
+// Test a canceled caller stops waiting and the load is canceled when no caller is left
+func TestGetOrLoadCancellation(t *testing.T) {
+	c := newLoadingCache(LoadingConfig{NegativeTTL: time.Minute})
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	load := func(ctx context.Context) (string, time.Duration, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return "", 0, ctx.Err()
+	}
+
+	first, cancelFirst := context.WithCancel(context.Background())
+	second, cancelSecond := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	go func() {
+		_, err := c.GetOrLoad(first, "key", load)
+		errs <- err
+	}()
+	<-started
+	go func() {
+		_, err := c.GetOrLoad(second, "key", load)
+		errs <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	cancelFirst()
+	assert.Equal(t, context.Canceled, <-errs)
+	select {
+	case <-canceled:
+		t.Fatal("the load was canceled while a caller was waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancelSecond()
+	assert.Equal(t, context.Canceled, <-errs)
+	<-canceled
+
+	// the canceled load is not cached as a failure
+	v, err := c.GetOrLoad(context.Background(), "key", func(ctx context.Context) (string, time.Duration, error) {
+		return "value", 0, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "value", v)
+}
+
+// Test GetOrLoad accepts a nil ctx like the rest of the cache
+func TestGetOrLoadNilContext(t *testing.T) {
+	c := newLoadingCache(LoadingConfig{})
+	v, err := c.GetOrLoad(nil, "key", func(ctx context.Context) (string, time.Duration, error) {
+		assert.NotNil(t, ctx)
+		return "value", 0, nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "value", v)
+	v, _, _ = c.Get(nil, "key")
+	assert.Equal(t, "value", v)
+}
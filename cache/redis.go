@@ -0,0 +1,306 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRedisAddr     = "localhost:6379"
+	defaultRedisPoolSize = 10
+	defaultMaxActive     = 100
+	defaultRedisTimeout  = 3 * time.Second
+)
+
+// Codec serializes the values stored in redis. Marshal and Unmarshal receive a pointer to the value
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec stores the values as JSON. It is the default codec, the interface{} values are read back as
+// maps, slices and float64 like in encoding/json
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// GobCodec stores the values with encoding/gob. The interface{} values keep their concrete type, that must be
+// registered with gob.Register
+type GobCodec struct{}
+
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	err := gob.NewEncoder(&b).Encode(v)
+	return b.Bytes(), err
+}
+
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// RedisConfig configures the connection to a Redis compatible server
+type RedisConfig struct {
+	// Addr is the host:port of the server. Defaults to localhost:6379
+	Addr string
+	// Username and Password are sent with AUTH when Password is set
+	Username string
+	Password string
+	// DB is the database selected with SELECT
+	DB int
+	// KeyPrefix is prepended to the keys, so several caches can share a database. Example: "users:"
+	KeyPrefix string
+	// Codec serializes the values. Defaults to JSONCodec
+	Codec Codec
+	// PoolSize is the max number of idle connections kept open. Defaults to 10, capped to MaxActive
+	PoolSize int
+	// MaxActive is the max number of open connections, idle or in use. When they are all in use, the operations
+	// wait for one until their ctx is done or Timeout elapses. Defaults to 100
+	MaxActive int
+	// DialTimeout and Timeout limit the connection and each command when the ctx has no earlier deadline.
+	// Default to 3 seconds
+	DialTimeout time.Duration
+	Timeout     time.Duration
+}
+
+// RedisCache is a Typed cache stored in a Redis compatible server, shared by all the instances of a service.
+// It speaks RESP over a pool of connections and it is safe for concurrent use
+type RedisCache[V any] struct {
+	name       string
+	defaultTTL time.Duration
+	prefix     string
+	codec      Codec
+	pool       *respPool
+}
+
+var _ Typed[string] = (*RedisCache[string])(nil)
+
+// NewRedisCache creates a new RedisCache of interface{} values behind the untyped Spec. The connection is
+// opened by the first operation.
+// name: name of the cache
+// ttl: default ttl for items in the cache, 0 stores them without expiration
+// With the default JSONCodec the values are read back as maps, read them with As. A GobCodec keeps the
+// concrete types registered with gob.Register.
+// Example:
+//
+//	client.Cache = cache.NewRedisCache("products", time.Minute, cfg)
+func NewRedisCache(name string, ttl time.Duration, cfg RedisConfig) Spec {
+	return AsSpec[interface{}](NewTypedRedisCache[interface{}](name, ttl, cfg))
+}
+
+// NewTypedRedisCache creates a new RedisCache of values of type V. The connection is opened by the first operation.
+// name: name of the cache
+// ttl: default ttl for items in the cache, 0 stores them without expiration
+// Example:
+//
+//	users := cache.NewTypedRedisCache[*User]("users", time.Hour, cache.RedisConfig{Addr: "redis:6379", KeyPrefix: "users:"})
+//	user, found, err := users.Get(ctx, "user-1")
+func NewTypedRedisCache[V any](name string, ttl time.Duration, cfg RedisConfig) *RedisCache[V] {
+	if cfg.Addr == "" {
+		cfg.Addr = defaultRedisAddr
+	}
+	if cfg.Codec == nil {
+		cfg.Codec = JSONCodec{}
+	}
+	if cfg.MaxActive <= 0 {
+		cfg.MaxActive = defaultMaxActive
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = defaultRedisPoolSize
+	}
+	cfg.PoolSize = min(cfg.PoolSize, cfg.MaxActive)
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = defaultRedisTimeout
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRedisTimeout
+	}
+	return &RedisCache[V]{
+		name:       name,
+		defaultTTL: ttl,
+		prefix:     cfg.KeyPrefix,
+		codec:      cfg.Codec,
+		pool:       newRespPool(cfg),
+	}
+}
+
+// Get returns the value of key, found is false if the key is not in the cache or it expired
+func (impl *RedisCache[V]) Get(ctx context.Context, key string) (value V, found bool, err error) {
+	if ctx != nil {
+		log.Printf("cache.%s.Get: key=%s", impl.name, key)
+	}
+	r, err := impl.do(ctx, newCommand("GET", impl.prefix+key))
+	if err != nil || r.null {
+		return value, false, err
+	}
+	if err := impl.codec.Unmarshal(r.value, &value); err != nil {
+		return value, false, err
+	}
+	return value, true, nil
+}
+
+func (impl *RedisCache[V]) Save(ctx context.Context, key string, value V) error {
+	if ctx != nil {
+		log.Printf("cache.%s.Save: key=%s", impl.name, key)
+	}
+	return impl.set(ctx, key, value, impl.defaultTTL)
+}
+
+// SaveWithTTL saves the value with a custom ttl, 0 stores it without expiration
+func (impl *RedisCache[V]) SaveWithTTL(ctx context.Context, key string, value V, ttl time.Duration) error {
+	if ctx != nil {
+		log.Printf("cache.%s.SaveWithTTL: key=%s, ttl=%v", impl.name, key, ttl)
+	}
+	return impl.set(ctx, key, value, ttl)
+}
+
+// SaveIfAbsent saves the value with a custom ttl only if key is not in the cache, with a SET NX. saved is false
+// if it was. 0 stores it without expiration.
+// The SET NX is not sent again if the connection breaks, the server may have run it: an error is returned
+// and the key may or may not be saved
+func (impl *RedisCache[V]) SaveIfAbsent(ctx context.Context, key string, value V, ttl time.Duration) (saved bool, err error) {
+	if ctx != nil {
+		log.Printf("cache.%s.SaveIfAbsent: key=%s, ttl=%v", impl.name, key, ttl)
+	}
+	cmd, err := impl.setCommand(key, value, ttl)
+	if err != nil {
+		return false, err
+	}
+	replies, _, err := impl.pool.doOnce(ctx, append(cmd, []byte("NX")))
+	if err != nil {
+		return false, err
+	}
+	if err := replies[0].err; err != nil {
+		return false, err
+	}
+	return !replies[0].null, nil
+}
+
+func (impl *RedisCache[V]) set(ctx context.Context, key string, value V, ttl time.Duration) error {
+	cmd, err := impl.setCommand(key, value, ttl)
+	if err != nil {
+		return err
+	}
+	_, err = impl.do(ctx, cmd)
+	return err
+}
+
+// Delete key value pair from the cache
+func (impl *RedisCache[V]) Delete(ctx context.Context, key string) error {
+	if ctx != nil {
+		log.Printf("cache.%s.Delete: key=%s", impl.name, key)
+	}
+	_, err := impl.do(ctx, newCommand("DEL", impl.prefix+key))
+	return err
+}
+
+// GetMany returns the values of keys found in the cache with a single MGET
+// Example:
+//
+//	users, err := users.GetMany(ctx, []string{"user-1", "user-2"})
+func (impl *RedisCache[V]) GetMany(ctx context.Context, keys []string) (map[string]V, error) {
+	if ctx != nil {
+		log.Printf("cache.%s.GetMany: keys=%d", impl.name, len(keys))
+	}
+	values := make(map[string]V, len(keys))
+	if len(keys) == 0 {
+		return values, nil
+	}
+	cmd := newCommand("MGET")
+	for _, key := range keys {
+		cmd = append(cmd, []byte(impl.prefix+key))
+	}
+	r, err := impl.do(ctx, cmd)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range r.array {
+		if item.null || i >= len(keys) {
+			continue
+		}
+		var value V
+		if err := impl.codec.Unmarshal(item.value, &value); err != nil {
+			return nil, err
+		}
+		values[keys[i]] = value
+	}
+	return values, nil
+}
+
+// SaveMany saves the items with the same ttl, 0 stores them without expiration.
+// The SETs are pipelined, they are sent together and cost a single round trip
+func (impl *RedisCache[V]) SaveMany(ctx context.Context, items map[string]V, ttl time.Duration) error {
+	if ctx != nil {
+		log.Printf("cache.%s.SaveMany: keys=%d, ttl=%v", impl.name, len(items), ttl)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	cmds := make([]command, 0, len(items))
+	for key, value := range items {
+		cmd, err := impl.setCommand(key, value, ttl)
+		if err != nil {
+			return err
+		}
+		cmds = append(cmds, cmd)
+	}
+	replies, err := impl.pool.do(ctx, cmds...)
+	if err != nil {
+		return err
+	}
+	return firstError(replies)
+}
+
+// DeleteMany deletes the keys with a single DEL
+func (impl *RedisCache[V]) DeleteMany(ctx context.Context, keys ...string) error {
+	if ctx != nil {
+		log.Printf("cache.%s.DeleteMany: keys=%d", impl.name, len(keys))
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	cmd := newCommand("DEL")
+	for _, key := range keys {
+		cmd = append(cmd, []byte(impl.prefix+key))
+	}
+	_, err := impl.do(ctx, cmd)
+	return err
+}
+
+// Close closes the idle connections
+func (impl *RedisCache[V]) Close() error {
+	return impl.pool.close()
+}
+
+func (impl *RedisCache[V]) setCommand(key string, value V, ttl time.Duration) (command, error) {
+	data, err := impl.codec.Marshal(&value)
+	if err != nil {
+		return nil, err
+	}
+	cmd := command{[]byte("SET"), []byte(impl.prefix + key), data}
+	if ttl > 0 {
+		// PX has a millisecond precision, a shorter ttl would be rejected
+		ms := max(ttl.Milliseconds(), 1)
+		cmd = append(cmd, []byte("PX"), []byte(strconv.FormatInt(ms, 10)))
+	}
+	return cmd, nil
+}
+
+// do runs a single command and returns its reply, or the error reply as an error
+func (impl *RedisCache[V]) do(ctx context.Context, cmd command) (reply, error) {
+	replies, err := impl.pool.do(ctx, cmd)
+	if err != nil {
+		return reply{}, err
+	}
+	return replies[0], replies[0].err
+}
@@ -0,0 +1,203 @@
+package cache
+
+import (
+	"context"
+	"encoding/gob"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/cache/cachetest"
+)
+
+type redisUser struct {
+	Name  string
+	Roles []string
+}
+
+// Test the Spec backed by redis saves, expires and deletes the values
+func TestRedisCacheSpec(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "")
+	cache := NewRedisCache("redis", time.Minute, RedisConfig{Addr: server.Addr()})
+	ctx := context.Background()
+
+	cache.Save(ctx, "key", "value")
+	expired, value := cache.Get(ctx, "key")
+	assert.Equal(t, "value", value)
+	assert.False(t, expired)
+
+	cache.SaveWithTTL(ctx, "short", "value", 50*time.Millisecond)
+	_, value = cache.Get(ctx, "short")
+	assert.Equal(t, "value", value)
+	time.Sleep(60 * time.Millisecond)
+	_, value = cache.Get(ctx, "short")
+	assert.Nil(t, value)
+
+	cache.Delete(ctx, "key")
+	_, value = cache.Get(ctx, "key")
+	assert.Nil(t, value)
+}
+
+// Test typed values, the default ttl, the key prefix and the reconnection after the server drops the connections
+func TestRedisCacheTyped(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "secret")
+	users := NewTypedRedisCache[*redisUser]("users", time.Minute,
+		RedisConfig{Addr: server.Addr(), Password: "secret", DB: 2, KeyPrefix: "users:"})
+	defer users.Close()
+	ctx := context.Background()
+
+	assert.Nil(t, users.Save(ctx, "1", &redisUser{Name: "Ada", Roles: []string{"admin"}}))
+	user, found, err := users.Get(ctx, "1")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, &redisUser{Name: "Ada", Roles: []string{"admin"}}, user)
+
+	value, ttl, _ := server.Get("users:1")
+	assert.Equal(t, `{"Name":"Ada","Roles":["admin"]}`, value)
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	assert.Nil(t, users.SaveWithTTL(ctx, "2", &redisUser{Name: "Grace"}, 0))
+	_, ttl, found = server.Get("users:2")
+	assert.True(t, found)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	server.DropConns()
+	_, found, err = users.Get(ctx, "missing")
+	assert.Nil(t, err)
+	assert.False(t, found)
+	assert.Equal(t, 2, server.Dials())
+}
+
+// Test the bulk operations are sent in a single batch
+func TestRedisCacheBulk(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "")
+	cache := NewTypedRedisCache[int]("counters", time.Minute, RedisConfig{Addr: server.Addr()})
+	ctx := context.Background()
+
+	assert.Nil(t, cache.SaveMany(ctx, map[string]int{"a": 1, "b": 2, "c": 3}, time.Minute))
+	values, err := cache.GetMany(ctx, []string{"a", "missing", "c"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"a": 1, "c": 3}, values)
+
+	assert.Nil(t, cache.DeleteMany(ctx, "a", "b"))
+	values, _ = cache.GetMany(ctx, []string{"a", "b", "c"})
+	assert.Equal(t, map[string]int{"c": 3}, values)
+
+	assert.Equal(t, [][]string{{"SET", "SET", "SET"}, {"MGET"}, {"DEL"}, {"MGET"}}, server.Batches())
+}
+
+// Test the pluggable codecs and the errors of the server
+func TestRedisCacheCodecAndErrors(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "secret")
+	gob.Register(&redisUser{})
+	cache := NewRedisCache("gob", time.Minute, RedisConfig{Addr: server.Addr(), Password: "secret", Codec: GobCodec{}})
+	ctx := context.Background()
+
+	cache.Save(ctx, "key", &redisUser{Name: "Ada"})
+	_, value := cache.Get(ctx, "key")
+	assert.Equal(t, &redisUser{Name: "Ada"}, value)
+
+	wrongPassword := NewTypedRedisCache[string]("wrong", time.Minute, RedisConfig{Addr: server.Addr(), Password: "wrong"})
+	_, _, err := wrongPassword.Get(ctx, "key")
+	assert.Equal(t, RedisError("WRONGPASS invalid username-password pair"), err)
+
+	typed := NewTypedRedisCache[int]("typed", time.Minute, RedisConfig{Addr: server.Addr(), Password: "secret"})
+	assert.Nil(t, typed.SaveWithTTL(ctx, "key", 1, time.Minute))
+	_, _, err = NewTypedRedisCache[*redisUser]("typed", time.Minute, RedisConfig{Addr: server.Addr(), Password: "secret"}).Get(ctx, "key")
+	assert.NotNil(t, err)
+
+	unreachable := NewTypedRedisCache[string]("down", time.Minute, RedisConfig{Addr: "127.0.0.1:1", DialTimeout: 100 * time.Millisecond})
+	_, _, err = unreachable.Get(ctx, "key")
+	assert.NotNil(t, err)
+}
+
+// Test SaveIfAbsent is a SET NX
+func TestRedisSaveIfAbsent(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "")
+	spec, ok := NewRedisCache("locks", time.Minute, RedisConfig{Addr: server.Addr(), KeyPrefix: "locks:"}).(AtomicSpec)
+	assert.True(t, ok)
+	ctx := context.Background()
+
+	saved, err := spec.SaveIfAbsent(ctx, "1", "a", 20*time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, saved)
+	saved, err = spec.SaveIfAbsent(ctx, "1", "b", time.Minute)
+	assert.Nil(t, err)
+	assert.False(t, saved)
+	time.Sleep(50 * time.Millisecond)
+	saved, _ = spec.SaveIfAbsent(ctx, "1", "c", 0)
+	assert.True(t, saved)
+	value, ttl, _ := server.Get("locks:1")
+	assert.Equal(t, `"c"`, value)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	// the SET NX is not sent again on a new connection, it may have run on the broken one
+	server.DropConns()
+	_, err = spec.SaveIfAbsent(ctx, "2", "a", time.Minute)
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, server.Dials())
+	saved, err = spec.SaveIfAbsent(ctx, "2", "a", time.Minute)
+	assert.Nil(t, err)
+	assert.True(t, saved)
+
+	down, _ := NewRedisCache("down", time.Minute, RedisConfig{Addr: "127.0.0.1:1"}).(AtomicSpec)
+	saved, err = down.SaveIfAbsent(ctx, "1", "a", time.Minute)
+	assert.NotNil(t, err)
+	assert.False(t, saved)
+}
+
+// Test the open connections are limited by MaxActive
+func TestRedisCacheMaxActive(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "")
+	cache := NewTypedRedisCache[int]("limited", time.Minute, RedisConfig{Addr: server.Addr(), PoolSize: 1, MaxActive: 2})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, cache.Save(ctx, "key", 1))
+		}()
+	}
+	wg.Wait()
+	assert.LessOrEqual(t, server.Dials(), 2)
+
+	// a caller whose ctx is done does not wait for a connection
+	busy := NewTypedRedisCache[int]("busy", time.Minute, RedisConfig{Addr: server.Addr(), MaxActive: 1})
+	conn, _, err := busy.pool.get(ctx)
+	assert.Nil(t, err)
+	timeout, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, _, err = busy.Get(timeout, "key")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	busy.pool.put(conn)
+	_, _, err = busy.Get(ctx, "key")
+	assert.Nil(t, err)
+}
+
+// Test the values of a Spec are converted back to their type when the codec returns maps
+func TestAs(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "")
+	spec := NewRedisCache("as", time.Minute, RedisConfig{Addr: server.Addr()})
+	ctx := context.Background()
+
+	spec.Save(ctx, "user", &redisUser{Name: "Ada", Roles: []string{"admin"}})
+	_, value := spec.Get(ctx, "user")
+	assert.IsType(t, map[string]interface{}{}, value)
+	user, ok := As[*redisUser](value)
+	assert.True(t, ok)
+	assert.Equal(t, &redisUser{Name: "Ada", Roles: []string{"admin"}}, user)
+
+	same := &redisUser{Name: "Grace"}
+	user, ok = As[*redisUser](same)
+	assert.True(t, ok)
+	assert.Same(t, same, user)
+
+	_, ok = As[*redisUser](nil)
+	assert.False(t, ok)
+	_, ok = As[*redisUser]("not a user")
+	assert.False(t, ok)
+}
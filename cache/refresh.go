@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/karlseguin/ccache"
+)
+
+const (
+	defaultRefreshTimeout = 30 * time.Second
+	defaultLoadTime       = 100 * time.Millisecond
+)
+
+// RefreshConfig configures the background refreshes of a MemoryCache. Set AheadFraction or Beta
+type RefreshConfig[V any] struct {
+	// Loader loads the new value of a key. ttl is the time the value is cached, 0 uses the default ttl of the cache
+	Loader func(ctx context.Context, key string) (value V, ttl time.Duration, err error)
+	// AheadFraction enables refresh-ahead: a value older than this fraction of its ttl is refreshed. Example: 0.8
+	AheadFraction float64
+	// Beta enables the XFetch probabilistic early expiration: each Get refreshes the value with a probability that grows
+	// as its expiration approaches and with the time its load took. 1 is the recommended value, a higher one refreshes
+	// earlier
+	Beta float64
+	// LoadTime is the estimated duration of a load, used by XFetch for the values that were saved and not refreshed.
+	// Defaults to 100ms
+	LoadTime time.Duration
+	// Timeout of each refresh. Defaults to 30 seconds
+	Timeout time.Duration
+	// OnError is called when a refresh fails, the current value is kept until it expires. Defaults to log the error
+	OnError func(key string, err error)
+}
+
+// WithRefresh refreshes the values close to their expiration in the background, so the hot keys do not expire and
+// cause latency spikes while they reload. Only one refresh of a key runs at a time, and a Save or a Delete made
+// while it loads wins over the refreshed value.
+// It must be called before the cache is used
+// Example:
+//
+//	rates := cache.NewTypedMemoryCache[float64]("rates", 100, time.Minute).WithRefresh(cache.RefreshConfig[float64]{
+//		Loader: func(ctx context.Context, currency string) (float64, time.Duration, error) {
+//			rate, err := ratesAPI.Get(ctx, currency)
+//			return rate, 0, err
+//		},
+//		AheadFraction: 0.8,
+//	})
+func (impl *MemoryCache[V]) WithRefresh(cfg RefreshConfig[V]) *MemoryCache[V] {
+	if cfg.Loader == nil {
+		panic("cache: RefreshConfig.Loader is required")
+	}
+	if (cfg.AheadFraction <= 0 || cfg.AheadFraction >= 1) && cfg.Beta <= 0 {
+		panic("cache: RefreshConfig needs an AheadFraction between 0 and 1 or a Beta greater than 0")
+	}
+	if cfg.LoadTime <= 0 {
+		cfg.LoadTime = defaultLoadTime
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultRefreshTimeout
+	}
+	if cfg.OnError == nil {
+		cfg.OnError = func(key string, err error) {
+			log.Printf("cache.%s.Refresh: error refreshing key=%s: %v", impl.name, key, err)
+		}
+	}
+	impl.refresh = &cfg
+	return impl
+}
+
+// shouldRefresh decides if a value that expires at expires must be refreshed
+func (impl *MemoryCache[V]) shouldRefresh(e entry[V], expires time.Time) bool {
+	remaining := time.Until(expires)
+	if impl.refresh.Beta > 0 {
+		loadTime := e.loadTime
+		if loadTime == 0 {
+			loadTime = impl.refresh.LoadTime
+		}
+		// XFetch: the expiration is moved forward by loadTime * beta * -ln(rand), rand is in (0, 1]
+		return float64(loadTime)*impl.refresh.Beta*-math.Log(1-impl.random()) >= float64(remaining)
+	}
+	return e.ttl-remaining >= time.Duration(float64(e.ttl)*impl.refresh.AheadFraction)
+}
+
+// startRefresh refreshes key in the background, unless it is already being refreshed. item is the current item of key,
+// the new value is discarded if a Save or a Delete replaced it during the load
+func (impl *MemoryCache[V]) startRefresh(key string, item *ccache.Item) {
+	impl.mu.Lock()
+	if impl.refreshing[key] {
+		impl.mu.Unlock()
+		return
+	}
+	impl.refreshing[key] = true
+	impl.mu.Unlock()
+
+	go func() {
+		defer func() {
+			impl.mu.Lock()
+			delete(impl.refreshing, key)
+			impl.mu.Unlock()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), impl.refresh.Timeout)
+		defer cancel()
+
+		start := time.Now()
+		value, ttl, err := safeLoad(ctx, key, func(ctx context.Context) (V, time.Duration, error) {
+			return impl.refresh.Loader(ctx, key)
+		})
+		if err != nil {
+			impl.refresh.OnError(key, err)
+			return
+		}
+		if ttl <= 0 {
+			ttl = impl.defaultTTL
+		}
+		impl.mu.Lock()
+		if impl.cCache.Get(key) == item {
+			impl.set(key, entry[V]{value: value, ttl: ttl, loadTime: time.Since(start)})
+		}
+		impl.mu.Unlock()
+	}()
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eventually waits for the value of key to be want
+func eventually(t *testing.T, c *MemoryCache[string], key, want string) {
+	assert.Eventually(t, func() bool {
+		v, _, _ := c.Get(context.Background(), key)
+		return v == want
+	}, time.Second, 5*time.Millisecond)
+}
+
+// Test the values past the fraction of their ttl are refreshed once in the background
+func TestRefreshAhead(t *testing.T) {
+	var loads int32
+	release := make(chan struct{})
+	c := NewTypedMemoryCache[string]("refresh", 10, 100*time.Millisecond).WithRefresh(RefreshConfig[string]{
+		Loader: func(ctx context.Context, key string) (string, time.Duration, error) {
+			atomic.AddInt32(&loads, 1)
+			<-release
+			return key + "-v2", time.Minute, nil
+		},
+		AheadFraction: 0.5,
+	})
+	ctx := context.Background()
+	c.Save(ctx, "key", "v1")
+
+	v, _, _ := c.Get(ctx, "key")
+	assert.Equal(t, "v1", v)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&loads))
+
+	time.Sleep(60 * time.Millisecond)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, found, _ := c.Get(ctx, "key")
+			assert.True(t, found)
+			assert.Equal(t, "v1", v)
+		}()
+	}
+	wg.Wait()
+	close(release)
+	eventually(t, c, "key", "key-v2")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
+// Test XFetch refreshes early depending on the random draw
+func TestRefreshXFetch(t *testing.T) {
+	var loads int32
+	c := NewTypedMemoryCache[string]("xfetch", 10, time.Minute).WithRefresh(RefreshConfig[string]{
+		Loader: func(ctx context.Context, key string) (string, time.Duration, error) {
+			atomic.AddInt32(&loads, 1)
+			return "v2", 0, nil
+		},
+		Beta:     1,
+		LoadTime: time.Second,
+	})
+	ctx := context.Background()
+	c.SaveWithTTL(ctx, "key", "v1", 10*time.Second)
+
+	// -ln(1) is 0, the value is not refreshed before it expires
+	c.random = func() float64 { return 0 }
+	c.Get(ctx, "key")
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&loads))
+
+	// -ln(1e-6) is about 13.8, so the expiration moves 13.8 seconds forward
+	c.random = func() float64 { return 1 - 1e-6 }
+	v, _, _ := c.Get(ctx, "key")
+	assert.Equal(t, "v1", v)
+	eventually(t, c, "key", "v2")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&loads))
+}
+
+// Test the failed refreshes are reported and keep the current value
+func TestRefreshError(t *testing.T) {
+	failures := make(chan string, 1)
+	c := NewTypedMemoryCache[string]("failing", 10, 50*time.Millisecond).WithRefresh(RefreshConfig[string]{
+		Loader: func(ctx context.Context, key string) (string, time.Duration, error) {
+			return "", 0, errors.New("backend down")
+		},
+		AheadFraction: 0.1,
+		OnError: func(key string, err error) {
+			failures <- key + ": " + err.Error()
+		},
+	})
+	ctx := context.Background()
+	c.Save(ctx, "key", "v1")
+	time.Sleep(10 * time.Millisecond)
+
+	v, _, _ := c.Get(ctx, "key")
+	assert.Equal(t, "v1", v)
+	assert.Equal(t, "key: backend down", <-failures)
+	v, _, _ = c.Get(ctx, "key")
+	assert.Equal(t, "v1", v)
+
+	assert.Panics(t, func() { NewTypedMemoryCache[string]("invalid", 10, time.Minute).WithRefresh(RefreshConfig[string]{}) })
+}
+
+// Test a refresh does not overwrite the values saved or deleted while it loads
+func TestRefreshDoesNotOverwriteWrites(t *testing.T) {
+	loads := make(chan string, 10)
+	release := make(chan struct{})
+	c := NewTypedMemoryCache[string]("writes", 10, 100*time.Millisecond).WithRefresh(RefreshConfig[string]{
+		Loader: func(ctx context.Context, key string) (string, time.Duration, error) {
+			loads <- key
+			<-release
+			return "refreshed", time.Minute, nil
+		},
+		AheadFraction: 0.1,
+	})
+	ctx := context.Background()
+	c.Save(ctx, "saved", "v1")
+	c.Save(ctx, "deleted", "v1")
+	time.Sleep(20 * time.Millisecond)
+
+	c.Get(ctx, "saved")
+	c.Get(ctx, "deleted")
+	<-loads
+	<-loads
+	c.Save(ctx, "saved", "v2")
+	c.Delete(ctx, "deleted")
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return len(c.refreshing) == 0
+	}, time.Second, 5*time.Millisecond)
+	v, _, _ := c.Get(ctx, "saved")
+	assert.Equal(t, "v2", v)
+	_, found, _ := c.Get(ctx, "deleted")
+	assert.False(t, found)
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RedisError is an error reply of the redis server, like a wrong password or an unknown command
+type RedisError string
+
+func (e RedisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// command is a redis command and its arguments, sent as a RESP array of bulk strings
+type command [][]byte
+
+func newCommand(name string, args ...string) command {
+	cmd := command{[]byte(name)}
+	for _, arg := range args {
+		cmd = append(cmd, []byte(arg))
+	}
+	return cmd
+}
+
+// reply is a RESP reply. A null bulk string or array sets null, an error reply sets err
+type reply struct {
+	value []byte
+	num   int64
+	array []reply
+	null  bool
+	err   error
+}
+
+// respConn is a connection to the redis server
+type respConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// pipeline sends all the commands before reading their replies, so a batch costs a single round trip.
+// An error means the connection is broken and must be closed, the error replies are in the replies
+func (c *respConn) pipeline(ctx context.Context, timeout time.Duration, cmds ...command) ([]reply, error) {
+	deadline := time.Now().Add(timeout)
+	if ctx != nil {
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		c.writeCommand(cmd)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]reply, len(cmds))
+	for i := range replies {
+		var err error
+		if replies[i], err = c.readReply(); err != nil {
+			return nil, err
+		}
+	}
+	return replies, nil
+}
+
+func (c *respConn) writeCommand(cmd command) {
+	c.w.WriteString("*" + strconv.Itoa(len(cmd)) + "\r\n")
+	for _, arg := range cmd {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.Write(arg)
+		c.w.WriteString("\r\n")
+	}
+}
+
+func (c *respConn) readReply() (reply, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return reply{}, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return reply{}, fmt.Errorf("redis: invalid reply line %q", line)
+	}
+	kind, payload := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return reply{value: []byte(payload)}, nil
+	case '-':
+		return reply{err: RedisError(payload)}, nil
+	case ':':
+		n, err := strconv.ParseInt(payload, 10, 64)
+		return reply{num: n}, err
+	case '$':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return reply{null: true}, err
+		}
+		value := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, value); err != nil {
+			return reply{}, err
+		}
+		return reply{value: value[:n]}, nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+		if err != nil || n < 0 {
+			return reply{null: true}, err
+		}
+		array := make([]reply, n)
+		for i := range array {
+			if array[i], err = c.readReply(); err != nil {
+				return reply{}, err
+			}
+		}
+		return reply{array: array}, nil
+	}
+	return reply{}, fmt.Errorf("redis: unknown reply type %q", kind)
+}
+
+// respPool keeps the idle connections to the redis server. Each open connection, idle or in use, holds a slot
+// of active, so at most MaxActive connections are open
+type respPool struct {
+	cfg    RedisConfig
+	idle   chan *respConn
+	active chan struct{}
+}
+
+func newRespPool(cfg RedisConfig) *respPool {
+	return &respPool{cfg: cfg, idle: make(chan *respConn, cfg.PoolSize), active: make(chan struct{}, cfg.MaxActive)}
+}
+
+// get returns an idle connection, reused is false if it was just dialed. When MaxActive connections are open,
+// it waits for one to be released until the ctx is done or Timeout elapses
+func (p *respPool) get(ctx context.Context) (conn *respConn, reused bool, err error) {
+	select {
+	case conn := <-p.idle:
+		return conn, true, nil
+	default:
+	}
+	var done <-chan struct{}
+	if ctx != nil {
+		done = ctx.Done()
+	}
+	timer := time.NewTimer(p.cfg.Timeout)
+	defer timer.Stop()
+	select {
+	case conn := <-p.idle:
+		return conn, true, nil
+	case p.active <- struct{}{}:
+	case <-done:
+		return nil, false, ctx.Err()
+	case <-timer.C:
+		return nil, false, fmt.Errorf("redis: pool exhausted, %d connections in use", p.cfg.MaxActive)
+	}
+	conn, err = p.dial(ctx)
+	if err != nil {
+		<-p.active
+	}
+	return conn, false, err
+}
+
+func (p *respPool) put(conn *respConn) {
+	select {
+	case p.idle <- conn:
+	default:
+		p.discard(conn)
+	}
+}
+
+// discard closes a connection and releases its slot
+func (p *respPool) discard(conn *respConn) {
+	conn.conn.Close()
+	<-p.active
+}
+
+func (p *respPool) dial(ctx context.Context) (*respConn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	dialer := net.Dialer{Timeout: p.cfg.DialTimeout}
+	netConn, err := dialer.DialContext(ctx, "tcp", p.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &respConn{conn: netConn, r: bufio.NewReader(netConn), w: bufio.NewWriter(netConn)}
+
+	var setup []command
+	if p.cfg.Password != "" {
+		if p.cfg.Username != "" {
+			setup = append(setup, newCommand("AUTH", p.cfg.Username, p.cfg.Password))
+		} else {
+			setup = append(setup, newCommand("AUTH", p.cfg.Password))
+		}
+	}
+	if p.cfg.DB != 0 {
+		setup = append(setup, newCommand("SELECT", strconv.Itoa(p.cfg.DB)))
+	}
+	if len(setup) > 0 {
+		replies, err := conn.pipeline(ctx, p.cfg.Timeout, setup...)
+		if err == nil {
+			err = firstError(replies)
+		}
+		if err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// do runs the commands in a pipeline. A reused connection closed by the server, like after an idle timeout,
+// is replaced by a new one and the commands are sent again, so they must be idempotent. See doOnce for the others
+func (p *respPool) do(ctx context.Context, cmds ...command) ([]reply, error) {
+	for {
+		replies, reused, err := p.doOnce(ctx, cmds...)
+		if err == nil || !reused || !isClosed(err) {
+			return replies, err
+		}
+	}
+}
+
+// doOnce runs the commands in a pipeline without sending them again, for the commands that are not idempotent like
+// SET NX: the server may have run them before the connection broke. reused tells if the connection was an idle one
+func (p *respPool) doOnce(ctx context.Context, cmds ...command) (replies []reply, reused bool, err error) {
+	conn, reused, err := p.get(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	replies, err = conn.pipeline(ctx, p.cfg.Timeout, cmds...)
+	if err != nil {
+		p.discard(conn)
+		return nil, reused, err
+	}
+	p.put(conn)
+	return replies, reused, nil
+}
+
+func (p *respPool) close() error {
+	for {
+		select {
+		case conn := <-p.idle:
+			p.discard(conn)
+		default:
+			return nil
+		}
+	}
+}
+
+// isClosed tells if err is caused by a connection closed by the server
+func isClosed(err error) bool {
+	var opErr *net.OpError
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) ||
+		(errors.As(err, &opErr) && !opErr.Timeout())
+}
+
+// firstError returns the first error reply
+func firstError(replies []reply) error {
+	for _, r := range replies {
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
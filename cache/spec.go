@@ -5,21 +5,22 @@ import (
 	"time"
 )
 
+// Spec is an untyped cache. Typed caches, like MemoryCache, are adapted to it with AsSpec
 type Spec interface {
 	// Get key value pair from the cache. If the key is not found, it returns nil. If the key is found, it returns the value.
 	// Also returns if the element is expired if was previously configured in the client
 	// The value is a pointer to the actual value stored in the cache. This is done to avoid copying the value.
-	// The value should be casted to the correct type before using it.
+	// The value should be casted to the correct type before using it, or use a Typed cache to avoid the casts.
 	// Example:
-	//  var expired bool
-	// 	var value *string
-	// 	expired, value = cache.Get(ctx, "key").(*string)
+	//  expired, value := cache.Get(ctx, "key")
+	//  s, ok := value.(string)
 	Get(ctx context.Context, key string) (expired bool, value interface{})
 	// Save key value pair in the cache
 	// Example:
 	//  cache.Save(ctx, "key", "value")
 	Save(ctx context.Context, key string, item interface{})
-	// SaveWithTTL saves key value pair in the cache with a custom ttl (in duration)
+	// SaveWithTTL saves key value pair in the cache with a custom ttl (in duration). A ttl of 0 or less stores it
+	// without expiration, a MemoryCache used to expire it at once
 	// Example:
 	//  cache.SaveWithTTL(ctx, "key", "value", 10*time.Second)
 	SaveWithTTL(ctx context.Context, key string, item interface{}, ttl time.Duration)
@@ -28,3 +29,14 @@ type Spec interface {
 	//  cache.Delete(ctx, "key")
 	Delete(ctx context.Context, key string)
 }
+
+// AtomicSpec is a Spec that saves a key only if it is absent in a single atomic operation, so the instances sharing
+// a remote cache can claim a key without a race. The Spec of MemoryCache and RedisCache implement it
+type AtomicSpec interface {
+	Spec
+	// SaveIfAbsent saves key value pair with a custom ttl only if the key is not in the cache or it expired.
+	// saved is false if the key was in the cache. Unlike the other methods, it reports the errors of remote caches
+	// Example:
+	//  saved, err := cache.SaveIfAbsent(ctx, "lock", "owner", time.Minute)
+	SaveIfAbsent(ctx context.Context, key string, item interface{}, ttl time.Duration) (saved bool, err error)
+}
@@ -0,0 +1,182 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Typed is a cache of values of type V, it needs no casts and reports the errors of remote caches
+type Typed[V any] interface {
+	// Get returns the value of key. found is false if the key is not in the cache or it expired
+	// Example:
+	//  user, found, err := users.Get(ctx, "user-1")
+	Get(ctx context.Context, key string) (value V, found bool, err error)
+	// Save saves the value with the default ttl of the cache
+	Save(ctx context.Context, key string, value V) error
+	// SaveWithTTL saves the value with a custom ttl, 0 or less stores it without expiration
+	SaveWithTTL(ctx context.Context, key string, value V, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+}
+
+var (
+	_ Typed[string]      = (*MemoryCache[string])(nil)
+	_ Spec               = (*specAdapter[string])(nil)
+	_ AtomicSpec         = (*atomicSpecAdapter[string])(nil)
+	_ savesIfAbsent[int] = (*MemoryCache[int])(nil)
+	_ savesIfAbsent[int] = (*RedisCache[int])(nil)
+)
+
+// stale is implemented by the caches that keep the expired values, like MemoryCache
+type stale[V any] interface {
+	GetStale(ctx context.Context, key string) (value V, expired bool, found bool)
+}
+
+// savesIfAbsent is implemented by the typed caches with an atomic set-if-absent, like MemoryCache and RedisCache
+type savesIfAbsent[V any] interface {
+	SaveIfAbsent(ctx context.Context, key string, value V, ttl time.Duration) (saved bool, err error)
+}
+
+// AsSpec adapts a typed cache to Spec, for the code that takes a Spec like rest.RestClient.Cache.
+// The errors of the typed cache are logged and Get returns a nil value for them.
+// Saving a value that is not a V is logged and ignored.
+// The Spec is an AtomicSpec if the typed cache has a SaveIfAbsent, like MemoryCache and RedisCache
+// Example:
+//
+//	client.Cache = cache.AsSpec[interface{}](redisCache)
+func AsSpec[V any](typed Typed[V]) Spec {
+	return newSpecAdapter(typed, false)
+}
+
+func newSpecAdapter[V any](typed Typed[V], returnExpired bool) Spec {
+	adapter := &specAdapter[V]{typed: typed, returnExpired: returnExpired}
+	if s, ok := typed.(savesIfAbsent[V]); ok {
+		return &atomicSpecAdapter[V]{specAdapter: adapter, saver: s}
+	}
+	return adapter
+}
+
+type specAdapter[V any] struct {
+	typed         Typed[V]
+	returnExpired bool
+}
+
+func (a *specAdapter[V]) Get(ctx context.Context, key string) (expired bool, value interface{}) {
+	if s, ok := a.typed.(stale[V]); ok && a.returnExpired {
+		v, expired, found := s.GetStale(ctx, key)
+		if !found {
+			return false, nil
+		}
+		return expired, v
+	}
+	v, found, err := a.typed.Get(ctx, key)
+	if err != nil {
+		log.Printf("cache: error getting key %s: %v", key, err)
+		return false, nil
+	}
+	if !found {
+		return false, nil
+	}
+	return false, v
+}
+
+func (a *specAdapter[V]) Save(ctx context.Context, key string, item interface{}) {
+	if v, ok := a.value(key, item); ok {
+		a.logError(key, a.typed.Save(ctx, key, v))
+	}
+}
+
+func (a *specAdapter[V]) SaveWithTTL(ctx context.Context, key string, item interface{}, ttl time.Duration) {
+	if v, ok := a.value(key, item); ok {
+		a.logError(key, a.typed.SaveWithTTL(ctx, key, v, ttl))
+	}
+}
+
+func (a *specAdapter[V]) Delete(ctx context.Context, key string) {
+	a.logError(key, a.typed.Delete(ctx, key))
+}
+
+type atomicSpecAdapter[V any] struct {
+	*specAdapter[V]
+	saver savesIfAbsent[V]
+}
+
+func (a *atomicSpecAdapter[V]) SaveIfAbsent(ctx context.Context, key string, item interface{}, ttl time.Duration) (bool, error) {
+	v, ok := a.value(key, item)
+	if !ok {
+		return false, fmt.Errorf("cache: key %s has a %T, not a %T", key, item, v)
+	}
+	return a.saver.SaveIfAbsent(ctx, key, v, ttl)
+}
+
+func (a *specAdapter[V]) value(key string, item interface{}) (V, bool) {
+	v, ok := item.(V)
+	if !ok && item != nil {
+		log.Printf("cache: ignoring key %s, %T is not a %T", key, item, v)
+	}
+	return v, ok || item == nil
+}
+
+func (a *specAdapter[V]) logError(key string, err error) {
+	if err != nil {
+		log.Printf("cache: error on key %s: %v", key, err)
+	}
+}
+
+// AsTyped adapts a Spec to a typed cache. Get returns an error if the value in the cache is not a V
+// Example:
+//
+//	nonces := cache.AsTyped[int64](cache.NewMemoryCache("nonces", 10000, time.Minute, false))
+func AsTyped[V any](spec Spec) Typed[V] {
+	return &typedAdapter[V]{spec: spec}
+}
+
+type typedAdapter[V any] struct {
+	spec Spec
+}
+
+func (a *typedAdapter[V]) Get(ctx context.Context, key string) (value V, found bool, err error) {
+	expired, item := a.spec.Get(ctx, key)
+	if item == nil || expired {
+		return value, false, nil
+	}
+	value, ok := item.(V)
+	if !ok {
+		return value, false, fmt.Errorf("cache: key %s has a %T, not a %T", key, item, value)
+	}
+	return value, true, nil
+}
+
+func (a *typedAdapter[V]) Save(ctx context.Context, key string, value V) error {
+	a.spec.Save(ctx, key, value)
+	return nil
+}
+
+func (a *typedAdapter[V]) SaveWithTTL(ctx context.Context, key string, value V, ttl time.Duration) error {
+	a.spec.SaveWithTTL(ctx, key, value, ttl)
+	return nil
+}
+
+func (a *typedAdapter[V]) Delete(ctx context.Context, key string) error {
+	a.spec.Delete(ctx, key)
+	return nil
+}
+
+// As returns the value read from a Spec as a T. A value decoded by a JSONCodec, like the maps of NewRedisCache,
+// is converted to a T through JSON. ok is false if the value is nil or it can not be converted
+// Example:
+//
+//	_, value := spec.Get(ctx, "user-1")
+//	user, ok := cache.As[*User](value)
+func As[T any](value interface{}) (t T, ok bool) {
+	if t, ok = value.(T); ok || value == nil {
+		return t, ok
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return t, false
+	}
+	return t, json.Unmarshal(data, &t) == nil
+}
@@ -0,0 +1,111 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	Name string
+}
+
+// Test a typed memory cache returns the values without casts and hides the expired ones
+func TestTypedMemoryCache(t *testing.T) {
+	ctx := context.Background()
+	users := NewTypedMemoryCache[*user]("users", 10, time.Minute)
+
+	u, found, err := users.Get(ctx, "missing")
+	assert.Nil(t, err)
+	assert.False(t, found)
+	assert.Nil(t, u)
+
+	assert.Nil(t, users.Save(ctx, "1", &user{Name: "Ana"}))
+	u, found, _ = users.Get(ctx, "1")
+	assert.True(t, found)
+	assert.Equal(t, "Ana", u.Name)
+
+	assert.Nil(t, users.SaveWithTTL(ctx, "2", &user{Name: "Bob"}, 20*time.Millisecond))
+	time.Sleep(50 * time.Millisecond)
+	_, found, _ = users.Get(ctx, "2")
+	assert.False(t, found)
+	u, expired, found := users.GetStale(ctx, "2")
+	assert.True(t, found)
+	assert.True(t, expired)
+	assert.Equal(t, "Bob", u.Name)
+
+	// a ttl of 0 never expires, like in RedisCache
+	assert.Nil(t, users.SaveWithTTL(ctx, "3", &user{Name: "Eve"}, 0))
+	time.Sleep(10 * time.Millisecond)
+	_, found, _ = users.Get(ctx, "3")
+	assert.True(t, found)
+
+	assert.Nil(t, users.Delete(ctx, "1"))
+	_, found, _ = users.Get(ctx, "1")
+	assert.False(t, found)
+}
+
+// Test the adapters between Spec and Typed
+func TestAdapters(t *testing.T) {
+	ctx := context.Background()
+	spec := AsSpec[*user](NewTypedMemoryCache[*user]("users", 10, time.Minute))
+
+	spec.Save(ctx, "1", &user{Name: "Ana"})
+	expired, value := spec.Get(ctx, "1")
+	assert.False(t, expired)
+	assert.Equal(t, &user{Name: "Ana"}, value)
+
+	spec.Save(ctx, "2", "not a user")
+	_, value = spec.Get(ctx, "2")
+	assert.Nil(t, value)
+
+	typed := AsTyped[string](NewMemoryCache("strings", 10, time.Minute, false))
+	assert.Nil(t, typed.Save(ctx, "1", "one"))
+	s, found, err := typed.Get(ctx, "1")
+	assert.Nil(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "one", s)
+
+	_, found, err = AsTyped[int](spec).Get(ctx, "1")
+	assert.False(t, found)
+	assert.ErrorContains(t, err, "has a *cache.user, not a int")
+}
+
+// Test SaveIfAbsent only saves the keys missing or expired, and only one of the concurrent callers wins
+func TestMemorySaveIfAbsent(t *testing.T) {
+	ctx := context.Background()
+	spec, ok := NewMemoryCache("locks", 10, time.Minute, true).(AtomicSpec)
+	assert.True(t, ok)
+
+	saved, err := spec.SaveIfAbsent(ctx, "1", "a", 20*time.Millisecond)
+	assert.Nil(t, err)
+	assert.True(t, saved)
+	saved, _ = spec.SaveIfAbsent(ctx, "1", "b", time.Minute)
+	assert.False(t, saved)
+	time.Sleep(50 * time.Millisecond)
+	saved, _ = spec.SaveIfAbsent(ctx, "1", "c", time.Minute)
+	assert.True(t, saved)
+	_, value := spec.Get(ctx, "1")
+	assert.Equal(t, "c", value)
+
+	var wins int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if saved, _ := spec.SaveIfAbsent(ctx, "2", i, time.Minute); saved {
+				atomic.AddInt32(&wins, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), wins)
+
+	_, ok = AsSpec[string](AsTyped[string](spec)).(AtomicSpec)
+	assert.False(t, ok)
+}
@@ -0,0 +1,533 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// reserved are the names declared by the generated runtime
+var reserved = []string{"Client", "NewClient", "Mock", "NewMock", "StatusError"}
+
+var pathParam = regexp.MustCompile(`\{([^}]+)\}`)
+
+type generator struct {
+	spec   *Spec
+	pkg    string
+	source string
+	// decls are the type declarations, in the order they are generated
+	decls []string
+	// used are the type names already declared
+	used map[string]bool
+	// components maps the names of the component schemas to their Go names
+	components map[string]string
+	// valueTypes are the component types whose zero value already means absent, like the slices
+	valueTypes map[string]bool
+	warnings   []string
+}
+
+// operation is an operation of the spec with its Go types resolved
+type operation struct {
+	Name        string
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Deprecated  bool
+	Params      []param
+	// BodyType is the type of the JSON request body, empty if the operation has none
+	BodyType string
+	// Result is the type decoded from the 2xx response, empty if it has no JSON body
+	Result    string
+	Responses []response
+}
+
+type param struct {
+	Name     string
+	In       string
+	Field    string
+	Type     string
+	Required bool
+	Pointer  bool
+	Doc      string
+}
+
+type response struct {
+	// Status is an exact status like 404, a range like 4XX or default
+	Status string
+	// Type is the type of the JSON body, empty if the response has none
+	Type string
+}
+
+func (r response) exact() bool {
+	return r.Status != "default" && !strings.Contains(r.Status, "X")
+}
+
+func (r response) success() bool {
+	return r.Status[0] == '2'
+}
+
+// condition returns the Go condition on res.StatusCode matching the status
+func (r response) condition() string {
+	if r.exact() {
+		return "res.StatusCode == " + r.Status
+	}
+	return fmt.Sprintf("res.StatusCode/100 == %c", r.Status[0])
+}
+
+func (r response) methodSuffix() string {
+	if r.Status == "default" {
+		return "Default"
+	}
+	return r.Status
+}
+
+func newGenerator(spec *Spec, pkg, source string) *generator {
+	return &generator{
+		spec:       spec,
+		pkg:        pkg,
+		source:     source,
+		used:       make(map[string]bool),
+		components: make(map[string]string),
+		valueTypes: make(map[string]bool),
+	}
+}
+
+// generate returns the source of the client and of the mock helpers
+func (g *generator) generate() (client, mock []byte, err error) {
+	for _, name := range reserved {
+		g.used[name] = true
+	}
+	names := sortedKeys(g.spec.Components.Schemas)
+	for _, name := range names {
+		goName := goName(name)
+		if g.used[goName] {
+			return nil, nil, fmt.Errorf("schema %q: the name %s is already used", name, goName)
+		}
+		g.used[goName] = true
+		g.components[name] = goName
+		g.valueTypes[goName] = isValueSchema(g.spec.Components.Schemas[name])
+	}
+	for _, name := range names {
+		if err := g.declareComponent(name, g.spec.Components.Schemas[name]); err != nil {
+			return nil, nil, fmt.Errorf("schema %q: %w", name, err)
+		}
+	}
+
+	ops, err := g.operations()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(ops) == 0 {
+		return nil, nil, fmt.Errorf("the spec has no operations")
+	}
+
+	client, err = g.format(g.renderClient(ops))
+	if err != nil {
+		return nil, nil, err
+	}
+	mock, err = g.format(g.renderMock(ops))
+	if err != nil {
+		return nil, nil, err
+	}
+	return client, mock, nil
+}
+
+func (g *generator) format(src string) ([]byte, error) {
+	out, err := format.Source([]byte(src))
+	if err != nil {
+		return nil, fmt.Errorf("generated invalid code: %w\n%s", err, src)
+	}
+	return out, nil
+}
+
+func (g *generator) warn(format string, args ...interface{}) {
+	g.warnings = append(g.warnings, fmt.Sprintf(format, args...))
+}
+
+// newName reserves a type name based on hint, a number is appended if it is already used
+func (g *generator) newName(hint string) string {
+	name := hint
+	for i := 2; g.used[name]; i++ {
+		name = fmt.Sprintf("%s%d", hint, i)
+	}
+	g.used[name] = true
+	return name
+}
+
+func isStruct(s *Schema) bool {
+	return len(s.Properties) > 0 || len(s.AllOf) > 1 || len(s.AllOf) == 1 && s.AllOf[0].Ref == ""
+}
+
+func isEnum(s *Schema) bool {
+	return s.is("string") && len(s.Enum) > 0
+}
+
+// isValueSchema returns true for the schemas of slices, maps and raw JSON
+func isValueSchema(s *Schema) bool {
+	if isStruct(s) || isEnum(s) || s.Ref != "" || len(s.AllOf) > 0 {
+		return false
+	}
+	return s.is("array") || s.is("object") || len(s.OneOf) > 0 || len(s.AnyOf) > 0 || len(s.Type) == 0
+}
+
+// pointerable returns false for the types whose zero value already means absent
+func (g *generator) pointerable(t string) bool {
+	return !strings.HasPrefix(t, "[]") && !strings.HasPrefix(t, "map[") && t != "json.RawMessage" && t != "interface{}" &&
+		!g.valueTypes[t]
+}
+
+func (g *generator) declareComponent(name string, s *Schema) error {
+	goName := g.components[name]
+	switch {
+	case isStruct(s):
+		return g.declareStruct(goName, s)
+	case isEnum(s):
+		g.declareEnum(goName, s)
+		return nil
+	}
+	t, err := g.typeOf(s, goName)
+	if err != nil {
+		return err
+	}
+	g.decls = append(g.decls, docComment(goName, s.Description, s.Deprecated)+fmt.Sprintf("type %s %s\n", goName, t))
+	return nil
+}
+
+// typeOf returns the Go type of the schema. Inline objects and enums are declared with a name based on hint
+func (g *generator) typeOf(s *Schema, hint string) (string, error) {
+	switch {
+	case s == nil:
+		return "json.RawMessage", nil
+	case s.Ref != "":
+		name, _, err := g.spec.schema(s.Ref)
+		if err != nil {
+			return "", err
+		}
+		return g.components[name], nil
+	case len(s.AllOf) == 1 && len(s.Properties) == 0:
+		return g.typeOf(s.AllOf[0], hint)
+	case isStruct(s):
+		name := g.newName(hint)
+		return name, g.declareStruct(name, s)
+	case isEnum(s):
+		name := g.newName(hint)
+		g.declareEnum(name, s)
+		return name, nil
+	case len(s.OneOf) > 0 || len(s.AnyOf) > 0:
+		// the variants can't be told apart without a discriminator, the caller decodes the raw JSON
+		return "json.RawMessage", nil
+	case s.is("array"):
+		item, err := g.typeOf(s.Items, hint+"Item")
+		return "[]" + item, err
+	case s.is("object"):
+		if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+			value, err := g.typeOf(s.AdditionalProperties.Schema, hint+"Value")
+			return "map[string]" + value, err
+		}
+		return "map[string]interface{}", nil
+	case s.is("string"):
+		switch s.Format {
+		case "date-time":
+			return "time.Time", nil
+		case "byte":
+			return "[]byte", nil
+		}
+		return "string", nil
+	case s.is("integer"):
+		switch s.Format {
+		case "int32":
+			return "int32", nil
+		case "int64":
+			return "int64", nil
+		}
+		return "int", nil
+	case s.is("number"):
+		if s.Format == "float" {
+			return "float32", nil
+		}
+		return "float64", nil
+	case s.is("boolean"):
+		return "bool", nil
+	}
+	return "json.RawMessage", nil
+}
+
+func (g *generator) declareStruct(name string, s *Schema) error {
+	var b strings.Builder
+	b.WriteString(docComment(name, s.Description, s.Deprecated))
+	fmt.Fprintf(&b, "type %s struct {\n", name)
+	if err := g.writeFields(&b, name, s); err != nil {
+		return err
+	}
+	b.WriteString("}\n")
+	g.decls = append(g.decls, b.String())
+	return nil
+}
+
+// writeFields writes the properties of s. The referenced schemas of allOf are embedded and the inline ones merged
+func (g *generator) writeFields(b *strings.Builder, name string, s *Schema) error {
+	for _, sub := range s.AllOf {
+		if sub.Ref != "" {
+			t, err := g.typeOf(sub, name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(b, "%s\n", t)
+			continue
+		}
+		if err := g.writeFields(b, name, sub); err != nil {
+			return err
+		}
+	}
+	for _, prop := range sortedKeys(s.Properties) {
+		p := s.Properties[prop]
+		field := goName(prop)
+		t, err := g.typeOf(p, name+field)
+		if err != nil {
+			return fmt.Errorf("property %q: %w", prop, err)
+		}
+		required := s.required(prop)
+		if (!required || p.nullable()) && g.pointerable(t) {
+			t = "*" + t
+		}
+		tag := prop
+		if !required {
+			tag += ",omitempty"
+		}
+		if p.Ref == "" {
+			b.WriteString(comment(p.Description))
+			if p.Deprecated {
+				if p.Description != "" {
+					b.WriteString("//\n")
+				}
+				b.WriteString("// Deprecated: the API may remove it\n")
+			}
+		}
+		fmt.Fprintf(b, "%s %s `json:%q`\n", field, t, tag)
+	}
+	return nil
+}
+
+func (g *generator) declareEnum(name string, s *Schema) {
+	var b strings.Builder
+	b.WriteString(docComment(name, s.Description, s.Deprecated))
+	fmt.Fprintf(&b, "type %s string\n\nconst (\n", name)
+	for _, v := range s.Enum {
+		value, ok := v.(string)
+		if !ok {
+			continue
+		}
+		constant := name + "Empty"
+		if value != "" {
+			constant = name + goName(value)
+		}
+		fmt.Fprintf(&b, "%s %s = %q\n", g.newName(constant), name, value)
+	}
+	b.WriteString(")\n")
+	g.decls = append(g.decls, b.String())
+}
+
+// docComment returns the comment of a declaration, empty if there is nothing to say
+func docComment(name, description string, deprecated bool) string {
+	var text string
+	if description != "" {
+		text = name + " " + description
+	}
+	if deprecated {
+		if text != "" {
+			text += "\n\n"
+		}
+		text += "Deprecated: the API may remove it"
+	}
+	return comment(text)
+}
+
+// operations returns the operations of the spec sorted by path and method
+func (g *generator) operations() ([]*operation, error) {
+	seen := make(map[string]string)
+	var ops []*operation
+	for _, path := range sortedKeys(g.spec.Paths) {
+		item := g.spec.Paths[path]
+		for _, mo := range item.operations() {
+			op, err := g.operation(path, item, mo)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", mo.Method, path, err)
+			}
+			if op == nil {
+				continue
+			}
+			if other, ok := seen[op.Name]; ok {
+				return nil, fmt.Errorf("%s %s: the operation name %s is already used by %s", mo.Method, path, op.Name, other)
+			}
+			seen[op.Name] = mo.Method + " " + path
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+func (g *generator) operation(path string, item *PathItem, mo methodOperation) (*operation, error) {
+	op := &operation{
+		Name:        goName(mo.OperationID),
+		Method:      mo.Method,
+		Path:        path,
+		Summary:     mo.Summary,
+		Description: mo.Description,
+		Deprecated:  mo.Deprecated,
+	}
+	if mo.OperationID == "" {
+		op.Name = goName(strings.ToLower(mo.Method) + " " + path)
+	}
+
+	if mo.RequestBody != nil {
+		body, err := g.spec.requestBody(mo.RequestBody)
+		if err != nil {
+			return nil, err
+		}
+		schema := jsonSchema(body.Content)
+		if schema == nil {
+			g.warn("%s %s: skipped, only JSON request bodies are supported", mo.Method, path)
+			return nil, nil
+		}
+		if op.BodyType, err = g.typeOf(schema, op.Name+"Request"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := g.params(op, append(append([]*Parameter{}, item.Parameters...), mo.Parameters...)); err != nil {
+		return nil, err
+	}
+	for _, m := range pathParam.FindAllStringSubmatch(path, -1) {
+		if op.param("path", m[1]) == nil {
+			return nil, fmt.Errorf("path param %q is not defined", m[1])
+		}
+	}
+
+	statuses := sortedKeys(mo.Responses)
+	sort.SliceStable(statuses, func(i, j int) bool { return statusRank(statuses[i]) < statusRank(statuses[j]) })
+	for _, status := range statuses {
+		res, err := g.spec.response(mo.Responses[status])
+		if err != nil {
+			return nil, err
+		}
+		r := response{Status: strings.ToUpper(status)}
+		if status == "default" {
+			r.Status = status
+		}
+		if schema := jsonSchema(res.Content); schema != nil {
+			hint := op.Name + r.methodSuffix() + "Response"
+			if r.success() && !op.hasSuccess() {
+				hint = op.Name + "Response"
+			}
+			if r.Type, err = g.typeOf(schema, hint); err != nil {
+				return nil, err
+			}
+		}
+		// the method returns the body of the first 2xx response
+		if r.success() && !op.hasSuccess() {
+			op.Result = r.Type
+		}
+		op.Responses = append(op.Responses, r)
+	}
+	return op, nil
+}
+
+// params resolves the params of the operation, the ones of the operation override the ones of the path
+func (g *generator) params(op *operation, params []*Parameter) error {
+	byKey := make(map[string]*Parameter)
+	var keys []string
+	for _, p := range params {
+		resolved, err := g.spec.parameter(p)
+		if err != nil {
+			return err
+		}
+		key := resolved.In + ":" + resolved.Name
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = resolved
+	}
+	for _, key := range keys {
+		p := byKey[key]
+		if p.In != "path" && p.In != "query" && p.In != "header" {
+			g.warn("%s %s: %s param %q is not supported", op.Method, op.Path, p.In, p.Name)
+			continue
+		}
+		field := goName(p.Name)
+		t, err := g.typeOf(p.Schema, op.Name+"Params"+field)
+		if err != nil {
+			return fmt.Errorf("param %q: %w", p.Name, err)
+		}
+		required := p.Required || p.In == "path"
+		op.Params = append(op.Params, param{
+			Name:     p.Name,
+			In:       p.In,
+			Field:    field,
+			Type:     t,
+			Required: required,
+			Pointer:  !required && g.pointerable(t),
+			Doc:      p.Description,
+		})
+	}
+	return nil
+}
+
+func (op *operation) param(in, name string) *param {
+	for i := range op.Params {
+		if op.Params[i].In == in && op.Params[i].Name == name {
+			return &op.Params[i]
+		}
+	}
+	return nil
+}
+
+func (op *operation) hasSuccess() bool {
+	for _, r := range op.Responses {
+		if r.success() {
+			return true
+		}
+	}
+	return false
+}
+
+func (op *operation) errors() []response {
+	var out []response
+	for _, r := range op.Responses {
+		if !r.success() {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (op *operation) has(in string) bool {
+	for _, p := range op.Params {
+		if p.In == in {
+			return true
+		}
+	}
+	return false
+}
+
+// statusRank sorts the exact statuses first, then the ranges and default last
+func statusRank(status string) int {
+	switch {
+	case status == "default":
+		return 2
+	case strings.ContainsAny(status, "Xx"):
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test the generated petstore package is up to date, run go generate ./cmd/restgen/... after changing the generator
+func TestGoldenPetstore(t *testing.T) {
+	out := t.TempDir()
+	assert.Nil(t, run("testdata/petstore.yaml", "petstore", out))
+	for _, name := range []string{"client_gen.go", "mock_gen.go"} {
+		want, err := os.ReadFile(filepath.Join("internal/petstore", name))
+		assert.Nil(t, err)
+		got, err := os.ReadFile(filepath.Join(out, name))
+		assert.Nil(t, err)
+		assert.Equal(t, string(want), string(got), name)
+	}
+}
+
+// Test the identifiers of the spec are converted to Go names
+func TestGoName(t *testing.T) {
+	cases := map[string]string{
+		"pet_id":         "PetID",
+		"petId":          "PetID",
+		"X-Request-ID":   "XRequestID",
+		"HTTPServer":     "HTTPServer",
+		"get /pets/{id}": "GetPetsID",
+		"2fa":            "N2fa",
+		"":               "X",
+	}
+	for in, want := range cases {
+		assert.Equal(t, want, goName(in), in)
+	}
+	assert.Equal(t, "getPet", unexported("GetPet"))
+	assert.Equal(t, "httpCheck", unexported("HTTPCheck"))
+	assert.Equal(t, "id", unexported("ID"))
+}
+
+// Test specs in JSON, OpenAPI 3.1 types and the invalid specs
+func TestGenerateErrors(t *testing.T) {
+	spec, err := parseSpec([]byte(`{"openapi":"3.1.0","paths":{"/users/{id}":{"get":{"operationId":"getUser",
+		"parameters":[{"name":"id","in":"path","required":true,"schema":{"type":"string"}}],
+		"responses":{"200":{"description":"ok","content":{"application/json":{"schema":{"type":"object",
+		"properties":{"nick":{"type":["string","null"]}},"required":["nick"]}}}}}}}}}`))
+	assert.Nil(t, err)
+	client, mock, err := newGenerator(spec, "users", "users.json").generate()
+	assert.Nil(t, err)
+	assert.Contains(t, string(client), "Nick *string `json:\"nick\"`")
+	assert.Contains(t, string(mock), "func (m *Mock) ExpectGetUser(params *GetUserParams")
+
+	_, err = parseSpec([]byte(`swagger: "2.0"`))
+	assert.ErrorContains(t, err, "only 3.x is supported")
+
+	spec, _ = parseSpec([]byte(`{"openapi":"3.0.0","paths":{"/users/{id}":{"get":{"responses":{}}}}}`))
+	_, _, err = newGenerator(spec, "users", "users.json").generate()
+	assert.ErrorContains(t, err, `path param "id" is not defined`)
+
+	spec, _ = parseSpec([]byte(`{"openapi":"3.0.0","paths":{"/users":{"get":{"responses":{"200":{"description":"ok",
+		"content":{"application/json":{"schema":{"$ref":"other.yaml#/User"}}}}}}}}}`))
+	_, _, err = newGenerator(spec, "users", "users.json").generate()
+	assert.ErrorContains(t, err, "unsupported reference")
+}
@@ -0,0 +1,308 @@
+// Code generated by restgen from petstore.yaml. DO NOT EDIT.
+
+package petstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// Client is a typed client of the Petstore 1.0.0 API
+type Client struct {
+	client rest.Client
+}
+
+// NewClient returns a client that sends the requests with client, its base URL must be the server of the API.
+// Use a rest.MockClient and NewMock in the tests
+func NewClient(client rest.Client) *Client {
+	return &Client{client: client}
+}
+
+// StatusError is returned when the API answers with an error status. Body is the response of the spec for the status,
+// json.RawMessage when the spec has none or the body does not match it
+// Example:
+//
+//	var notFound *petstore.StatusError[petstore.Error]
+//	if errors.As(err, &notFound) {
+//		log.Print(notFound.StatusCode, notFound.Body)
+//	}
+type StatusError[T any] struct {
+	StatusCode int
+	Body       T
+	Response   *rest.Response
+}
+
+func (e *StatusError[T]) Error() string {
+	return fmt.Sprintf("petstore: unexpected status %d: %s", e.StatusCode, e.Response.BodyBytes)
+}
+
+func newStatusError[T any](res *rest.Response) error {
+	e := &StatusError[T]{StatusCode: res.StatusCode, Response: res}
+	if err := json.Unmarshal(res.BodyBytes, &e.Body); err != nil {
+		return unexpectedStatus(res)
+	}
+	return e
+}
+
+func unexpectedStatus(res *rest.Response) error {
+	return &StatusError[json.RawMessage]{StatusCode: res.StatusCode, Body: res.BodyBytes, Response: res}
+}
+
+// send does the request and decodes the body of a 2xx response into out, errorFor returns the error of the other statuses
+func send(req *rest.Request, out interface{}, errorFor func(res *rest.Response) error) error {
+	res := req.Do()
+	switch {
+	case res == nil:
+		return errors.New("petstore: invalid request")
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		if res.Error != nil {
+			return res.Error
+		}
+		if out == nil || len(res.BodyBytes) == 0 {
+			return nil
+		}
+		return res.MapTo(out)
+	case res.StatusCode >= 800, res.Error != nil && len(res.BodyBytes) == 0:
+		// the request failed before the API answered
+		return res.Error
+	}
+	return errorFor(res)
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// formatParam formats a path, query or header param
+func formatParam(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+type Error struct {
+	Code    int32  `json:"code"`
+	Message string `json:"message"`
+}
+
+type NewPet struct {
+	Attributes map[string]string `json:"attributes,omitempty"`
+	Name       string            `json:"name"`
+	Status     *PetStatus        `json:"status,omitempty"`
+	Tag        *string           `json:"tag,omitempty"`
+}
+
+// Pet is a pet of the store
+type Pet struct {
+	NewPet
+	CreatedAt time.Time `json:"createdAt"`
+	ID        int64     `json:"id"`
+	// the id of the owner, null if the pet was not adopted
+	Owner     *string  `json:"owner,omitempty"`
+	PhotoUrls []string `json:"photoUrls,omitempty"`
+	// the legacy tag of the pet, replaced by the attributes
+	//
+	// Deprecated: the API may remove it
+	Tag *string `json:"tag,omitempty"`
+}
+
+type PetStatus string
+
+const (
+	PetStatusAvailable PetStatus = "available"
+	PetStatusPending   PetStatus = "pending"
+	PetStatusSold      PetStatus = "sold"
+)
+
+type Pets []Pet
+
+type GetHealthResponseStatus string
+
+const (
+	GetHealthResponseStatusOk       GetHealthResponseStatus = "ok"
+	GetHealthResponseStatusDegraded GetHealthResponseStatus = "degraded"
+)
+
+type GetHealthResponse struct {
+	CheckedAt *time.Time               `json:"checkedAt,omitempty"`
+	Status    *GetHealthResponseStatus `json:"status,omitempty"`
+}
+
+type CreatePet422ResponseErrorsItem struct {
+	Field   string  `json:"field"`
+	Message *string `json:"message,omitempty"`
+}
+
+type CreatePet422Response struct {
+	Errors []CreatePet422ResponseErrorsItem `json:"errors,omitempty"`
+	Title  *string                          `json:"title,omitempty"`
+}
+
+// GetHealth calls GET /health
+func (c *Client) GetHealth(ctx context.Context) (*GetHealthResponse, error) {
+	path, query := getHealthPath()
+	req := c.client.Request("GET", withQuery(path, query), nil).WithContext(ctx)
+	var out GetHealthResponse
+	if err := send(req, &out, unexpectedStatus); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getHealthPath returns the path and the query of a GetHealth request
+func getHealthPath() (string, url.Values) {
+	return "/health", nil
+}
+
+// ListPetsParams are the params of ListPets
+type ListPetsParams struct {
+	// max number of pets
+	Limit  *int32     // query param limit
+	Tags   []string   // query param tags
+	Status *PetStatus // query param status
+}
+
+// ListPets lists the pets of the store
+//
+//	GET /pets
+func (c *Client) ListPets(ctx context.Context, params *ListPetsParams) (Pets, error) {
+	path, query := listPetsPath(params)
+	req := c.client.Request("GET", withQuery(path, query), nil).WithContext(ctx)
+	var out Pets
+	if err := send(req, &out, listPetsError); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// listPetsPath returns the path and the query of a ListPets request
+func listPetsPath(params *ListPetsParams) (string, url.Values) {
+	if params == nil {
+		params = &ListPetsParams{}
+	}
+	query := url.Values{}
+	if params.Limit != nil {
+		query.Set("limit", formatParam(*params.Limit))
+	}
+	for _, v := range params.Tags {
+		query.Add("tags", formatParam(v))
+	}
+	if params.Status != nil {
+		query.Set("status", formatParam(*params.Status))
+	}
+	return "/pets", query
+}
+
+// listPetsError returns the error of a ListPets response with an error status
+func listPetsError(res *rest.Response) error {
+	return newStatusError[Error](res)
+}
+
+// CreatePet adds a pet to the store
+//
+//	POST /pets
+func (c *Client) CreatePet(ctx context.Context, body NewPet) (*Pet, error) {
+	path, query := createPetPath()
+	req := c.client.Request("POST", withQuery(path, query), body).WithContext(ctx)
+	var out Pet
+	if err := send(req, &out, createPetError); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// createPetPath returns the path and the query of a CreatePet request
+func createPetPath() (string, url.Values) {
+	return "/pets", nil
+}
+
+// createPetError returns the error of a CreatePet response with an error status
+func createPetError(res *rest.Response) error {
+	switch {
+	case res.StatusCode == 422:
+		return newStatusError[CreatePet422Response](res)
+	}
+	return unexpectedStatus(res)
+}
+
+// GetPetParams are the params of GetPet
+type GetPetParams struct {
+	PetID      int64   // path param petId
+	XRequestID *string // header param X-Request-ID
+}
+
+// GetPet returns a pet by id
+//
+//	GET /pets/{petId}
+func (c *Client) GetPet(ctx context.Context, params *GetPetParams) (*Pet, error) {
+	path, query := getPetPath(params)
+	req := c.client.Request("GET", withQuery(path, query), nil).WithContext(ctx)
+	if params != nil {
+		if params.XRequestID != nil {
+			req.WithHeader("X-Request-ID", formatParam(*params.XRequestID))
+		}
+	}
+	var out Pet
+	if err := send(req, &out, getPetError); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// getPetPath returns the path and the query of a GetPet request
+func getPetPath(params *GetPetParams) (string, url.Values) {
+	if params == nil {
+		params = &GetPetParams{}
+	}
+	return "/pets/" + url.PathEscape(formatParam(params.PetID)), nil
+}
+
+// getPetError returns the error of a GetPet response with an error status
+func getPetError(res *rest.Response) error {
+	switch {
+	case res.StatusCode == 404:
+		return newStatusError[Error](res)
+	}
+	return unexpectedStatus(res)
+}
+
+// DeletePetParams are the params of DeletePet
+type DeletePetParams struct {
+	PetID int64 // path param petId
+}
+
+// DeletePet calls DELETE /pets/{petId}
+//
+// Deprecated: the API may remove it
+func (c *Client) DeletePet(ctx context.Context, params *DeletePetParams) error {
+	path, query := deletePetPath(params)
+	req := c.client.Request("DELETE", withQuery(path, query), nil).WithContext(ctx)
+	return send(req, nil, deletePetError)
+}
+
+// deletePetPath returns the path and the query of a DeletePet request
+func deletePetPath(params *DeletePetParams) (string, url.Values) {
+	if params == nil {
+		params = &DeletePetParams{}
+	}
+	return "/pets/" + url.PathEscape(formatParam(params.PetID)), nil
+}
+
+// deletePetError returns the error of a DeletePet response with an error status
+func deletePetError(res *rest.Response) error {
+	switch {
+	case res.StatusCode/100 == 4:
+		return newStatusError[Error](res)
+	}
+	return unexpectedStatus(res)
+}
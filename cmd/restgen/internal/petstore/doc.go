@@ -0,0 +1,5 @@
+// Package petstore is the client generated from testdata/petstore.yaml. It is an example of the generated code
+// and the golden file of the generator tests.
+package petstore
+
+//go:generate go run ../.. -spec ../../testdata/petstore.yaml -package petstore -out .
@@ -0,0 +1,164 @@
+// Code generated by restgen from petstore.yaml. DO NOT EDIT.
+
+package petstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// Mock sets typed expectations of the petstore operations on a rest.MockClient
+// Example:
+//
+//	mockClient := rest.NewDefaultMockClient(t)
+//	petstore.NewMock(mockClient).ExpectOperation(params).Respond200(body)
+//	client := petstore.NewClient(mockClient)
+type Mock struct {
+	client *rest.MockClient
+}
+
+func NewMock(client *rest.MockClient) *Mock {
+	return &Mock{client: client}
+}
+
+// requestMatchers matches the path and the query params of a request
+func requestMatchers(path string, query url.Values) []rest.Matcher {
+	matchers := []rest.Matcher{rest.MatchURL(path)}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range query[key] {
+			matchers = append(matchers, rest.MatchQuery(key, value))
+		}
+	}
+	return matchers
+}
+
+func jsonResponse(status int, body interface{}) rest.MockResponse {
+	b, err := json.Marshal(body)
+	if err != nil {
+		panic("petstore: invalid mock response: " + err.Error())
+	}
+	return rest.MockResponse{StatusCode: status, JSONBody: string(b), Headers: http.Header{"Content-Type": {"application/json"}}}
+}
+
+// GetHealthExpectation is an expected GetHealth call
+type GetHealthExpectation struct {
+	*rest.Expectation
+}
+
+// ExpectGetHealth expects a GetHealth call.
+// More matchers, like rest.MatchJSONBody, can be added
+func (m *Mock) ExpectGetHealth(matchers ...rest.Matcher) *GetHealthExpectation {
+	path, query := getHealthPath()
+	return &GetHealthExpectation{m.client.Expect("GET", append(requestMatchers(path, query), matchers...)...)}
+}
+
+// Respond200 appends a 200 response to the sequence of the expectation
+func (e *GetHealthExpectation) Respond200(body GetHealthResponse) *GetHealthExpectation {
+	e.Respond(jsonResponse(200, body))
+	return e
+}
+
+// ListPetsExpectation is an expected ListPets call
+type ListPetsExpectation struct {
+	*rest.Expectation
+}
+
+// ExpectListPets expects a ListPets call with the path params and the query params set in params.
+// More matchers, like rest.MatchJSONBody, can be added
+func (m *Mock) ExpectListPets(params *ListPetsParams, matchers ...rest.Matcher) *ListPetsExpectation {
+	path, query := listPetsPath(params)
+	return &ListPetsExpectation{m.client.Expect("GET", append(requestMatchers(path, query), matchers...)...)}
+}
+
+// Respond200 appends a 200 response to the sequence of the expectation
+func (e *ListPetsExpectation) Respond200(body Pets) *ListPetsExpectation {
+	e.Respond(jsonResponse(200, body))
+	return e
+}
+
+// RespondDefault appends a default response to the sequence of the expectation
+func (e *ListPetsExpectation) RespondDefault(status int, body Error) *ListPetsExpectation {
+	e.Respond(jsonResponse(status, body))
+	return e
+}
+
+// CreatePetExpectation is an expected CreatePet call
+type CreatePetExpectation struct {
+	*rest.Expectation
+}
+
+// ExpectCreatePet expects a CreatePet call.
+// More matchers, like rest.MatchJSONBody, can be added
+func (m *Mock) ExpectCreatePet(matchers ...rest.Matcher) *CreatePetExpectation {
+	path, query := createPetPath()
+	return &CreatePetExpectation{m.client.Expect("POST", append(requestMatchers(path, query), matchers...)...)}
+}
+
+// Respond201 appends a 201 response to the sequence of the expectation
+func (e *CreatePetExpectation) Respond201(body Pet) *CreatePetExpectation {
+	e.Respond(jsonResponse(201, body))
+	return e
+}
+
+// Respond422 appends a 422 response to the sequence of the expectation
+func (e *CreatePetExpectation) Respond422(body CreatePet422Response) *CreatePetExpectation {
+	e.Respond(jsonResponse(422, body))
+	return e
+}
+
+// GetPetExpectation is an expected GetPet call
+type GetPetExpectation struct {
+	*rest.Expectation
+}
+
+// ExpectGetPet expects a GetPet call with the path params and the query params set in params.
+// More matchers, like rest.MatchJSONBody, can be added
+func (m *Mock) ExpectGetPet(params *GetPetParams, matchers ...rest.Matcher) *GetPetExpectation {
+	path, query := getPetPath(params)
+	return &GetPetExpectation{m.client.Expect("GET", append(requestMatchers(path, query), matchers...)...)}
+}
+
+// Respond200 appends a 200 response to the sequence of the expectation
+func (e *GetPetExpectation) Respond200(body Pet) *GetPetExpectation {
+	e.Respond(jsonResponse(200, body))
+	return e
+}
+
+// Respond404 appends a 404 response to the sequence of the expectation
+func (e *GetPetExpectation) Respond404(body Error) *GetPetExpectation {
+	e.Respond(jsonResponse(404, body))
+	return e
+}
+
+// DeletePetExpectation is an expected DeletePet call
+type DeletePetExpectation struct {
+	*rest.Expectation
+}
+
+// ExpectDeletePet expects a DeletePet call with the path params and the query params set in params.
+// More matchers, like rest.MatchJSONBody, can be added
+func (m *Mock) ExpectDeletePet(params *DeletePetParams, matchers ...rest.Matcher) *DeletePetExpectation {
+	path, query := deletePetPath(params)
+	return &DeletePetExpectation{m.client.Expect("DELETE", append(requestMatchers(path, query), matchers...)...)}
+}
+
+// Respond204 appends a 204 response to the sequence of the expectation
+func (e *DeletePetExpectation) Respond204() *DeletePetExpectation {
+	e.Respond(rest.MockResponse{StatusCode: 204})
+	return e
+}
+
+// Respond4XX appends a 4XX response to the sequence of the expectation
+func (e *DeletePetExpectation) Respond4XX(status int, body Error) *DeletePetExpectation {
+	e.Respond(jsonResponse(status, body))
+	return e
+}
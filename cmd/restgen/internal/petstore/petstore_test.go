@@ -0,0 +1,106 @@
+package petstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// Test the typed expectations of the mock
+func TestMock(t *testing.T) {
+	mockClient := rest.NewDefaultMockClient(t)
+	mock := NewMock(mockClient)
+	client := NewClient(mockClient)
+	ctx := context.Background()
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	mock.ExpectGetPet(&GetPetParams{PetID: 1}).Respond200(Pet{NewPet: NewPet{Name: "Rex"}, ID: 1, CreatedAt: created})
+	mock.ExpectGetPet(&GetPetParams{PetID: 2}).Respond404(Error{Code: 404, Message: "not found"})
+	limit := int32(10)
+	mock.ExpectListPets(&ListPetsParams{Limit: &limit}).Respond200(Pets{{ID: 1}, {ID: 2}})
+	mock.ExpectCreatePet(rest.MatchJSONBody(`{"name":"Tom","status":"available"}`)).
+		Respond422(CreatePet422Response{Errors: []CreatePet422ResponseErrorsItem{{Field: "name"}}}).
+		Respond201(Pet{ID: 3}).
+		Times(2)
+	mock.ExpectDeletePet(&DeletePetParams{PetID: 3}).Respond204()
+
+	pet, err := client.GetPet(ctx, &GetPetParams{PetID: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, "Rex", pet.Name)
+	assert.Equal(t, created, pet.CreatedAt)
+
+	_, err = client.GetPet(ctx, &GetPetParams{PetID: 2})
+	var notFound *StatusError[Error]
+	if assert.True(t, errors.As(err, &notFound)) {
+		assert.Equal(t, 404, notFound.StatusCode)
+		assert.Equal(t, "not found", notFound.Body.Message)
+	}
+
+	pets, err := client.ListPets(ctx, &ListPetsParams{Limit: &limit, Tags: []string{"dog"}})
+	assert.Nil(t, err)
+	assert.Len(t, pets, 2)
+
+	status := PetStatusAvailable
+	_, err = client.CreatePet(ctx, NewPet{Name: "Tom", Status: &status})
+	var invalid *StatusError[CreatePet422Response]
+	if assert.True(t, errors.As(err, &invalid)) {
+		assert.Equal(t, "name", invalid.Body.Errors[0].Field)
+	}
+	pet, err = client.CreatePet(ctx, NewPet{Name: "Tom", Status: &status})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), pet.ID)
+
+	assert.Nil(t, client.DeletePet(ctx, &DeletePetParams{PetID: 3}))
+}
+
+// Test the requests sent to a server and the errors of the statuses that are not in the spec
+func TestClient(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		switch r.URL.Path {
+		case "/pets":
+			json.NewEncoder(w).Encode(Pets{{ID: 1}})
+		case "/pets/1":
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("no pets here"))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(Error{Code: 403, Message: "forbidden"})
+		}
+	}))
+	defer srv.Close()
+	client := NewClient(rest.NewCustomRestClient(rest.Config{BaseURL: srv.URL, TimeoutInMillis: 1000}))
+	ctx := context.Background()
+
+	status := PetStatusSold
+	pets, err := client.ListPets(ctx, &ListPetsParams{Tags: []string{"a", "b"}, Status: &status})
+	assert.Nil(t, err)
+	assert.Len(t, pets, 1)
+	assert.Equal(t, "status=sold&tags=a&tags=b", got.URL.RawQuery)
+
+	requestID := "req-1"
+	_, err = client.GetPet(ctx, &GetPetParams{PetID: 1, XRequestID: &requestID})
+	assert.Equal(t, "req-1", got.Header.Get("X-Request-ID"))
+	var unexpected *StatusError[json.RawMessage]
+	if assert.True(t, errors.As(err, &unexpected)) {
+		assert.Equal(t, 418, unexpected.StatusCode)
+		assert.Equal(t, "no pets here", string(unexpected.Body))
+	}
+
+	err = client.DeletePet(ctx, &DeletePetParams{PetID: 9})
+	var forbidden *StatusError[Error]
+	if assert.True(t, errors.As(err, &forbidden)) {
+		assert.Equal(t, "forbidden", forbidden.Body.Message)
+	}
+	assert.Equal(t, http.MethodDelete, got.Method)
+	assert.Equal(t, "/pets/9", got.URL.Path)
+}
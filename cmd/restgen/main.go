@@ -0,0 +1,67 @@
+// Command restgen generates a typed client of an API from its OpenAPI 3 spec, on top of rest.Client.
+//
+// It writes two files to the output directory:
+//   - client_gen.go: the schemas as Go types, a Client with a method per operation, the params of the operations
+//     and a StatusError with the typed error responses.
+//   - mock_gen.go: a Mock with typed expectations of each operation for a rest.MockClient.
+//
+// Usage:
+//
+//	restgen -spec petstore.yaml -package petstore -out ./petstore
+//
+// Or in a go:generate directive:
+//
+//	//go:generate go run github.com/abraham-corales/go-lib/cmd/restgen -spec api.yaml -package partner
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "path of the OpenAPI 3 spec, in YAML or JSON")
+	pkg := flag.String("package", "", "package of the generated code. Defaults to the name of the output directory")
+	out := flag.String("out", ".", "output directory")
+	flag.Parse()
+
+	if err := run(*specPath, *pkg, *out); err != nil {
+		fmt.Fprintln(os.Stderr, "restgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(specPath, pkg, out string) error {
+	if specPath == "" {
+		return fmt.Errorf("-spec is required")
+	}
+	if pkg == "" {
+		abs, err := filepath.Abs(out)
+		if err != nil {
+			return err
+		}
+		pkg = filepath.Base(abs)
+	}
+	spec, err := loadSpec(specPath)
+	if err != nil {
+		return err
+	}
+
+	g := newGenerator(spec, pkg, filepath.Base(specPath))
+	client, mock, err := g.generate()
+	if err != nil {
+		return err
+	}
+	for _, w := range g.warnings {
+		fmt.Fprintln(os.Stderr, "restgen: warning:", w)
+	}
+	if err := os.MkdirAll(out, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(out, "client_gen.go"), client, 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(out, "mock_gen.go"), mock, 0o644)
+}
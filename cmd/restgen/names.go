@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// initialisms are written in upper case, like golint does
+var initialisms = map[string]bool{
+	"API": true, "DNS": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"JWT": true, "SQL": true, "SSH": true, "TLS": true, "TTL": true, "UI": true, "URI": true, "URL": true,
+	"UUID": true, "XML": true,
+}
+
+// words splits an identifier of the spec in words: pet_id, pet-id, petId and PetID are pet, id
+func words(s string) []string {
+	var out []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			out = append(out, string(current))
+			current = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := current[len(current)-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// a new word starts after a lower case letter, or at the last upper case letter of an acronym: HTTPServer
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return out
+}
+
+// goName returns the exported Go identifier of s: pet_id is PetID
+func goName(s string) string {
+	var b strings.Builder
+	for _, w := range words(s) {
+		upper := strings.ToUpper(w)
+		if initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		b.WriteString(strings.ToUpper(w[:1]) + strings.ToLower(w[1:]))
+	}
+	name := b.String()
+	if name == "" {
+		return "X"
+	}
+	if unicode.IsDigit(rune(name[0])) {
+		return "N" + name
+	}
+	return name
+}
+
+// unexported returns name with the first word in lower case: GetPet is getPet and HTTPCheck is httpCheck
+func unexported(name string) string {
+	for i, r := range name {
+		if i > 0 && unicode.IsLower(r) {
+			if i == 1 {
+				return strings.ToLower(name[:1]) + name[1:]
+			}
+			// the last upper case letter starts the next word
+			return strings.ToLower(name[:i-1]) + name[i-1:]
+		}
+	}
+	return strings.ToLower(name)
+}
+
+// comment returns text as a Go comment, each line prefixed with //
+func comment(text string) string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, " \t")
+		if line == "" {
+			b.WriteString("//\n")
+			continue
+		}
+		b.WriteString("// " + line + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const clientRuntime = `
+// Client is a typed client of the TITLE API
+type Client struct {
+	client rest.Client
+}
+
+// NewClient returns a client that sends the requests with client, its base URL must be the server of the API.
+// Use a rest.MockClient and NewMock in the tests
+func NewClient(client rest.Client) *Client {
+	return &Client{client: client}
+}
+
+// StatusError is returned when the API answers with an error status. Body is the response of the spec for the status,
+// json.RawMessage when the spec has none or the body does not match it
+// Example:
+//
+//	var notFound *PKG.StatusError[PKG.Error]
+//	if errors.As(err, &notFound) {
+//		log.Print(notFound.StatusCode, notFound.Body)
+//	}
+type StatusError[T any] struct {
+	StatusCode int
+	Body       T
+	Response   *rest.Response
+}
+
+func (e *StatusError[T]) Error() string {
+	return fmt.Sprintf("PKG: unexpected status %d: %s", e.StatusCode, e.Response.BodyBytes)
+}
+
+func newStatusError[T any](res *rest.Response) error {
+	e := &StatusError[T]{StatusCode: res.StatusCode, Response: res}
+	if err := json.Unmarshal(res.BodyBytes, &e.Body); err != nil {
+		return unexpectedStatus(res)
+	}
+	return e
+}
+
+func unexpectedStatus(res *rest.Response) error {
+	return &StatusError[json.RawMessage]{StatusCode: res.StatusCode, Body: res.BodyBytes, Response: res}
+}
+
+// send does the request and decodes the body of a 2xx response into out, errorFor returns the error of the other statuses
+func send(req *rest.Request, out interface{}, errorFor func(res *rest.Response) error) error {
+	res := req.Do()
+	switch {
+	case res == nil:
+		return errors.New("PKG: invalid request")
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		if res.Error != nil {
+			return res.Error
+		}
+		if out == nil || len(res.BodyBytes) == 0 {
+			return nil
+		}
+		return res.MapTo(out)
+	case res.StatusCode >= 800, res.Error != nil && len(res.BodyBytes) == 0:
+		// the request failed before the API answered
+		return res.Error
+	}
+	return errorFor(res)
+}
+
+func withQuery(path string, query url.Values) string {
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
+
+// formatParam formats a path, query or header param
+func formatParam(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+`
+
+const mockRuntime = `
+// Mock sets typed expectations of the PKG operations on a rest.MockClient
+// Example:
+//
+//	mockClient := rest.NewDefaultMockClient(t)
+//	PKG.NewMock(mockClient).ExpectOperation(params).Respond200(body)
+//	client := PKG.NewClient(mockClient)
+type Mock struct {
+	client *rest.MockClient
+}
+
+func NewMock(client *rest.MockClient) *Mock {
+	return &Mock{client: client}
+}
+
+// requestMatchers matches the path and the query params of a request
+func requestMatchers(path string, query url.Values) []rest.Matcher {
+	matchers := []rest.Matcher{rest.MatchURL(path)}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range query[key] {
+			matchers = append(matchers, rest.MatchQuery(key, value))
+		}
+	}
+	return matchers
+}
+
+func jsonResponse(status int, body interface{}) rest.MockResponse {
+	b, err := json.Marshal(body)
+	if err != nil {
+		panic("PKG: invalid mock response: " + err.Error())
+	}
+	return rest.MockResponse{StatusCode: status, JSONBody: string(b), Headers: http.Header{"Content-Type": {"application/json"}}}
+}
+`
+
+func (g *generator) header(imports ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Code generated by restgen from %s. DO NOT EDIT.\n\npackage %s\n\nimport (\n", g.source, g.pkg)
+	for _, imp := range imports {
+		if imp == "" {
+			b.WriteString("\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%q\n", imp)
+	}
+	b.WriteString(")\n")
+	return b.String()
+}
+
+func (g *generator) runtime(src string) string {
+	title := strings.TrimSpace(g.spec.Info.Title + " " + g.spec.Info.Version)
+	if title == "" {
+		title = g.pkg
+	}
+	return strings.NewReplacer("PKG", g.pkg, "TITLE", title).Replace(src)
+}
+
+func (g *generator) renderClient(ops []*operation) string {
+	var b strings.Builder
+	b.WriteString(g.header("context", "encoding/json", "errors", "fmt", "net/url", "time", "", "github.com/abraham-corales/go-lib/rest"))
+	b.WriteString(g.runtime(clientRuntime))
+	for _, decl := range g.decls {
+		b.WriteString("\n" + decl)
+	}
+	for _, op := range ops {
+		if len(op.Params) > 0 {
+			g.writeParams(&b, op)
+		}
+		g.writeMethod(&b, op)
+		g.writePath(&b, op)
+		if len(op.errors()) > 0 {
+			g.writeErrors(&b, op)
+		}
+	}
+	return b.String()
+}
+
+func (g *generator) writeParams(b *strings.Builder, op *operation) {
+	fmt.Fprintf(b, "\n// %sParams are the params of %s\ntype %sParams struct {\n", op.Name, op.Name, op.Name)
+	for _, p := range op.Params {
+		b.WriteString(comment(p.Doc))
+		t := p.Type
+		if p.Pointer {
+			t = "*" + t
+		}
+		fmt.Fprintf(b, "%s %s // %s param %s\n", p.Field, t, p.In, p.Name)
+	}
+	b.WriteString("}\n")
+}
+
+func (g *generator) writeMethod(b *strings.Builder, op *operation) {
+	doc := fmt.Sprintf("%s calls %s %s", op.Name, op.Method, op.Path)
+	if op.Summary != "" {
+		doc = fmt.Sprintf("%s %s\n\n\t%s %s", op.Name, op.Summary, op.Method, op.Path)
+	}
+	if op.Description != "" && op.Description != op.Summary {
+		doc += "\n\n" + op.Description
+	}
+	if op.Deprecated {
+		doc += "\n\nDeprecated: the API may remove it"
+	}
+	b.WriteString("\n" + comment(doc))
+
+	args := []string{"ctx context.Context"}
+	if len(op.Params) > 0 {
+		args = append(args, "params *"+op.Name+"Params")
+	}
+	if op.BodyType != "" {
+		args = append(args, "body "+op.BodyType)
+	}
+	result, ret := "error", "return send(req, nil, %s)\n"
+	if op.Result != "" {
+		t, out := op.Result, "out"
+		if g.pointerable(t) {
+			t, out = "*"+t, "&out"
+		}
+		result = "(" + t + ", error)"
+		ret = fmt.Sprintf("var out %s\nif err := send(req, &out, %%s); err != nil {\nreturn nil, err\n}\nreturn %s, nil\n", op.Result, out)
+	}
+	fmt.Fprintf(b, "func (c *Client) %s(%s) %s {\n", op.Name, strings.Join(args, ", "), result)
+
+	fmt.Fprintf(b, "path, query := %s\n", op.pathCall())
+	body := "nil"
+	if op.BodyType != "" {
+		body = "body"
+	}
+	fmt.Fprintf(b, "req := c.client.Request(%q, withQuery(path, query), %s).WithContext(ctx)\n", op.Method, body)
+	if op.has("header") {
+		b.WriteString("if params != nil {\n")
+		for _, p := range op.Params {
+			if p.In != "header" {
+				continue
+			}
+			if p.Pointer {
+				fmt.Fprintf(b, "if params.%s != nil {\nreq.WithHeader(%q, formatParam(*params.%s))\n}\n", p.Field, p.Name, p.Field)
+			} else {
+				fmt.Fprintf(b, "req.WithHeader(%q, formatParam(params.%s))\n", p.Name, p.Field)
+			}
+		}
+		b.WriteString("}\n")
+	}
+	errorFor := "unexpectedStatus"
+	if len(op.errors()) > 0 {
+		errorFor = unexported(op.Name) + "Error"
+	}
+	fmt.Fprintf(b, ret, errorFor)
+	b.WriteString("}\n")
+}
+
+func (op *operation) pathCall() string {
+	if len(op.Params) > 0 {
+		return unexported(op.Name) + "Path(params)"
+	}
+	return unexported(op.Name) + "Path()"
+}
+
+// writePath writes the function that builds the path and the query of the operation, shared by the client and the mock
+func (g *generator) writePath(b *strings.Builder, op *operation) {
+	fn := unexported(op.Name) + "Path"
+	fmt.Fprintf(b, "\n// %s returns the path and the query of a %s request\n", fn, op.Name)
+	if len(op.Params) == 0 {
+		fmt.Fprintf(b, "func %s() (string, url.Values) {\nreturn %q, nil\n}\n", fn, op.Path)
+		return
+	}
+	fmt.Fprintf(b, "func %s(params *%sParams) (string, url.Values) {\n", fn, op.Name)
+	if op.has("path") || op.has("query") {
+		fmt.Fprintf(b, "if params == nil {\nparams = &%sParams{}\n}\n", op.Name)
+	}
+
+	query := "nil"
+	if op.has("query") {
+		query = "query"
+		b.WriteString("query := url.Values{}\n")
+		for _, p := range op.Params {
+			if p.In != "query" {
+				continue
+			}
+			switch {
+			case strings.HasPrefix(p.Type, "[]") && p.Type != "[]byte":
+				fmt.Fprintf(b, "for _, v := range params.%s {\nquery.Add(%q, formatParam(v))\n}\n", p.Field, p.Name)
+			case p.Pointer:
+				fmt.Fprintf(b, "if params.%s != nil {\nquery.Set(%q, formatParam(*params.%s))\n}\n", p.Field, p.Name, p.Field)
+			default:
+				fmt.Fprintf(b, "query.Set(%q, formatParam(params.%s))\n", p.Name, p.Field)
+			}
+		}
+	}
+
+	var parts []string
+	last := 0
+	for _, loc := range pathParam.FindAllStringSubmatchIndex(op.Path, -1) {
+		if loc[0] > last {
+			parts = append(parts, fmt.Sprintf("%q", op.Path[last:loc[0]]))
+		}
+		p := op.param("path", op.Path[loc[2]:loc[3]])
+		parts = append(parts, fmt.Sprintf("url.PathEscape(formatParam(params.%s))", p.Field))
+		last = loc[1]
+	}
+	if last < len(op.Path) {
+		parts = append(parts, fmt.Sprintf("%q", op.Path[last:]))
+	}
+	fmt.Fprintf(b, "return %s, %s\n}\n", strings.Join(parts, " + "), query)
+}
+
+// writeErrors writes the function that decodes the error responses of the operation
+func (g *generator) writeErrors(b *strings.Builder, op *operation) {
+	fn := unexported(op.Name) + "Error"
+	fmt.Fprintf(b, "\n// %s returns the error of a %s response with an error status\n", fn, op.Name)
+	fmt.Fprintf(b, "func %s(res *rest.Response) error {\n", fn)
+	fallback := "unexpectedStatus(res)"
+	var cases []response
+	for _, r := range op.errors() {
+		if r.Status == "default" {
+			fallback = fmt.Sprintf("newStatusError[%s](res)", bodyType(r))
+			continue
+		}
+		cases = append(cases, r)
+	}
+	if len(cases) > 0 {
+		b.WriteString("switch {\n")
+		for _, r := range cases {
+			fmt.Fprintf(b, "case %s:\nreturn newStatusError[%s](res)\n", r.condition(), bodyType(r))
+		}
+		b.WriteString("}\n")
+	}
+	fmt.Fprintf(b, "return %s\n}\n", fallback)
+}
+
+func bodyType(r response) string {
+	if r.Type == "" {
+		return "json.RawMessage"
+	}
+	return r.Type
+}
+
+func (g *generator) renderMock(ops []*operation) string {
+	var b strings.Builder
+	b.WriteString(g.header("encoding/json", "net/http", "net/url", "sort", "", "github.com/abraham-corales/go-lib/rest"))
+	b.WriteString(g.runtime(mockRuntime))
+	for _, op := range ops {
+		exp := op.Name + "Expectation"
+		fmt.Fprintf(&b, "\n// %s is an expected %s call\ntype %s struct {\n*rest.Expectation\n}\n", exp, op.Name, exp)
+
+		fmt.Fprintf(&b, "\n// Expect%s expects a %s call", op.Name, op.Name)
+		args := "matchers ...rest.Matcher"
+		if len(op.Params) > 0 {
+			b.WriteString(" with the path params and the query params set in params")
+			args = "params *" + op.Name + "Params, " + args
+		}
+		b.WriteString(".\n// More matchers, like rest.MatchJSONBody, can be added\n")
+		fmt.Fprintf(&b, "func (m *Mock) Expect%s(%s) *%s {\n", op.Name, args, exp)
+		fmt.Fprintf(&b, "path, query := %s\n", op.pathCall())
+		fmt.Fprintf(&b, "return &%s{m.client.Expect(%q, append(requestMatchers(path, query), matchers...)...)}\n}\n", exp, op.Method)
+
+		for _, r := range op.Responses {
+			method := "Respond" + r.methodSuffix()
+			var params []string
+			status := r.Status
+			if !r.exact() {
+				params = append(params, "status int")
+				status = "status"
+			}
+			res := fmt.Sprintf("rest.MockResponse{StatusCode: %s}", status)
+			if r.Type != "" {
+				params = append(params, "body "+r.Type)
+				res = fmt.Sprintf("jsonResponse(%s, body)", status)
+			}
+			fmt.Fprintf(&b, "\n// %s appends a %s response to the sequence of the expectation\n", method, r.Status)
+			fmt.Fprintf(&b, "func (e *%s) %s(%s) *%s {\ne.Respond(%s)\nreturn e\n}\n", exp, method, strings.Join(params, ", "), exp, res)
+		}
+	}
+	return b.String()
+}
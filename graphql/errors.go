@@ -0,0 +1,80 @@
+package graphql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Location is a line and column of the query
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error is an entry of the errors of a GraphQL response
+type Error struct {
+	Message    string                 `json:"message"`
+	Locations  []Location             `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (path: %s)", e.Message, e.PathString())
+}
+
+// PathString returns the path as a dotted string, like user.friends.0.name
+func (e *Error) PathString() string {
+	parts := make([]string, len(e.Path))
+	for i, p := range e.Path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// Code returns extensions.code, the error code used by most servers, like UNAUTHENTICATED
+func (e *Error) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+// Errors are the errors of a GraphQL response. The data of the fields without errors is still decoded.
+// Example:
+//
+//	var gqlErrs graphql.Errors
+//	if errors.As(err, &gqlErrs) {
+//		for _, e := range gqlErrs {
+//			log.Printf("%s at %s: %s", e.Code(), e.PathString(), e.Message)
+//		}
+//	}
+type Errors []*Error
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "graphql: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap allows errors.As to find each *Error
+func (e Errors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+	return errs
+}
+
+// StatusError is returned when the server answers with an unexpected status and without a GraphQL response
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("graphql: unexpected status %d: %s", e.StatusCode, e.Body)
+}
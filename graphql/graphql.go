@@ -0,0 +1,339 @@
+// Package graphql is a GraphQL client on top of rest.Client. The requests go through the RestClient,
+// so they use its base URL, auth (token source, signers, forwarded identity), cache and retries.
+package graphql
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+const (
+	acceptHeader               = "application/graphql-response+json, application/json"
+	persistedQueryNotFound     = "PersistedQueryNotFound"
+	persistedQueryNotFoundCode = "PERSISTED_QUERY_NOT_FOUND"
+)
+
+// Operation is a query or a mutation
+type Operation struct {
+	Query         string
+	OperationName string
+	Variables     map[string]interface{}
+	// CacheTTL caches the result of a query in the cache of the RestClient. Cached queries are sent with GET,
+	// so the URL with the query and the variables is the cache key. Ignored for mutations and subscriptions,
+	// and the responses with errors are not cached
+	CacheTTL time.Duration
+}
+
+// Client sends GraphQL operations to an endpoint of a rest.Client
+type Client struct {
+	client rest.Client
+	path   string
+	// PersistedQueries enables automatic persisted queries (APQ): the SHA-256 hash is sent instead of the query,
+	// and the query is sent again only when the server does not know the hash yet
+	PersistedQueries bool
+}
+
+// NewClient returns a client for the GraphQL endpoint in path, relative to the base URL of client.
+// Example:
+//
+//	gql := graphql.NewClient(rest.NewCustomRestClient(rest.Config{BaseURL: "https://api.example.com"}), "/graphql")
+//
+//	var data struct {
+//		User struct {
+//			Name string `json:"name"`
+//		} `json:"user"`
+//	}
+//	err := gql.Query(ctx, `query($id: ID!) { user(id: $id) { name } }`, map[string]interface{}{"id": "1"}, &data)
+func NewClient(client rest.Client, path string) *Client {
+	return &Client{client: client, path: path}
+}
+
+// Query executes a query and decodes its data into data
+func (c *Client) Query(ctx context.Context, query string, variables map[string]interface{}, data interface{}) error {
+	return c.Exec(ctx, Operation{Query: query, Variables: variables}, data)
+}
+
+// Mutate executes a mutation and decodes its data into data
+func (c *Client) Mutate(ctx context.Context, mutation string, variables map[string]interface{}, data interface{}) error {
+	return c.Exec(ctx, Operation{Query: mutation, Variables: variables}, data)
+}
+
+// Exec executes op and decodes its data into data, a pointer or nil to ignore the data.
+// If the response has errors the data is still decoded and an Errors is returned
+func (c *Client) Exec(ctx context.Context, op Operation, data interface{}) error {
+	if c.PersistedQueries {
+		res, err := c.send(ctx, op, op.payload(false, true))
+		if err != nil {
+			return err
+		}
+		if !res.persistedQueryNotFound() {
+			return res.decode(data)
+		}
+	}
+	res, err := c.send(ctx, op, op.payload(true, c.PersistedQueries))
+	if err != nil {
+		return err
+	}
+	return res.decode(data)
+}
+
+// Execute executes op and returns its data as a T.
+// Example:
+//
+//	data, err := graphql.Execute[struct{ Viewer User }](ctx, gql, graphql.Operation{Query: `{ viewer { id name } }`})
+func Execute[T any](ctx context.Context, c *Client, op Operation) (T, error) {
+	var data T
+	err := c.Exec(ctx, op, &data)
+	return data, err
+}
+
+// Batch sends ops in a single request, for servers that support batching like Apollo Server or gqlgen.
+// data[i] receives the data of ops[i] and errs[i] is its error, err is only set if the whole request failed.
+// Batches always send the full queries and are not cached
+func (c *Client) Batch(ctx context.Context, ops []Operation, data []interface{}) (errs []error, err error) {
+	if len(data) != len(ops) {
+		return nil, errors.New("graphql: ops and data must have the same length")
+	}
+	payloads := make([]payload, len(ops))
+	for i, op := range ops {
+		payloads[i] = op.payload(true, false)
+	}
+	res := c.client.Post(c.path, payloads).WithContext(ctx).WithHeader("Accept", acceptHeader).Do()
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	var responses []response
+	if err := json.Unmarshal(res.BodyBytes, &responses); err != nil {
+		if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+			return nil, &StatusError{StatusCode: res.StatusCode, Body: res.BodyBytes}
+		}
+		return nil, errors.Wrap(err, "graphql: invalid batch response")
+	}
+	if len(responses) != len(ops) {
+		return nil, fmt.Errorf("graphql: got %d responses for %d operations", len(responses), len(ops))
+	}
+	errs = make([]error, len(ops))
+	for i := range responses {
+		errs[i] = responses[i].decode(data[i])
+	}
+	return errs, nil
+}
+
+func (c *Client) send(ctx context.Context, op Operation, p payload) (*response, error) {
+	var req *rest.Request
+	if op.CacheTTL > 0 && operationType(op.Query, op.OperationName) == "query" {
+		// a 200 with GraphQL errors is not cached, the next call asks the server again
+		req = c.client.Get(c.path + "?" + p.query()).WithCache(op.CacheTTL).WithCacheCondition(withoutErrors)
+	} else {
+		req = c.client.Post(c.path, p)
+	}
+	res := req.WithContext(ctx).WithHeader("Accept", acceptHeader).Do()
+	if res.Error != nil {
+		return nil, res.Error
+	}
+
+	out := &response{}
+	err := json.Unmarshal(res.BodyBytes, out)
+	if err != nil || (out.Data == nil && len(out.Errors) == 0) {
+		// GraphQL errors may come with 4xx statuses, other bodies are not a GraphQL response
+		if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+			return nil, &StatusError{StatusCode: res.StatusCode, Body: res.BodyBytes}
+		}
+		return nil, errors.New("graphql: invalid response, it has no data nor errors")
+	}
+	return out, nil
+}
+
+type payload struct {
+	Query         string                 `json:"query,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    *extensions            `json:"extensions,omitempty"`
+}
+
+type extensions struct {
+	PersistedQuery persistedQuery `json:"persistedQuery"`
+}
+
+type persistedQuery struct {
+	Version    int    `json:"version"`
+	SHA256Hash string `json:"sha256Hash"`
+}
+
+func (op Operation) payload(withQuery, persisted bool) payload {
+	p := payload{OperationName: op.OperationName, Variables: op.Variables}
+	if withQuery {
+		p.Query = op.Query
+	}
+	if persisted {
+		p.Extensions = &extensions{PersistedQuery: persistedQuery{Version: 1, SHA256Hash: QueryHash(op.Query)}}
+	}
+	return p
+}
+
+// query encodes the payload as the query string of a GET request
+func (p payload) query() string {
+	values := url.Values{}
+	if p.Query != "" {
+		values.Set("query", p.Query)
+	}
+	if p.OperationName != "" {
+		values.Set("operationName", p.OperationName)
+	}
+	if len(p.Variables) > 0 {
+		b, _ := json.Marshal(p.Variables)
+		values.Set("variables", string(b))
+	}
+	if p.Extensions != nil {
+		b, _ := json.Marshal(p.Extensions)
+		values.Set("extensions", string(b))
+	}
+	return values.Encode()
+}
+
+// QueryHash returns the hex SHA-256 of the query, the hash used by the persisted queries
+func QueryHash(query string) string {
+	sum := sha256.Sum256([]byte(query))
+	return hex.EncodeToString(sum[:])
+}
+
+type response struct {
+	Data   json.RawMessage `json:"data"`
+	Errors Errors          `json:"errors"`
+}
+
+func (r *response) decode(data interface{}) error {
+	if data != nil && len(r.Data) > 0 && string(r.Data) != "null" {
+		if err := json.Unmarshal(r.Data, data); err != nil {
+			return errors.Wrap(err, "graphql: error decoding data")
+		}
+	}
+	if len(r.Errors) > 0 {
+		return r.Errors
+	}
+	return nil
+}
+
+// withoutErrors returns true if res is a GraphQL response with data and no errors
+func withoutErrors(res *rest.Response) bool {
+	var out response
+	return json.Unmarshal(res.BodyBytes, &out) == nil && len(out.Data) > 0 && string(out.Data) != "null" && len(out.Errors) == 0
+}
+
+func (r *response) persistedQueryNotFound() bool {
+	for _, e := range r.Errors {
+		if e.Message == persistedQueryNotFound || e.Code() == persistedQueryNotFoundCode {
+			return true
+		}
+	}
+	return false
+}
+
+// operationType returns the type (query, mutation or subscription) of the operation of the document named
+// operationName, or of its first operation without a name. The fragments, comments and strings are skipped.
+// It returns an empty string if the operation is not found
+func operationType(document, operationName string) string {
+	type operation struct{ kind, name string }
+	var ops []operation
+	depth := 0
+	inDefinition, awaitingName := false, false
+	for i := 0; i < len(document); {
+		c := document[i]
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			i = skipString(document, i)
+			awaitingName = false
+		case c == '{' || c == '(' || c == '[':
+			if c == '{' && depth == 0 && !inDefinition {
+				// the shorthand form of a query
+				ops = append(ops, operation{kind: "query"})
+				inDefinition = true
+			}
+			depth++
+			awaitingName = false
+			i++
+		case c == '}' || c == ')' || c == ']':
+			depth--
+			if c == '}' && depth == 0 {
+				inDefinition = false
+			}
+			awaitingName = false
+			i++
+		case isNameStart(c):
+			start := i
+			for i < len(document) && isNameByte(document[i]) {
+				i++
+			}
+			name := document[start:i]
+			if depth > 0 {
+				continue
+			}
+			switch {
+			case awaitingName:
+				ops[len(ops)-1].name = name
+				awaitingName = false
+			case !inDefinition:
+				inDefinition = true
+				if name == "query" || name == "mutation" || name == "subscription" {
+					ops = append(ops, operation{kind: name})
+					awaitingName = true
+				}
+			}
+		default:
+			i++
+		}
+	}
+	for _, op := range ops {
+		if operationName == "" || op.name == operationName {
+			return op.kind
+		}
+	}
+	return ""
+}
+
+// skipString returns the position after the string or block string starting at i
+func skipString(document string, i int) int {
+	if strings.HasPrefix(document[i:], `"""`) {
+		for i += 3; i < len(document); i++ {
+			if strings.HasPrefix(document[i:], `\"""`) {
+				i += 3
+			} else if strings.HasPrefix(document[i:], `"""`) {
+				return i + 3
+			}
+		}
+		return i
+	}
+	for i++; i < len(document); i++ {
+		switch document[i] {
+		case '\\':
+			i++
+		case '"', '\n':
+			return i + 1
+		}
+	}
+	return i
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameByte(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,228 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+const userQuery = `query User($id: ID!) { user(id: $id) { id name } }`
+
+type userData struct {
+	User *struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"user"`
+}
+
+// fakeServer is a GraphQL server that resolves the user query and supports APQ and batching
+type fakeServer struct {
+	mu        sync.Mutex
+	persisted map[string]string
+	requests  []*http.Request
+	payloads  []payload
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *rest.RestClient) {
+	s := &fakeServer{persisted: map[string]string{}}
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	client := rest.NewCustomRestClient(rest.Config{BaseURL: server.URL})
+	client.Cache = cache.NewMemoryCache("graphql", 100, time.Minute, false)
+	return s, client
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, r)
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodGet {
+		p := payload{Query: r.URL.Query().Get("query")}
+		json.Unmarshal([]byte(r.URL.Query().Get("variables")), &p.Variables)
+		if ext := r.URL.Query().Get("extensions"); ext != "" {
+			p.Extensions = &extensions{}
+			json.Unmarshal([]byte(ext), p.Extensions)
+		}
+		json.NewEncoder(w).Encode(s.resolve(p))
+		return
+	}
+
+	var body json.RawMessage
+	json.NewDecoder(r.Body).Decode(&body)
+	if strings.HasPrefix(string(body), "[") {
+		var batch []payload
+		json.Unmarshal(body, &batch)
+		out := make([]interface{}, len(batch))
+		for i, p := range batch {
+			out[i] = s.resolve(p)
+		}
+		json.NewEncoder(w).Encode(out)
+		return
+	}
+	var p payload
+	json.Unmarshal(body, &p)
+	json.NewEncoder(w).Encode(s.resolve(p))
+}
+
+func (s *fakeServer) resolve(p payload) interface{} {
+	s.payloads = append(s.payloads, p)
+	if p.Extensions != nil {
+		hash := p.Extensions.PersistedQuery.SHA256Hash
+		if p.Query == "" {
+			if p.Query = s.persisted[hash]; p.Query == "" {
+				return map[string]interface{}{"errors": []map[string]interface{}{{
+					"message": "PersistedQueryNotFound", "extensions": map[string]string{"code": "PERSISTED_QUERY_NOT_FOUND"},
+				}}}
+			}
+		} else {
+			s.persisted[hash] = p.Query
+		}
+	}
+	if p.Query != userQuery {
+		return map[string]interface{}{"errors": []map[string]interface{}{{"message": "unknown query"}}}
+	}
+	id, _ := p.Variables["id"].(string)
+	if id != "1" {
+		return map[string]interface{}{
+			"data": map[string]interface{}{"user": nil},
+			"errors": []map[string]interface{}{{
+				"message":    "user not found",
+				"locations":  []Location{{Line: 1, Column: 26}},
+				"path":       []interface{}{"user"},
+				"extensions": map[string]string{"code": "NOT_FOUND"},
+			}},
+		}
+	}
+	return map[string]interface{}{"data": map[string]interface{}{"user": map[string]string{"id": "1", "name": "Ana"}}}
+}
+
+// Test data and errors are decoded
+func TestQuery(t *testing.T) {
+	_, client := newFakeServer(t)
+	gql := NewClient(client, "/graphql")
+
+	var data userData
+	err := gql.Query(context.Background(), userQuery, map[string]interface{}{"id": "1"}, &data)
+	assert.Nil(t, err)
+	assert.Equal(t, "Ana", data.User.Name)
+
+	data = userData{}
+	err = gql.Query(context.Background(), userQuery, map[string]interface{}{"id": "2"}, &data)
+	assert.Nil(t, data.User)
+	var gqlErrs Errors
+	assert.True(t, errors.As(err, &gqlErrs))
+	assert.Len(t, gqlErrs, 1)
+	var gqlErr *Error
+	assert.True(t, errors.As(err, &gqlErr))
+	assert.Equal(t, "NOT_FOUND", gqlErr.Code())
+	assert.Equal(t, "user", gqlErr.PathString())
+	assert.Equal(t, []Location{{Line: 1, Column: 26}}, gqlErr.Locations)
+	assert.Equal(t, "graphql: user not found (path: user)", err.Error())
+
+	user, err := Execute[userData](context.Background(), gql, Operation{Query: userQuery, Variables: map[string]interface{}{"id": "1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "1", user.User.ID)
+}
+
+// Test the query is only sent when the server does not know its hash
+func TestPersistedQueries(t *testing.T) {
+	server, client := newFakeServer(t)
+	gql := NewClient(client, "/graphql")
+	gql.PersistedQueries = true
+
+	for i := 0; i < 2; i++ {
+		var data userData
+		assert.Nil(t, gql.Query(context.Background(), userQuery, map[string]interface{}{"id": "1"}, &data))
+		assert.Equal(t, "Ana", data.User.Name)
+	}
+
+	assert.Len(t, server.payloads, 3)
+	assert.Empty(t, server.payloads[0].Query)
+	assert.Equal(t, userQuery, server.payloads[1].Query)
+	assert.Empty(t, server.payloads[2].Query)
+	assert.Equal(t, QueryHash(userQuery), server.payloads[2].Extensions.PersistedQuery.SHA256Hash)
+}
+
+// Test cached queries are sent with GET and served from the cache of the RestClient
+func TestCachedQuery(t *testing.T) {
+	server, client := newFakeServer(t)
+	gql := NewClient(client, "/graphql")
+	op := Operation{Query: userQuery, Variables: map[string]interface{}{"id": "1"}, CacheTTL: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		data, err := Execute[userData](context.Background(), gql, op)
+		assert.Nil(t, err)
+		assert.Equal(t, "Ana", data.User.Name)
+	}
+	assert.Len(t, server.requests, 1)
+	assert.Equal(t, http.MethodGet, server.requests[0].Method)
+
+	// responses with errors are not cached
+	op.Variables = map[string]interface{}{"id": "2"}
+	for i := 0; i < 2; i++ {
+		_, err := Execute[userData](context.Background(), gql, op)
+		assert.NotNil(t, err)
+	}
+	assert.Len(t, server.requests, 3)
+}
+
+// Test the type of the operation is read from its definition
+func TestOperationType(t *testing.T) {
+	assert.Equal(t, "query", operationType(" # comment\n query { a }", ""))
+	assert.Equal(t, "query", operationType("{ a }", ""))
+	assert.Equal(t, "mutation", operationType("# comment\n  mutation { a }", ""))
+	assert.Equal(t, "mutation", operationType(`fragment F on User { id }
+		mutation Rename($name: String = "{ query }") { rename(name: $name) { ...F } }`, ""))
+	assert.Equal(t, "mutation", operationType(`query A { a } mutation B { b(text: """a \""" }""") }`, "B"))
+	assert.Equal(t, "query", operationType(`query A { a } mutation B { b }`, "A"))
+	assert.Equal(t, "subscription", operationType(`subscription { events { id } }`, ""))
+	assert.Equal(t, "", operationType(`fragment F on User { id }`, ""))
+}
+
+// Test several operations in a single request
+func TestBatch(t *testing.T) {
+	server, client := newFakeServer(t)
+	gql := NewClient(client, "/graphql")
+
+	var first, second userData
+	errs, err := gql.Batch(context.Background(), []Operation{
+		{Query: userQuery, Variables: map[string]interface{}{"id": "1"}},
+		{Query: userQuery, Variables: map[string]interface{}{"id": "2"}},
+	}, []interface{}{&first, &second})
+	assert.Nil(t, err)
+	assert.Len(t, server.requests, 1)
+	assert.Nil(t, errs[0])
+	assert.Equal(t, "Ana", first.User.Name)
+	var gqlErrs Errors
+	assert.True(t, errors.As(errs[1], &gqlErrs))
+
+	_, err = gql.Batch(context.Background(), []Operation{{Query: userQuery}}, nil)
+	assert.NotNil(t, err)
+}
+
+// Test responses that are not GraphQL responses
+func TestStatusError(t *testing.T) {
+	mock := rest.NewDefaultMockClient(t)
+	mock.Expect(http.MethodPost, rest.MatchURL("/graphql"), rest.MatchBodyPath("variables.id", func(v gjson.Result) bool { return v.String() == "1" })).
+		Respond(rest.MockResponse{StatusCode: 502, JSONBody: `<html>bad gateway</html>`})
+	gql := NewClient(mock, "/graphql")
+
+	err := gql.Query(context.Background(), userQuery, map[string]interface{}{"id": "1"}, nil)
+	var statusErr *StatusError
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, 502, statusErr.StatusCode)
+}
@@ -0,0 +1,137 @@
+package hmacauth
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+var (
+	oldKey = Key{ID: "2023", Secret: []byte("old-secret")}
+	newKey = Key{ID: "2024", Secret: []byte("new-secret")}
+)
+
+func newVerifiedServer(t *testing.T, nonces cache.Spec) *httptest.Server {
+	app := fiber.New()
+	app.Post("/payments", Verify(Config{
+		Keys:   StaticKeys(oldKey, newKey),
+		Nonces: nonces,
+	}), func(c *fiber.Ctx) error {
+		return c.SendString(c.Locals("hmac_key_id").(string))
+	})
+	server := httptest.NewServer(adaptor.FiberApp(app))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test signed requests are accepted and keys can be rotated
+func TestSignAndVerify(t *testing.T) {
+	server := newVerifiedServer(t, cache.NewMemoryCache("nonces", 100, time.Minute, false))
+	signer := NewSigner(oldKey)
+	client := rest.NewCustomRestClient(rest.Config{BaseURL: server.URL}).WithSigners(signer)
+
+	res := client.Post("/payments?currency=ARS", map[string]int{"amount": 10}).Do()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "2023", string(res.BodyBytes))
+
+	signer.Rotate(newKey)
+	res = client.Post("/payments", map[string]int{"amount": 10}).Do()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "2024", string(res.BodyBytes))
+
+	signer.Rotate(Key{ID: "unknown", Secret: []byte("x")})
+	assert.Equal(t, 401, client.Post("/payments", nil).Do().StatusCode)
+}
+
+// Test tampered, stale and replayed requests are rejected
+func TestVerifyRejects(t *testing.T) {
+	server := newVerifiedServer(t, cache.NewMemoryCache("nonces", 100, time.Minute, false))
+	signer := NewSigner(newKey)
+
+	signed := func(body string) *http.Request {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/payments", bytes.NewBufferString(body))
+		assert.Nil(t, signer.Sign(req, []byte(body)))
+		return req
+	}
+	send := func(req *http.Request, body string) int {
+		req.Body = http.NoBody
+		if body != "" {
+			req.Body = io.NopCloser(strings.NewReader(body))
+		}
+		res, err := http.DefaultClient.Do(req)
+		assert.Nil(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+
+	req := signed(`{"amount":10}`)
+	assert.Equal(t, 200, send(req.Clone(req.Context()), `{"amount":10}`))
+	assert.Equal(t, 401, send(req.Clone(req.Context()), `{"amount":10}`), "replay")
+
+	req = signed(`{"amount":10}`)
+	assert.Equal(t, 401, send(req, `{"amount":99}`), "tampered body")
+
+	signer.now = func() time.Time { return time.Now().Add(-10 * time.Minute) }
+	req = signed(`{}`)
+	assert.Equal(t, 401, send(req, `{}`), "stale timestamp")
+
+	req, _ = http.NewRequest(http.MethodPost, server.URL+"/payments", nil)
+	assert.Equal(t, 401, send(req, ""), "unsigned")
+}
+
+// slowSpec is a Spec without SaveIfAbsent whose Get takes a while, so the concurrent requests overlap
+type slowSpec struct {
+	cache.Spec
+}
+
+func (s slowSpec) Get(ctx context.Context, key string) (bool, interface{}) {
+	expired, value := s.Spec.Get(ctx, key)
+	time.Sleep(10 * time.Millisecond)
+	return expired, value
+}
+
+// Test only one of the copies of a signed request sent at the same time is accepted
+func TestVerifyConcurrentReplays(t *testing.T) {
+	caches := map[string]cache.Spec{
+		"atomic": cache.NewMemoryCache("nonces", 100, time.Minute, false),
+		"mutex":  slowSpec{Spec: cache.NewMemoryCache("nonces", 100, time.Minute, false)},
+	}
+	for name, nonces := range caches {
+		t.Run(name, func(t *testing.T) {
+			server := newVerifiedServer(t, nonces)
+			req, _ := http.NewRequest(http.MethodPost, server.URL+"/payments", nil)
+			assert.Nil(t, NewSigner(newKey).Sign(req, nil))
+
+			var accepted int32
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res, err := http.DefaultClient.Do(req.Clone(req.Context()))
+					assert.Nil(t, err)
+					res.Body.Close()
+					if res.StatusCode == 200 {
+						atomic.AddInt32(&accepted, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			assert.Equal(t, int32(1), accepted)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package hmacauth
+
+import (
+	"crypto/hmac"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/problem"
+)
+
+const defaultWindow = 5 * time.Minute
+
+// Config configures the verification middleware
+type Config struct {
+	// Keys returns the secret of a key id, false if the key is unknown. Several keys can be active while they are rotated
+	Keys func(keyID string) ([]byte, bool)
+	// Nonces remembers the nonces already seen to reject replays. They are claimed with cache.Claim, so the copies of
+	// a request sent at the same time are rejected too, by all the instances sharing a NewRedisCache
+	Nonces cache.Spec
+	// Window is the max difference allowed between the timestamp of the request and now. Defaults to 5 minutes
+	Window        time.Duration
+	Headers       Headers
+	Canonicalizer Canonicalizer
+	now           func() time.Time
+}
+
+// StaticKeys returns a key lookup over a fixed set of keys
+func StaticKeys(keys ...Key) func(keyID string) ([]byte, bool) {
+	byID := make(map[string][]byte, len(keys))
+	for _, k := range keys {
+		byID[k.ID] = k.Secret
+	}
+	return func(keyID string) ([]byte, bool) {
+		secret, ok := byID[keyID]
+		return secret, ok
+	}
+}
+
+// Verify returns a middleware that rejects the requests without a valid signature with a 401.
+// Requests outside the time window or reusing a nonce are rejected too, and a 503 is returned if Nonces fails.
+// The id of the key that signed the request is injected in the fiber context as hmac_key_id
+// Example:
+//
+//	app.Post("/partners/webhook", hmacauth.Verify(hmacauth.Config{
+//		Keys:   hmacauth.StaticKeys(hmacauth.Key{ID: "2024-01", Secret: secret}),
+//		Nonces: cache.NewMemoryCache("nonces", 10000, 10*time.Minute, false),
+//	}), handler)
+func Verify(cfg Config) fiber.Handler {
+	if cfg.Keys == nil || cfg.Nonces == nil {
+		panic("hmacauth: Keys and Nonces are required")
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+	if cfg.Headers == (Headers{}) {
+		cfg.Headers = DefaultHeaders
+	}
+	if cfg.Canonicalizer == nil {
+		cfg.Canonicalizer = DefaultCanonicalizer
+	}
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
+	// claims the nonces when Nonces has no SaveIfAbsent
+	var mu sync.Mutex
+
+	return func(c *fiber.Ctx) error {
+		keyID := c.Get(cfg.Headers.KeyID)
+		timestamp := c.Get(cfg.Headers.Timestamp)
+		nonce := c.Get(cfg.Headers.Nonce)
+		signature := c.Get(cfg.Headers.Signature)
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			return unauthorized(c, "Missing signature.")
+		}
+
+		secret, ok := cfg.Keys(keyID)
+		if !ok {
+			return unauthorized(c, "Unknown key.")
+		}
+
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return unauthorized(c, "Invalid timestamp.")
+		}
+		skew := cfg.now().Sub(time.Unix(unix, 0))
+		if skew > cfg.Window || skew < -cfg.Window {
+			return unauthorized(c, "Request outside the time window.")
+		}
+
+		expected := Sign(secret, cfg.Canonicalizer(CanonicalRequest{
+			Method:    c.Method(),
+			Path:      c.OriginalURL(),
+			Timestamp: timestamp,
+			Nonce:     nonce,
+			BodyHash:  HashBody(c.Body()),
+		}))
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return unauthorized(c, "Invalid signature.")
+		}
+
+		// the nonce is checked only after the signature, so unsigned requests can't fill the cache
+		nonceKey := "hmacauth:" + keyID + ":" + nonce
+		// a nonce can only be replayed while its timestamp is inside the window, so it is remembered for twice the window
+		_, claimed, err := cache.Claim(c.UserContext(), cfg.Nonces, &mu, nonceKey, true, 2*cfg.Window)
+		if err != nil {
+			return problem.Send(c, problem.New(fiber.StatusServiceUnavailable, "The nonce could not be checked."))
+		}
+		if !claimed {
+			return unauthorized(c, "Replayed request.")
+		}
+
+		c.Locals("hmac_key_id", keyID)
+		return c.Next()
+	}
+}
+
+func unauthorized(c *fiber.Ctx, message string) error {
+	return problem.Send(c, problem.New(fiber.StatusUnauthorized, message))
+}
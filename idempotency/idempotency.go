@@ -0,0 +1,184 @@
+// Package idempotency provides a fiber middleware that deduplicates unsafe requests carrying an Idempotency-Key header.
+// The first response of each key, with its status, headers and body, is stored and returned again for the repeated
+// requests.
+package idempotency
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/problem"
+)
+
+const (
+	defaultHeader  = "Idempotency-Key"
+	defaultTTL     = 24 * time.Hour
+	defaultLockTTL = time.Minute
+	// ReplayedHeader is set in the responses returned from the cache
+	ReplayedHeader = "Idempotent-Replayed"
+)
+
+// Config configures the idempotency middleware
+type Config struct {
+	// Cache stores the responses by key. Required. With a cache.AtomicSpec, like the caches of NewMemoryCache and
+	// NewRedisCache, the keys are claimed atomically and a cache shared by several instances of the service detects
+	// the concurrent duplicates received by different instances. Other caches only detect them within an instance
+	Cache cache.Spec
+	// TTL is the time the responses are kept. Defaults to 24 hours
+	TTL time.Duration
+	// LockTTL is the max time a request holds its key while it is processed. Defaults to 1 minute
+	LockTTL time.Duration
+	// Header is the header carrying the key. Defaults to Idempotency-Key
+	Header string
+	// KeyFunc builds the cache key from the request and the idempotency key. Defaults to method + path + key.
+	// Use it to scope the keys by client, for example with the client_id injected by oauthv2
+	KeyFunc func(c *fiber.Ctx, key string) string
+}
+
+// Record is the state of a key saved in the cache: the lock of a request in progress or its response
+type Record struct {
+	// InProgress marks a key whose request is being processed, it has no response yet
+	InProgress bool
+	StatusCode int
+	// Headers are the response headers, without the hop-by-hop ones
+	Headers  map[string][]string
+	Body     []byte
+	BodyHash string
+}
+
+func init() {
+	gob.Register(&Record{})
+}
+
+// New returns a middleware that stores the responses of the requests with an idempotency key and returns the stored
+// response for the repeated ones. Concurrent requests with the same key are rejected with a 409 and reusing a key
+// with a different payload is rejected with a 422. Safe methods (GET, HEAD, OPTIONS, TRACE) are ignored.
+// Responses with 5xx are not stored, so the client can retry them. If the cache fails to claim a key the request
+// is rejected with a 503.
+// Example:
+//
+//	app.Post("/payments", idempotency.New(idempotency.Config{
+//		Cache: cache.NewMemoryCache("idempotency", 10000, 24*time.Hour, false),
+//	}), handler)
+func New(cfg Config) fiber.Handler {
+	if cfg.Cache == nil {
+		panic("idempotency: Cache is required")
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = defaultTTL
+	}
+	if cfg.LockTTL <= 0 {
+		cfg.LockTTL = defaultLockTTL
+	}
+	if cfg.Header == "" {
+		cfg.Header = defaultHeader
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = func(c *fiber.Ctx, key string) string {
+			return c.Method() + c.Path() + ":" + key
+		}
+	}
+	// the caches without an atomic set-if-absent serialize the check and the lock of a key in the process
+	var mu sync.Mutex
+
+	return func(c *fiber.Ctx) error {
+		key := c.Get(cfg.Header)
+		if key == "" || isSafe(c.Method()) {
+			return c.Next()
+		}
+		cacheKey := "idempotency:" + cfg.KeyFunc(c, key)
+		bodyHash := hashBody(c.Body())
+		ctx := c.UserContext()
+
+		lock := &Record{InProgress: true, BodyHash: bodyHash}
+		stored, claimed, err := cache.Claim(ctx, cfg.Cache, &mu, cacheKey, lock, cfg.LockTTL)
+		if err != nil {
+			return reject(c, fiber.StatusServiceUnavailable, "The Idempotency-Key could not be checked.")
+		}
+		if !claimed {
+			if stored.InProgress {
+				return reject(c, fiber.StatusConflict, "A request with the same Idempotency-Key is in progress.")
+			}
+			if stored.BodyHash != bodyHash {
+				return reject(c, fiber.StatusUnprocessableEntity, "Idempotency-Key reused with a different payload.")
+			}
+			c.Set(ReplayedHeader, "true")
+			for name, values := range stored.Headers {
+				c.Response().Header.Del(name)
+				for _, value := range values {
+					c.Response().Header.Add(name, value)
+				}
+			}
+			return c.Status(stored.StatusCode).Send(stored.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			cfg.Cache.Delete(ctx, cacheKey)
+			return err
+		}
+
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			cfg.Cache.Delete(ctx, cacheKey)
+			return nil
+		}
+		cfg.Cache.SaveWithTTL(ctx, cacheKey, &Record{
+			StatusCode: status,
+			Headers:    responseHeaders(c),
+			Body:       append([]byte(nil), c.Response().Body()...),
+			BodyHash:   bodyHash,
+		}, cfg.TTL)
+		return nil
+	}
+}
+
+// notReplayed are the headers of a response that are not stored: the hop-by-hop ones and the ones set by the server
+var notReplayed = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+	"Content-Length":      true,
+	"Date":                true,
+	"Server":              true,
+}
+
+// responseHeaders returns the headers of the response to store
+func responseHeaders(c *fiber.Ctx) map[string][]string {
+	headers := make(map[string][]string)
+	c.Response().Header.VisitAll(func(key, value []byte) {
+		name := http.CanonicalHeaderKey(string(key))
+		if !notReplayed[name] {
+			headers[name] = append(headers[name], string(value))
+		}
+	})
+	return headers
+}
+
+func isSafe(method string) bool {
+	switch method {
+	case fiber.MethodGet, fiber.MethodHead, fiber.MethodOptions, fiber.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+func reject(c *fiber.Ctx, status int, message string) error {
+	return problem.Send(c, problem.New(status, message))
+}
@@ -0,0 +1,162 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/cache/cachetest"
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// forEachCache runs test with a memory cache and with a redis cache, that reads the records back as maps
+func forEachCache(t *testing.T, test func(t *testing.T, spec cache.Spec)) {
+	t.Run("memory", func(t *testing.T) {
+		test(t, cache.NewMemoryCache("idempotency", 100, time.Hour, false))
+	})
+	t.Run("redis", func(t *testing.T) {
+		server := cachetest.NewRedisServer(t, "")
+		test(t, cache.NewRedisCache("idempotency", time.Hour, cache.RedisConfig{Addr: server.Addr()}))
+	})
+}
+
+func newPaymentsServer(t *testing.T, spec cache.Spec, handler fiber.Handler) *rest.RestClient {
+	app := fiber.New()
+	app.Post("/payments", New(Config{Cache: spec}), handler)
+	server := httptest.NewServer(adaptor.FiberApp(app))
+	t.Cleanup(server.Close)
+	return rest.NewCustomRestClient(rest.Config{BaseURL: server.URL})
+}
+
+// Test repeated requests get the stored response and the handler runs once
+func TestRepeatedRequestsAreReplayed(t *testing.T) {
+	forEachCache(t, func(t *testing.T, spec cache.Spec) {
+		var calls int32
+		client := newPaymentsServer(t, spec, func(c *fiber.Ctx) error {
+			n := atomic.AddInt32(&calls, 1)
+			c.Location(fmt.Sprintf("/payments/%d", n))
+			c.Append("X-Trace", "a", "b")
+			return c.Status(fiber.StatusCreated).JSON(fiber.Map{"id": n})
+		})
+
+		req := client.Post("/payments", map[string]int{"amount": 10}).WithIdempotencyKey()
+		key := req.Headers.Get(rest.IdempotencyKeyHeader)
+		assert.NotEmpty(t, key)
+
+		first := req.Do()
+		second := req.Do()
+		assert.Equal(t, key, req.Headers.Get(rest.IdempotencyKeyHeader))
+		assert.Equal(t, 201, first.StatusCode)
+		assert.Equal(t, 201, second.StatusCode)
+		assert.JSONEq(t, `{"id":1}`, string(second.BodyBytes))
+		assert.Equal(t, "true", second.Headers.Get(ReplayedHeader))
+		assert.Equal(t, "application/json", second.Headers.Get("Content-Type"))
+		assert.Equal(t, "/payments/1", second.Headers.Get("Location"))
+		assert.Equal(t, "a, b", second.Headers.Get("X-Trace"))
+		assert.Equal(t, int32(1), calls)
+
+		res := client.Post("/payments", map[string]int{"amount": 99}).WithIdempotencyKey(key).Do()
+		assert.Equal(t, 422, res.StatusCode)
+		problem, ok := res.Problem()
+		assert.True(t, ok)
+		assert.Equal(t, "Idempotency-Key reused with a different payload.", problem.Detail)
+
+		res = client.Post("/payments", map[string]int{"amount": 10}).Do()
+		assert.Equal(t, 201, res.StatusCode)
+		assert.Equal(t, int32(2), calls)
+	})
+}
+
+// Test concurrent requests with the same key are rejected
+func TestConcurrentDuplicatesAreRejected(t *testing.T) {
+	forEachCache(t, func(t *testing.T, spec cache.Spec) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		client := newPaymentsServer(t, spec, func(c *fiber.Ctx) error {
+			close(started)
+			<-release
+			return c.SendStatus(fiber.StatusCreated)
+		})
+
+		done := make(chan *rest.Response)
+		go func() {
+			done <- client.Post("/payments", nil).WithIdempotencyKey("key-1").Do()
+		}()
+		<-started
+
+		res := client.Post("/payments", nil).WithIdempotencyKey("key-1").Do()
+		assert.Equal(t, 409, res.StatusCode)
+
+		close(release)
+		assert.Equal(t, 201, (<-done).StatusCode)
+		assert.Equal(t, 201, client.Post("/payments", nil).WithIdempotencyKey("key-1").Do().StatusCode)
+	})
+}
+
+// Test server errors are not stored so the request can be retried
+func TestServerErrorsAreNotStored(t *testing.T) {
+	forEachCache(t, func(t *testing.T, spec cache.Spec) {
+		var calls int32
+		client := newPaymentsServer(t, spec, func(c *fiber.Ctx) error {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				return c.SendStatus(fiber.StatusServiceUnavailable)
+			}
+			return c.SendStatus(fiber.StatusCreated)
+		})
+
+		req := client.Post("/payments", nil).WithIdempotencyKey()
+		assert.Equal(t, 503, req.Do().StatusCode)
+		assert.Equal(t, 201, req.Do().StatusCode)
+		assert.Equal(t, 201, req.Do().StatusCode)
+		assert.Equal(t, int32(2), calls)
+	})
+}
+
+// Test the duplicates received by another instance sharing the redis cache are rejected
+func TestConcurrentDuplicatesAcrossInstances(t *testing.T) {
+	server := cachetest.NewRedisServer(t, "")
+	cfg := cache.RedisConfig{Addr: server.Addr()}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	first := newPaymentsServer(t, cache.NewRedisCache("idempotency", time.Hour, cfg), func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendStatus(fiber.StatusCreated)
+	})
+	second := newPaymentsServer(t, cache.NewRedisCache("idempotency", time.Hour, cfg), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusCreated)
+	})
+
+	done := make(chan *rest.Response)
+	go func() {
+		done <- first.Post("/payments", nil).WithIdempotencyKey("key-1").Do()
+	}()
+	<-started
+
+	assert.Equal(t, 409, second.Post("/payments", nil).WithIdempotencyKey("key-1").Do().StatusCode)
+	close(release)
+	assert.Equal(t, 201, (<-done).StatusCode)
+	res := second.Post("/payments", nil).WithIdempotencyKey("key-1").Do()
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, "true", res.Headers.Get(ReplayedHeader))
+}
+
+// Test the requests are rejected when the cache can not claim the key
+func TestUnavailableCache(t *testing.T) {
+	var calls int32
+	client := newPaymentsServer(t, cache.NewRedisCache("idempotency", time.Hour, cache.RedisConfig{Addr: "127.0.0.1:1"}),
+		func(c *fiber.Ctx) error {
+			atomic.AddInt32(&calls, 1)
+			return c.SendStatus(fiber.StatusCreated)
+		})
+
+	assert.Equal(t, 503, client.Post("/payments", nil).WithIdempotencyKey("key-1").Do().StatusCode)
+	assert.Equal(t, int32(0), calls)
+}
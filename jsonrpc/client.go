@@ -0,0 +1,172 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// StatusError is returned when the server answers with an unexpected status and without a JSON-RPC response
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("jsonrpc: unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+// Client sends JSON-RPC calls to an endpoint of a rest.Client, so they use its base URL, auth and retries
+type Client struct {
+	client rest.Client
+	path   string
+	nextID uint64
+}
+
+// NewClient returns a client for the JSON-RPC endpoint in path, relative to the base URL of client.
+// Example:
+//
+//	rpc := jsonrpc.NewClient(rest.NewCustomRestClient(rest.Config{BaseURL: "https://node.example.com"}), "/")
+//
+//	var balance string
+//	err := rpc.Call(ctx, "eth_getBalance", []interface{}{address, "latest"}, &balance)
+func NewClient(client rest.Client, path string) *Client {
+	return &Client{client: client, path: path}
+}
+
+// Call calls method with params, an array or a struct, and decodes the result into result, a pointer or nil.
+// If the server answers with an error object it is returned as an *Error
+func (c *Client) Call(ctx context.Context, method string, params interface{}, result interface{}) error {
+	req := c.newRequest(method, params)
+	res := c.client.Post(c.path, req).WithContext(ctx).Do()
+	if res.Error != nil {
+		return res.Error
+	}
+
+	var out response
+	if err := json.Unmarshal(res.BodyBytes, &out); err != nil || out.JSONRPC != version {
+		return statusError(res)
+	}
+	if !bytes.Equal(out.ID, req.ID) {
+		return fmt.Errorf("jsonrpc: got response id %s for request id %s", out.ID, req.ID)
+	}
+	return out.decode(result)
+}
+
+// Notify sends a notification, a call without id. The server does not answer, so errors of the method are not reported
+func (c *Client) Notify(ctx context.Context, method string, params interface{}) error {
+	res := c.client.Post(c.path, request{JSONRPC: version, Method: method, Params: params}).WithContext(ctx).Do()
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return &StatusError{StatusCode: res.StatusCode, Body: res.BodyBytes}
+	}
+	return nil
+}
+
+// BatchCall is a call of a batch. After Batch returns, Result has the decoded result and Error the error of the call
+type BatchCall struct {
+	Method string
+	Params interface{}
+	// Result is a pointer where the result is decoded, nil to ignore it
+	Result interface{}
+	// Notification sends the call without id, no result nor error is received for it
+	Notification bool
+	Error        error
+
+	id json.RawMessage
+}
+
+// Batch sends the calls in a single request. The responses are correlated with the calls by id, so the server may
+// answer them in any order. The returned error is only set if the whole request failed.
+// Example:
+//
+//	calls := []*jsonrpc.BatchCall{
+//		{Method: "eth_blockNumber", Result: &block},
+//		{Method: "eth_gasPrice", Result: &gasPrice},
+//	}
+//	if err := rpc.Batch(ctx, calls); err != nil {
+//		return err
+//	}
+//	if calls[1].Error != nil {
+//		...
+//	}
+func (c *Client) Batch(ctx context.Context, calls []*BatchCall) error {
+	if len(calls) == 0 {
+		return errors.New("jsonrpc: empty batch")
+	}
+	reqs := make([]request, len(calls))
+	pending := make(map[string]*BatchCall, len(calls))
+	for i, call := range calls {
+		if call.Notification {
+			reqs[i] = request{JSONRPC: version, Method: call.Method, Params: call.Params}
+			continue
+		}
+		reqs[i] = c.newRequest(call.Method, call.Params)
+		call.id = reqs[i].ID
+		pending[string(call.id)] = call
+	}
+
+	res := c.client.Post(c.path, reqs).WithContext(ctx).Do()
+	if res.Error != nil {
+		return res.Error
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var responses []response
+	if err := json.Unmarshal(res.BodyBytes, &responses); err != nil {
+		// a batch that is not valid at all gets a single error response
+		var single response
+		if json.Unmarshal(res.BodyBytes, &single) == nil && single.Error != nil {
+			return single.Error
+		}
+		return statusError(res)
+	}
+	for _, out := range responses {
+		call, ok := pending[string(out.ID)]
+		if !ok {
+			continue
+		}
+		delete(pending, string(out.ID))
+		call.Error = out.decode(call.Result)
+	}
+	for id, call := range pending {
+		call.Error = fmt.Errorf("jsonrpc: no response for request id %s", id)
+	}
+	return nil
+}
+
+func (c *Client) newRequest(method string, params interface{}) request {
+	id := atomic.AddUint64(&c.nextID, 1)
+	return request{JSONRPC: version, Method: method, Params: params, ID: json.RawMessage(strconv.FormatUint(id, 10))}
+}
+
+func (r *response) decode(result interface{}) error {
+	if r.Error != nil {
+		return r.Error
+	}
+	if result != nil && len(r.Result) > 0 {
+		if err := json.Unmarshal(r.Result, result); err != nil {
+			return errors.Wrap(err, "jsonrpc: error decoding result")
+		}
+	}
+	return nil
+}
+
+func statusError(res *rest.Response) error {
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return &StatusError{StatusCode: res.StatusCode, Body: res.BodyBytes}
+	}
+	return errors.New("jsonrpc: invalid response")
+}
@@ -0,0 +1,69 @@
+// Package jsonrpc implements JSON-RPC 2.0 over HTTP: a client on top of rest.Client and a fiber handler
+// that dispatches the calls to registered Go functions.
+package jsonrpc
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+const version = "2.0"
+
+// Error codes defined by the specification. Codes from -32000 to -32099 are reserved for server errors
+const (
+	CodeParseError     = -32700
+	CodeInvalidRequest = -32600
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeInternalError  = -32603
+)
+
+// Error is the error object of a response. Handlers can return it to choose the code sent to the client.
+// Example:
+//
+//	var rpcErr *jsonrpc.Error
+//	if errors.As(err, &rpcErr) && rpcErr.Code == jsonrpc.CodeMethodNotFound {
+//		...
+//	}
+type Error struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewError returns an error with code and message, data is optional
+func NewError(code int, message string, data interface{}) *Error {
+	return &Error{Code: code, Message: message, Data: data}
+}
+
+func (e *Error) Error() string {
+	if e.Data != nil {
+		return fmt.Sprintf("jsonrpc: %d %s: %v", e.Code, e.Message, e.Data)
+	}
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+// request is a call, or a notification when it has no id
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  interface{}     `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// serverRequest is a request as received by the server, params are decoded by the method
+type serverRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var null = json.RawMessage("null")
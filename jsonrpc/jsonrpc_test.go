@@ -0,0 +1,168 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+type transferParams struct {
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Amount int64  `json:"amount"`
+}
+
+func (p transferParams) Validate() error {
+	if p.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	return nil
+}
+
+type addParams struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+func newWalletServer(t *testing.T) (string, *int32) {
+	var notified int32
+	server := NewServer()
+	server.MustRegister("add", func(ctx context.Context, p addParams) (int, error) {
+		return p.A + p.B, nil
+	})
+	server.MustRegister("transfer", func(ctx context.Context, p *transferParams) (string, error) {
+		if p.From == "empty" {
+			return "", NewError(-32001, "Insufficient funds", map[string]int64{"missing": p.Amount})
+		}
+		return "tx-1", nil
+	})
+	server.MustRegister("ping", func(ctx context.Context) error {
+		atomic.AddInt32(&notified, 1)
+		return nil
+	})
+	server.MustRegister("fail", func(ctx context.Context) (int, error) {
+		return 0, errors.New("db is down")
+	})
+	server.MustRegister("panic", func(ctx context.Context) (int, error) {
+		panic("boom")
+	})
+	assert.NotNil(t, server.Register("bad", func(p addParams) error { return nil }))
+	assert.NotNil(t, server.Register("bad", func(ctx context.Context) int { return 0 }))
+
+	app := fiber.New()
+	app.Post("/rpc", server.Handler())
+	httpServer := httptest.NewServer(adaptor.FiberApp(app))
+	t.Cleanup(httpServer.Close)
+	return httpServer.URL, &notified
+}
+
+// Test calls and notifications through the client
+func TestCallAndNotify(t *testing.T) {
+	url, notified := newWalletServer(t)
+	rpc := NewClient(rest.NewCustomRestClient(rest.Config{BaseURL: url}), "/rpc")
+	ctx := context.Background()
+
+	var sum int
+	assert.Nil(t, rpc.Call(ctx, "add", map[string]int{"a": 1, "b": 2}, &sum))
+	assert.Equal(t, 3, sum)
+	assert.Nil(t, rpc.Call(ctx, "add", []int{5, 6}, &sum))
+	assert.Equal(t, 11, sum)
+
+	var tx string
+	err := rpc.Call(ctx, "transfer", transferParams{From: "empty", To: "b", Amount: 10}, &tx)
+	var rpcErr *Error
+	assert.True(t, errors.As(err, &rpcErr))
+	assert.Equal(t, -32001, rpcErr.Code)
+	assert.Equal(t, map[string]interface{}{"missing": float64(10)}, rpcErr.Data)
+
+	cases := []struct {
+		method string
+		params interface{}
+		code   int
+	}{
+		{"transfer", transferParams{From: "a", To: "b"}, CodeInvalidParams},
+		{"transfer", map[string]interface{}{"from": "a", "amount": 1, "memo": "x"}, CodeInvalidParams},
+		{"add", []int{1, 2, 3}, CodeInvalidParams},
+		{"missing", nil, CodeMethodNotFound},
+		{"fail", nil, CodeInternalError},
+		{"panic", nil, CodeInternalError},
+	}
+	for _, tc := range cases {
+		err := rpc.Call(ctx, tc.method, tc.params, nil)
+		assert.True(t, errors.As(err, &rpcErr), tc.method)
+		assert.Equal(t, tc.code, rpcErr.Code, tc.method)
+	}
+	assert.NotContains(t, rpc.Call(ctx, "fail", nil, nil).Error(), "db is down")
+
+	assert.Nil(t, rpc.Notify(ctx, "ping", nil))
+	assert.Equal(t, int32(1), atomic.LoadInt32(notified))
+}
+
+// Test batches are correlated by id
+func TestBatch(t *testing.T) {
+	url, notified := newWalletServer(t)
+	rpc := NewClient(rest.NewCustomRestClient(rest.Config{BaseURL: url}), "/rpc")
+
+	var sum int
+	var tx string
+	calls := []*BatchCall{
+		{Method: "add", Params: []int{1, 1}, Result: &sum},
+		{Method: "ping", Notification: true},
+		{Method: "transfer", Params: transferParams{From: "a", To: "b", Amount: 1}, Result: &tx},
+		{Method: "missing"},
+	}
+	assert.Nil(t, rpc.Batch(context.Background(), calls))
+	assert.Nil(t, calls[0].Error)
+	assert.Equal(t, 2, sum)
+	assert.Equal(t, "tx-1", tx)
+	var rpcErr *Error
+	assert.True(t, errors.As(calls[3].Error, &rpcErr))
+	assert.Equal(t, CodeMethodNotFound, rpcErr.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(notified))
+
+	assert.Nil(t, rpc.Batch(context.Background(), []*BatchCall{{Method: "ping", Notification: true}}))
+	assert.Equal(t, int32(2), atomic.LoadInt32(notified))
+}
+
+// Test the responses of the handler to invalid requests, as in the examples of the specification
+func TestHandlerInvalidRequests(t *testing.T) {
+	url, _ := newWalletServer(t)
+	post := func(body string) (int, string) {
+		res, err := http.Post(url+"/rpc", "application/json", strings.NewReader(body))
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		b, _ := io.ReadAll(res.Body)
+		return res.StatusCode, string(b)
+	}
+
+	_, body := post(`{"jsonrpc": "2.0", "method": "add", "params": "bar", "baz]`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`, body)
+
+	_, body = post(`{"jsonrpc": "2.0", "method": 1, "params": "bar"}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`, body)
+
+	_, body = post(`[]`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`, body)
+
+	_, body = post(`[1, {"jsonrpc": "2.0", "method": "add", "params": [1, 2], "id": "a"}]`)
+	assert.JSONEq(t, `[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},
+		{"jsonrpc":"2.0","result":3,"id":"a"}]`, body)
+
+	status, body := post(`{"jsonrpc": "2.0", "method": "ping"}`)
+	assert.Equal(t, 204, status)
+	assert.Empty(t, body)
+
+	_, body = post(`{"jsonrpc": "2.0", "method": "ping", "id": null}`)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","result":null,"id":null}`, body)
+}
@@ -0,0 +1,294 @@
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Validator is implemented by params that check their values after they are decoded
+type Validator interface {
+	Validate() error
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+type method struct {
+	fn        reflect.Value
+	params    reflect.Type
+	hasResult bool
+}
+
+// Server dispatches JSON-RPC calls to the registered functions
+type Server struct {
+	methods map[string]*method
+}
+
+// NewServer returns a server without methods
+func NewServer() *Server {
+	return &Server{methods: make(map[string]*method)}
+}
+
+// Register registers fn as the method name. fn must be one of
+//
+//	func(ctx context.Context) error
+//	func(ctx context.Context) (R, error)
+//	func(ctx context.Context, params P) error
+//	func(ctx context.Context, params P) (R, error)
+//
+// The params are decoded into P rejecting unknown fields. If P is a struct, positional params are assigned to its
+// fields in order. If P implements Validator it is called after decoding. Invalid params get a -32602 error.
+// Returning an *Error sends it as it is, any other error is sent as a -32603 without details.
+// Example:
+//
+//	type TransferParams struct {
+//		From   string `json:"from"`
+//		To     string `json:"to"`
+//		Amount int64  `json:"amount"`
+//	}
+//
+//	func (p TransferParams) Validate() error {
+//		if p.Amount <= 0 {
+//			return errors.New("amount must be positive")
+//		}
+//		return nil
+//	}
+//
+//	server.Register("wallet_transfer", func(ctx context.Context, p TransferParams) (string, error) { ... })
+func (s *Server) Register(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	t := v.Type()
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("jsonrpc: %s is not a function", name)
+	}
+	if t.NumIn() < 1 || t.NumIn() > 2 || t.In(0) != contextType {
+		return fmt.Errorf("jsonrpc: %s must receive a context and optionally the params", name)
+	}
+	if t.NumOut() < 1 || t.NumOut() > 2 || t.Out(t.NumOut()-1) != errorType {
+		return fmt.Errorf("jsonrpc: %s must return an error and optionally a result", name)
+	}
+	m := &method{fn: v, hasResult: t.NumOut() == 2}
+	if t.NumIn() == 2 {
+		m.params = t.In(1)
+	}
+	s.methods[name] = m
+	return nil
+}
+
+// MustRegister is like Register but panics if fn has an invalid signature
+func (s *Server) MustRegister(name string, fn interface{}) {
+	if err := s.Register(name, fn); err != nil {
+		panic(err)
+	}
+}
+
+// Handler returns a fiber handler that serves the registered methods, single calls and batches.
+// The context of the methods is the user context of the request.
+// Example:
+//
+//	server := jsonrpc.NewServer()
+//	server.MustRegister("wallet_balance", balance)
+//	app.Post("/rpc", oauthv2.Protected, server.Handler())
+func (s *Server) Handler() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body := bytes.TrimSpace(c.Body())
+		ctx := c.UserContext()
+
+		if len(body) > 0 && body[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(body, &batch); err != nil {
+				return c.JSON(errorResponse(null, NewError(CodeParseError, "Parse error", nil)))
+			}
+			if len(batch) == 0 {
+				return c.JSON(errorResponse(null, NewError(CodeInvalidRequest, "Invalid Request", nil)))
+			}
+			responses := make([]*response, 0, len(batch))
+			for _, raw := range batch {
+				if res := s.handle(ctx, raw); res != nil {
+					responses = append(responses, res)
+				}
+			}
+			if len(responses) == 0 {
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+			return c.JSON(responses)
+		}
+
+		if !json.Valid(body) {
+			return c.JSON(errorResponse(null, NewError(CodeParseError, "Parse error", nil)))
+		}
+		res := s.handle(ctx, body)
+		if res == nil {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+		return c.JSON(res)
+	}
+}
+
+// handle runs a single call, the response is nil for notifications
+func (s *Server) handle(ctx context.Context, raw json.RawMessage) *response {
+	var req serverRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != version || req.Method == "" || !validID(req.ID) {
+		id := null
+		if err == nil && validID(req.ID) && len(req.ID) > 0 {
+			id = req.ID
+		}
+		return errorResponse(id, NewError(CodeInvalidRequest, "Invalid Request", nil))
+	}
+
+	result, rpcErr := s.call(ctx, req)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	if rpcErr != nil {
+		return errorResponse(req.ID, rpcErr)
+	}
+	return &response{JSONRPC: version, Result: result, ID: req.ID}
+}
+
+func (s *Server) call(ctx context.Context, req serverRequest) (result json.RawMessage, rpcErr *Error) {
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return nil, NewError(CodeMethodNotFound, "Method not found", nil)
+	}
+
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	if m.params != nil {
+		params, err := decodeParams(req.Params, m.params)
+		if err != nil {
+			var e *Error
+			if errors.As(err, &e) {
+				return nil, e
+			}
+			return nil, NewError(CodeInvalidParams, "Invalid params", err.Error())
+		}
+		args = append(args, params)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("jsonrpc: panic in method %s: %v", req.Method, p)
+			result, rpcErr = nil, NewError(CodeInternalError, "Internal error", nil)
+		}
+	}()
+	out := m.fn.Call(args)
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return nil, e
+		}
+		log.Printf("jsonrpc: error in method %s: %v", req.Method, err)
+		return nil, NewError(CodeInternalError, "Internal error", nil)
+	}
+	if !m.hasResult {
+		return null, nil
+	}
+	b, err := json.Marshal(out[0].Interface())
+	if err != nil {
+		log.Printf("jsonrpc: error encoding the result of %s: %v", req.Method, err)
+		return nil, NewError(CodeInternalError, "Internal error", nil)
+	}
+	return b, nil
+}
+
+// decodeParams decodes by-name or by-position params into a new value of type t and validates it
+func decodeParams(raw json.RawMessage, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t)
+	raw = bytes.TrimSpace(raw)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, null):
+	case raw[0] == '[' && structType(t) != nil:
+		if err := decodePositional(raw, v.Elem()); err != nil {
+			return v, err
+		}
+	case raw[0] == '[' || raw[0] == '{':
+		decoder := json.NewDecoder(bytes.NewReader(raw))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(v.Interface()); err != nil {
+			return v, err
+		}
+	default:
+		return v, NewError(CodeInvalidRequest, "Invalid Request", "params must be an array or an object")
+	}
+
+	// Validate may have a value or a pointer receiver
+	if validator, ok := v.Elem().Interface().(Validator); ok && !isNilPtr(v.Elem()) {
+		if err := validator.Validate(); err != nil {
+			return v, err
+		}
+	} else if validator, ok := v.Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return v, err
+		}
+	}
+	return v.Elem(), nil
+}
+
+// decodePositional assigns the elements of the array to the exported fields of the struct in order
+func decodePositional(raw json.RawMessage, v reflect.Value) error {
+	var values []json.RawMessage
+	if err := json.Unmarshal(raw, &values); err != nil {
+		return err
+	}
+	if v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	fields := make([]int, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		if f.IsExported() && !strings.HasPrefix(f.Tag.Get("json"), "-") {
+			fields = append(fields, i)
+		}
+	}
+	if len(values) > len(fields) {
+		return fmt.Errorf("expected at most %d params, got %d", len(fields), len(values))
+	}
+	for i, value := range values {
+		if err := json.Unmarshal(value, v.Field(fields[i]).Addr().Interface()); err != nil {
+			return fmt.Errorf("param %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// structType returns the struct type of t or of the type pointed by t, nil if it is not a struct
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		return t
+	}
+	return nil
+}
+
+func isNilPtr(v reflect.Value) bool {
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
+
+// validID returns true if the id is absent, null, a string or a number
+func validID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+func errorResponse(id json.RawMessage, err *Error) *response {
+	return &response{JSONRPC: version, Error: err, ID: id}
+}
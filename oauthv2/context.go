@@ -0,0 +1,53 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// verifiedTokenKey is the local holding the bearer token once its signature, audience and scopes were validated
+const verifiedTokenKey = "verified_token"
+
+// GetPrincipal returns the authenticated caller of the request from the values injected by the middlewares.
+// Token is only set when the bearer token was validated by Protected or ProtectExternal, the claims of the
+// internal requests are injected without validation and their token is never forwarded
+func GetPrincipal(c *fiber.Ctx) rest.Principal {
+	p := rest.Principal{
+		Token:       localString(c, verifiedTokenKey),
+		Scopes:      localString(c, "scopes"),
+		ClientID:    localString(c, "client_id"),
+		Auth0Client: localString(c, "auth0_client"),
+		Email:       localString(c, "email"),
+	}
+	if audience, ok := c.Locals("audience").([]string); ok {
+		p.Audience = audience
+	}
+	return p
+}
+
+// Context returns the user context of the request carrying its authenticated caller, so it can be forwarded
+// by the rest clients configured with Config.ForwardIdentity
+// Example:
+//
+//	client.Get("/accounts").WithContext(oauth.Context(c)).Do()
+func Context(c *fiber.Ctx) context.Context {
+	return rest.ContextWithPrincipal(c.UserContext(), GetPrincipal(c))
+}
+
+// localString returns a copy of the string in the local key: the ones set from the headers share the buffers
+// of fasthttp, that are reused after the request while the Principal may be kept by a goroutine
+func localString(c *fiber.Ctx, key string) string {
+	v := c.Locals(key)
+	if v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return strings.Clone(s)
+	}
+	return fmt.Sprintf("%v", v)
+}
@@ -0,0 +1,140 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// Test the caller of an internal request is forwarded to the outbound calls, except its token that was not validated
+func TestContextForwardsPrincipal(t *testing.T) {
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer upstream.Close()
+	client := rest.NewCustomRestClient(rest.Config{
+		BaseURL:         upstream.URL,
+		ForwardIdentity: rest.ForwardBearerToken | rest.ForwardInternalHeaders,
+	})
+
+	app := fiber.New()
+	app.Get("/", ProtectExternal, func(c *fiber.Ctx) error {
+		p := GetPrincipal(c)
+		assert.Equal(t, "cli-1", p.ClientID)
+		assert.Equal(t, "read", p.Scopes)
+		return c.SendStatus(client.Get("/accounts").WithContext(Context(c)).Do().StatusCode)
+	})
+
+	token := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"azp":"auth0-1"}`)) + "."
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Scopes", "read")
+	req.Header.Set("X-Client-Id", "cli-1")
+
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Empty(t, received.Get("Authorization"))
+	assert.Equal(t, "read", received.Get("X-Scopes"))
+	assert.Equal(t, "cli-1", received.Get("X-Client-Id"))
+	assert.Equal(t, "auth0-1", received.Get("X-Auth0-Client"))
+}
+
+// Test the principal kept after the request does not change when the request buffers are reused
+func TestPrincipalOutlivesRequest(t *testing.T) {
+	var kept []rest.Principal
+	app := fiber.New()
+	app.Get("/", ProtectExternal, func(c *fiber.Ctx) error {
+		kept = append(kept, GetPrincipal(c))
+		return c.SendStatus(http.StatusOK)
+	})
+
+	for _, clientID := range []string{"cli-1", "cli-2"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Scopes", "read")
+		req.Header.Set("X-Client-Id", clientID)
+		_, err := app.Test(req)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, "cli-1", kept[0].ClientID)
+	assert.Equal(t, "cli-2", kept[1].ClientID)
+}
+
+// signedToken returns a RS256 token with the given claims and configures its key in the jwks
+func signedToken(t *testing.T, claims jwt.MapClaims) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t, err)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+
+	previousJwks, previousAudiences := jwks, AudiencesAllowed
+	jwks = Jwks{Keys: []JSONWebKeys{{Kid: "test", X5c: []string{base64.StdEncoding.EncodeToString(cert)}}}}
+	AudiencesAllowed = []string{"api"}
+	t.Cleanup(func() { jwks, AudiencesAllowed = previousJwks, previousAudiences })
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+	signed, err := token.SignedString(key)
+	assert.Nil(t, err)
+	return signed
+}
+
+// Test the validated token of an external request is forwarded
+func TestContextForwardsValidatedToken(t *testing.T) {
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer upstream.Close()
+	client := rest.NewCustomRestClient(rest.Config{BaseURL: upstream.URL, ForwardIdentity: rest.ForwardBearerToken})
+
+	app := fiber.New()
+	app.Get("/", ProtectExternal, func(c *fiber.Ctx) error {
+		return c.SendStatus(client.Get("/accounts").WithContext(Context(c)).Do().StatusCode)
+	})
+
+	token := signedToken(t, jwt.MapClaims{"aud": "api", "scope": "read"})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-External", "true")
+
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "Bearer "+token, received.Get("Authorization"))
+}
+
+// Test rejected requests get a problem details response
+func TestRejectionsAreProblems(t *testing.T) {
+	app := fiber.New()
+	app.Get("/protected", Protected, func(c *fiber.Ctx) error { return c.SendStatus(200) })
+	app.Get("/internal", DenyExternal, func(c *fiber.Ctx) error { return c.SendStatus(200) })
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, rest.ProblemContentType, res.Header.Get("Content-Type"))
+
+	req = httptest.NewRequest(http.MethodGet, "/internal", nil)
+	req.Header.Set("X-External", "true")
+	res, err = app.Test(req)
+	assert.Nil(t, err)
+	assert.Equal(t, 403, res.StatusCode)
+	assert.Equal(t, rest.ProblemContentType, res.Header.Get("Content-Type"))
+}
@@ -13,8 +13,13 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/abraham-corales/go-lib/problem"
 )
 
+// Response was the body of the error responses.
+//
+// Deprecated: the errors are sent as problem details, see the problem package
 type Response struct {
 	Message string `json:"message"`
 }
@@ -95,12 +100,12 @@ func Initialize() {
 
 // Protected does check your JWT token and validates it
 // If the token is valid or if the environment is local it will call the next handler
-// If the token is invalid, it will return a 401
+// If the token is invalid, it will return a 401 problem details response
 func Protected(c *fiber.Ctx) error {
 	if checkJWT(c) || isLocal(getEnvironment()) {
 		return c.Next()
 	}
-	return c.Status(fiber.StatusUnauthorized).SendString("Unauthorized.")
+	return problem.Send(c, problem.New(fiber.StatusUnauthorized, "A valid bearer token is required."))
 }
 
 // ProtectExternal checks if the request is external or not, if it is external it will check the JWT token
@@ -118,14 +123,14 @@ func ProtectExternal(c *fiber.Ctx) error {
 	if isValid || isLocal(getEnvironment()) {
 		return c.Next()
 	}
-	return c.Status(fiber.StatusUnauthorized).JSON(Response{Message: "Unauthorized."})
+	return problem.Send(c, problem.New(fiber.StatusUnauthorized, "A valid bearer token is required."))
 }
 
 // DenyExternal checks if the request is external or not, if it is external it will return a 403
 func DenyExternal(c *fiber.Ctx) error {
 	isExternal := c.Get("X-External") == "true"
 	if isExternal {
-		return c.Status(fiber.StatusForbidden).JSON(Response{Message: "Forbidden."})
+		return problem.Send(c, problem.New(fiber.StatusForbidden, "External requests are not allowed."))
 	}
 	return c.Next()
 }
@@ -234,6 +239,8 @@ func checkJWT(c *fiber.Ctx) bool {
 
 	// Adding scopes and client_id to context
 	injectContextValues(c, claims)
+	// only a validated token can be forwarded to other services
+	c.Locals(verifiedTokenKey, token)
 
 	// call injectHeaders func if internal audience
 	if strings.Contains(claims["aud"].(string), "internal") {
@@ -0,0 +1,60 @@
+// Package problem renders errors of fiber handlers as RFC 9457 problem details (application/problem+json),
+// the format decoded by rest.Response into a rest.ProblemDetails.
+package problem
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// New returns a problem with the status and its default title.
+// Example:
+//
+//	return problem.New(fiber.StatusNotFound, "Account 123 does not exist.")
+func New(status int, detail string) *rest.ProblemDetails {
+	return &rest.ProblemDetails{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// Send writes p as the response. The status defaults to 500 and the instance to the path of the request
+func Send(c *fiber.Ctx, p *rest.ProblemDetails) error {
+	out := *p
+	if out.Status == 0 {
+		out.Status = fiber.StatusInternalServerError
+	}
+	if out.Title == "" && (out.Type == "" || out.Type == "about:blank") {
+		out.Title = http.StatusText(out.Status)
+	}
+	if out.Instance == "" {
+		out.Instance = c.Path()
+	}
+	return c.Status(out.Status).JSON(out, rest.ProblemContentType)
+}
+
+// ErrorHandler is a fiber error handler that renders the errors returned by the handlers as problem details.
+// A rest.ProblemDetails is sent as it is, a *fiber.Error keeps its status and message
+// and any other error is a 500 without details, so internal messages are not leaked.
+// Example:
+//
+//	app := fiber.New(fiber.Config{ErrorHandler: problem.ErrorHandler})
+func ErrorHandler(c *fiber.Ctx, err error) error {
+	var p *rest.ProblemDetails
+	if errors.As(err, &p) {
+		return Send(c, p)
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		return Send(c, New(fe.Code, fe.Message))
+	}
+	log.Printf("Error handling %s %s: %v", c.Method(), c.Path(), err)
+	return Send(c, New(fiber.StatusInternalServerError, ""))
+}
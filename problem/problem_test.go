@@ -0,0 +1,56 @@
+package problem
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// Test the errors of the handlers are rendered as problems readable by RestClient
+func TestErrorHandler(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler})
+	app.Get("/problem", func(c *fiber.Ctx) error {
+		p := New(fiber.StatusConflict, "Order 1 is already paid.")
+		p.Extensions = map[string]interface{}{"order_id": "1"}
+		return fmt.Errorf("paying order: %w", p)
+	})
+	app.Get("/fiber", func(c *fiber.Ctx) error {
+		return fiber.NewError(fiber.StatusBadRequest, "Missing amount.")
+	})
+	app.Get("/internal", func(c *fiber.Ctx) error {
+		return errors.New("db password is wrong")
+	})
+	server := httptest.NewServer(adaptor.FiberApp(app))
+	defer server.Close()
+	client := rest.NewCustomRestClient(rest.Config{BaseURL: server.URL})
+
+	res := client.Get("/problem").Do()
+	assert.Equal(t, rest.ProblemContentType, res.Headers.Get("Content-Type"))
+	p, ok := res.Problem()
+	assert.True(t, ok)
+	assert.Equal(t, &rest.ProblemDetails{Type: "about:blank", Title: "Conflict", Status: 409, Detail: "Order 1 is already paid.",
+		Instance: "/problem", Extensions: map[string]interface{}{"order_id": "1"}}, p)
+
+	p, ok = client.Get("/fiber").Do().Problem()
+	assert.True(t, ok)
+	assert.Equal(t, 400, p.Status)
+	assert.Equal(t, "Missing amount.", p.Detail)
+
+	res = client.Get("/internal").Do()
+	p, ok = res.Problem()
+	assert.True(t, ok)
+	assert.Equal(t, 500, p.Status)
+	assert.Equal(t, "Internal Server Error", p.Title)
+	assert.NotContains(t, string(res.BodyBytes), "password")
+
+	p, ok = client.Get("/missing").Do().Problem()
+	assert.True(t, ok)
+	assert.Equal(t, 404, p.Status)
+}
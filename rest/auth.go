@@ -0,0 +1,45 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+)
+
+// TokenSource provides the bearer token that the client injects in the Authorization header of every request
+// that does not set one explicitly with WithAuthorizationToken nor forwards the one of its caller
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// InvalidatingTokenSource is a TokenSource that can discard a token rejected by the server.
+// When a request authorized by it receives a 401, the token is invalidated and the request is retried once
+type InvalidatingTokenSource interface {
+	TokenSource
+	Invalidate(token string)
+}
+
+// WithTokenSource sets the source of the Authorization header of the requests
+func (c *RestClient) WithTokenSource(ts TokenSource) *RestClient {
+	c.TokenSource = ts
+	return c
+}
+
+// context returns the context of the request, context.Background if none was set
+func (r *Request) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+	return r.ctx
+}
+
+// RequestSigner signs the http request right before it is sent, once every header was set.
+// body is the exact payload of the request
+type RequestSigner interface {
+	Sign(req *http.Request, body []byte) error
+}
+
+// WithSigners adds signers that are applied, in order, to every request of the client
+func (c *RestClient) WithSigners(s ...RequestSigner) *RestClient {
+	c.Signers = append(c.Signers, s...)
+	return c
+}
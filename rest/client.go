@@ -16,22 +16,49 @@ type Client interface {
 	Get(url string) *Request
 	Put(url string, body interface{}) *Request
 	Post(url string, body interface{}) *Request
+	Patch(url string, body interface{}) *Request
+	Delete(url string) *Request
+	Head(url string) *Request
+	Options(url string) *Request
 	Request(method string, url string, body interface{}) *Request
 }
 
+var (
+	_ Client = (*RestClient)(nil)
+	_ Client = (*MockClient)(nil)
+)
+
 type RestClient struct {
 	*httpclient.Client
-	ReqInterceptors []RequestInterceptor  //Does not work yet
-	ResInterceptors []ResponseInterceptor //Does not work yet
+	ReqInterceptors []RequestInterceptor
+	ResInterceptors []ResponseInterceptor
 	Config          Config
 	Cache           cache.Spec
+	Debug           *DebugRecorder
+	TokenSource     TokenSource
+	Signers         []RequestSigner
+	endpoints       *endpointPool
 }
 
 type Config struct {
 	BaseURL         string
 	TimeoutInMillis int
 	DefaultHeaders  http.Header
-	Retries         int
+	// Retries is the number of times the idempotent requests failed with a transport error or a 5xx are retried,
+	// unless the request sets its own with WithRetries
+	Retries int
+	// Transport overrides the default http transport of the client. Useful to plug in recorders or custom TLS settings
+	Transport http.RoundTripper
+	// BaseURLs are several endpoints serving the same API. When set, each request is sent to the one chosen by EndpointPolicy
+	// and idempotent requests fail over to the next endpoint
+	BaseURLs       []string
+	EndpointPolicy EndpointPolicy
+	// EjectAfterFailures is the number of consecutive failures (transport errors or 5xx) that eject an endpoint. Defaults to 3
+	EjectAfterFailures int
+	// EjectionDuration is the time an ejected endpoint stays out of the pool. Defaults to 30 seconds
+	EjectionDuration time.Duration
+	// ForwardIdentity defines what is forwarded from the Principal of the request context. Defaults to ForwardNothing
+	ForwardIdentity IdentityForwarding
 }
 
 func NewDefaultRestClient() *RestClient {
@@ -50,22 +77,29 @@ func NewDefaultRestClient() *RestClient {
 }
 
 func NewCustomRestClient(cfg Config) *RestClient {
-	customTransport := http.DefaultTransport
-	//nolint:gosec // need insecure TLS option for testing while noto resolves the certificate issue
-	customTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	customTransport := cfg.Transport
+	if customTransport == nil {
+		customTransport = http.DefaultTransport
+		//nolint:gosec // need insecure TLS option for testing while noto resolves the certificate issue
+		customTransport.(*http.Transport).TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
 
 	client := http.Client{
 		Timeout:   time.Duration(cfg.TimeoutInMillis) * time.Millisecond,
 		Transport: customTransport,
 	}
 
-	return &RestClient{
+	restClient := &RestClient{
 		Client: httpclient.NewClient(httpclient.WithHTTPClient(&client)),
 		Config: cfg,
 	}
+	if len(cfg.BaseURLs) > 0 {
+		restClient.endpoints = newEndpointPool(cfg.BaseURLs, cfg.EndpointPolicy, cfg.EjectAfterFailures, cfg.EjectionDuration)
+	}
+	return restClient
 }
 
-// RequestInterceptor is a function that can be used to modify the request or the response
+// RequestInterceptor is a function that can be used to modify the request and executes before it is sent
 type RequestInterceptor func(request *Request)
 
 func (c *RestClient) WithRequestInterceptors(i ...RequestInterceptor) *RestClient {
@@ -107,6 +141,39 @@ func (c *RestClient) Post(url string, body interface{}) *Request {
 	}
 }
 
+func (c *RestClient) Patch(url string, body interface{}) *Request {
+	return &Request{
+		Method: http.MethodPatch,
+		URL:    url,
+		Body:   body,
+		client: c,
+	}
+}
+
+func (c *RestClient) Delete(url string) *Request {
+	return &Request{
+		Method: http.MethodDelete,
+		URL:    url,
+		client: c,
+	}
+}
+
+func (c *RestClient) Head(url string) *Request {
+	return &Request{
+		Method: http.MethodHead,
+		URL:    url,
+		client: c,
+	}
+}
+
+func (c *RestClient) Options(url string) *Request {
+	return &Request{
+		Method: http.MethodOptions,
+		URL:    url,
+		client: c,
+	}
+}
+
 func (c *RestClient) Request(method string, url string, body interface{}) *Request {
 	return &Request{
 		Method: method,
@@ -0,0 +1,103 @@
+package rest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+	"github.com/abraham-corales/go-lib/rest/resttest"
+)
+
+// contractBackend is an implementation of rest.Client under test
+type contractBackend struct {
+	client rest.Client
+	// stub sets the response of method+url
+	stub func(method, url string, statusCode int, body string)
+	// lastBody returns the body received in the last request
+	lastBody func() string
+}
+
+func newRestClientBackend(t *testing.T) contractBackend {
+	server := resttest.NewServer(t)
+	return contractBackend{
+		client: server.Client(),
+		stub: func(method, url string, statusCode int, body string) {
+			server.On(method, url).Reply(statusCode, body)
+		},
+		lastBody: func() string {
+			requests := server.Requests()
+			return string(requests[len(requests)-1].Body)
+		},
+	}
+}
+
+func newMockClientBackend(t *testing.T) contractBackend {
+	client := rest.NewDefaultMockClient(t)
+	return contractBackend{
+		client: client,
+		stub: func(method, url string, statusCode int, body string) {
+			client.SetMockCall(method, url, rest.MockResponse{StatusCode: statusCode, JSONBody: body})
+		},
+		lastBody: func() string {
+			stack := client.GetRequestStack()
+			body := stack[len(stack)-1].RequestBody
+			if body == nil {
+				return ""
+			}
+			b, _ := json.Marshal(body)
+			return string(b)
+		},
+	}
+}
+
+// Test every rest.Client implementation honors the same contract
+func TestClientContract(t *testing.T) {
+	backends := map[string]func(t *testing.T) contractBackend{
+		"RestClient": newRestClientBackend,
+		"MockClient": newMockClientBackend,
+	}
+	payload := map[string]string{"name": "john"}
+
+	cases := []struct {
+		method   string
+		status   int
+		body     string
+		withBody bool
+		do       func(c rest.Client) *rest.Request
+	}{
+		{http.MethodGet, 200, `{"id":1}`, false, func(c rest.Client) *rest.Request { return c.Get("/users") }},
+		{http.MethodPost, 201, `{"id":1}`, true, func(c rest.Client) *rest.Request { return c.Post("/users", payload) }},
+		{http.MethodPut, 200, `{"id":1}`, true, func(c rest.Client) *rest.Request { return c.Put("/users", payload) }},
+		{http.MethodPatch, 200, `{"id":1}`, true, func(c rest.Client) *rest.Request { return c.Patch("/users", payload) }},
+		{http.MethodDelete, 204, ``, false, func(c rest.Client) *rest.Request { return c.Delete("/users") }},
+		{http.MethodHead, 200, ``, false, func(c rest.Client) *rest.Request { return c.Head("/users") }},
+		{http.MethodOptions, 204, ``, false, func(c rest.Client) *rest.Request { return c.Options("/users") }},
+		{"PURGE", 200, `{}`, true, func(c rest.Client) *rest.Request { return c.Request("PURGE", "/users", payload) }},
+	}
+
+	for name, newBackend := range backends {
+		for _, tc := range cases {
+			t.Run(name+"/"+tc.method, func(t *testing.T) {
+				backend := newBackend(t)
+				backend.stub(tc.method, "/users", tc.status, tc.body)
+
+				req := tc.do(backend.client)
+				assert.Equal(t, tc.method, req.Method)
+				assert.Equal(t, "/users", req.URL)
+
+				res := req.Do()
+				assert.Nil(t, res.Error)
+				assert.Equal(t, tc.status, res.StatusCode)
+				assert.Equal(t, tc.body, string(res.BodyBytes))
+				if tc.withBody {
+					assert.JSONEq(t, `{"name":"john"}`, backend.lastBody())
+				} else {
+					assert.Empty(t, backend.lastBody())
+				}
+			})
+		}
+	}
+}
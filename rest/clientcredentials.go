@@ -0,0 +1,199 @@
+package rest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenExpiryDelta = 30 * time.Second
+	defaultTokenTimeout     = 10 * time.Second
+)
+
+// ClientCredentialsConfig configures a ClientCredentialsTokenSource
+type ClientCredentialsConfig struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	// Audience is sent as the audience parameter, required by providers like Auth0
+	Audience string
+	Scopes   []string
+	// AuthInParams sends the client credentials in the form body instead of the HTTP Basic Authorization header
+	AuthInParams bool
+	// ExpiryDelta is how long before the expiration the token is refreshed. Defaults to 30 seconds.
+	// It is capped to half the lifetime of the token, so short-lived tokens are not requested on every call
+	ExpiryDelta time.Duration
+	// HTTPClient is used to request the tokens. Defaults to a client with a 10 seconds timeout
+	HTTPClient *http.Client
+}
+
+// TokenError is the error response of the token endpoint (RFC 6749 section 5.2)
+type TokenError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *TokenError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth2: token request failed with status %d: %s: %s", e.StatusCode, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth2: token request failed with status %d: %s", e.StatusCode, e.Code)
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+type tokenCall struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+// ClientCredentialsTokenSource is a TokenSource implementing the OAuth2 client credentials grant (RFC 6749 section 4.4).
+// Tokens are cached until shortly before they expire and concurrent refreshes are collapsed into a single request.
+// Example:
+//
+//	ts := rest.NewClientCredentialsTokenSource(rest.ClientCredentialsConfig{
+//		TokenURL:     "https://auth.example.com/oauth/token",
+//		ClientID:     os.Getenv("CLIENT_ID"),
+//		ClientSecret: os.Getenv("CLIENT_SECRET"),
+//		Audience:     "https://api.example.com",
+//	})
+//	client := rest.NewCustomRestClient(cfg).WithTokenSource(ts)
+type ClientCredentialsTokenSource struct {
+	cfg ClientCredentialsConfig
+	now func() time.Time
+
+	mu       sync.Mutex
+	token    string
+	expiry   time.Time
+	inflight *tokenCall
+}
+
+// NewClientCredentialsTokenSource creates a new ClientCredentialsTokenSource
+func NewClientCredentialsTokenSource(cfg ClientCredentialsConfig) *ClientCredentialsTokenSource {
+	if cfg.ExpiryDelta <= 0 {
+		cfg.ExpiryDelta = defaultTokenExpiryDelta
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: defaultTokenTimeout}
+	}
+	return &ClientCredentialsTokenSource{cfg: cfg, now: time.Now}
+}
+
+// Token returns the cached token or requests a new one if it is about to expire
+func (s *ClientCredentialsTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.token != "" && (s.expiry.IsZero() || s.now().Before(s.expiry)) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+	call := s.inflight
+	if call == nil {
+		call = &tokenCall{done: make(chan struct{})}
+		s.inflight = call
+		// the refresh is shared by every waiting caller, so it must not be canceled by the one that started it
+		go s.refresh(context.WithoutCancel(ctx), call)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Invalidate discards token if it is the cached one, so the next call to Token requests a new one
+func (s *ClientCredentialsTokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+		s.expiry = time.Time{}
+	}
+}
+
+func (s *ClientCredentialsTokenSource) refresh(ctx context.Context, call *tokenCall) {
+	res, err := s.requestToken(ctx)
+
+	s.mu.Lock()
+	if err == nil {
+		s.token = res.AccessToken
+		// tokens without expires_in are kept until the server rejects them
+		s.expiry = time.Time{}
+		if res.ExpiresIn > 0 {
+			lifetime := time.Duration(res.ExpiresIn) * time.Second
+			s.expiry = s.now().Add(lifetime - min(s.cfg.ExpiryDelta, lifetime/2))
+		}
+		call.token = res.AccessToken
+	}
+	call.err = err
+	s.inflight = nil
+	s.mu.Unlock()
+	close(call.done)
+}
+
+func (s *ClientCredentialsTokenSource) requestToken(ctx context.Context) (*tokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+	if s.cfg.Audience != "" {
+		form.Set("audience", s.cfg.Audience)
+	}
+	if s.cfg.AuthInParams {
+		form.Set("client_id", s.cfg.ClientID)
+		form.Set("client_secret", s.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !s.cfg.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+	}
+
+	res, err := s.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		tokenErr := &TokenError{StatusCode: res.StatusCode}
+		if json.Unmarshal(body, tokenErr) != nil || tokenErr.Code == "" {
+			tokenErr.Code = http.StatusText(res.StatusCode)
+		}
+		return nil, tokenErr
+	}
+
+	var token tokenResponse
+	if err := json.Unmarshal(body, &token); err != nil {
+		return nil, fmt.Errorf("oauth2: invalid token response: %w", err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2: token response without access_token")
+	}
+	return &token, nil
+}
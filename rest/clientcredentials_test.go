@@ -0,0 +1,138 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTokenServer(t *testing.T, hits *int32, expiresIn int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "client" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"bad credentials"}`))
+			return
+		}
+		assert.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		assert.Equal(t, "read write", r.FormValue("scope"))
+		assert.Equal(t, "https://api.example.com", r.FormValue("audience"))
+		time.Sleep(10 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token-` + strconv.Itoa(int(n)) + `","token_type":"Bearer","expires_in":` + strconv.Itoa(expiresIn) + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestTokenSource(tokenURL, secret string) *ClientCredentialsTokenSource {
+	return NewClientCredentialsTokenSource(ClientCredentialsConfig{
+		TokenURL:     tokenURL,
+		ClientID:     "client",
+		ClientSecret: secret,
+		Audience:     "https://api.example.com",
+		Scopes:       []string{"read", "write"},
+	})
+}
+
+// Test concurrent callers share a single token request and the token is cached
+func TestClientCredentialsSingleflight(t *testing.T) {
+	var hits int32
+	ts := newTestTokenSource(newTokenServer(t, &hits, 3600).URL, "secret")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.Token(t.Context())
+			assert.Nil(t, err)
+			assert.Equal(t, "token-1", token)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), hits)
+}
+
+// Test the token is refreshed shortly before it expires
+func TestClientCredentialsRefreshBeforeExpiry(t *testing.T) {
+	var hits int32
+	ts := newTestTokenSource(newTokenServer(t, &hits, 60).URL, "secret")
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	token, _ := ts.Token(t.Context())
+	assert.Equal(t, "token-1", token)
+
+	now = now.Add(29 * time.Second)
+	token, _ = ts.Token(t.Context())
+	assert.Equal(t, "token-1", token)
+
+	now = now.Add(2 * time.Second)
+	token, _ = ts.Token(t.Context())
+	assert.Equal(t, "token-2", token)
+}
+
+// Test the expiry delta is capped to half the lifetime of short-lived tokens
+func TestClientCredentialsShortLivedToken(t *testing.T) {
+	var hits int32
+	ts := newTestTokenSource(newTokenServer(t, &hits, 20).URL, "secret")
+	now := time.Now()
+	ts.now = func() time.Time { return now }
+
+	ts.Token(t.Context())
+	now = now.Add(9 * time.Second)
+	token, _ := ts.Token(t.Context())
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+
+	now = now.Add(2 * time.Second)
+	token, _ = ts.Token(t.Context())
+	assert.Equal(t, "token-2", token)
+}
+
+// Test token endpoint errors are typed
+func TestClientCredentialsError(t *testing.T) {
+	var hits int32
+	ts := newTestTokenSource(newTokenServer(t, &hits, 60).URL, "wrong")
+
+	_, err := ts.Token(t.Context())
+	tokenErr, ok := err.(*TokenError)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, tokenErr.StatusCode)
+	assert.Equal(t, "invalid_client", tokenErr.Code)
+}
+
+// Test the client injects the token and retries once with a new one on 401
+func TestRestClientTokenSourceRetriesOn401(t *testing.T) {
+	var tokenHits int32
+	ts := newTestTokenSource(newTokenServer(t, &tokenHits, 3600).URL, "secret")
+
+	var apiHits int32
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&apiHits, 1)
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(api.Close)
+
+	client := NewCustomRestClient(Config{BaseURL: api.URL}).WithTokenSource(ts)
+	res := client.Get("/users").Do()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, int32(2), tokenHits)
+	assert.Equal(t, int32(2), apiHits)
+
+	res = client.Get("/users").WithAuthorizationToken("explicit").Do()
+	assert.Equal(t, 401, res.StatusCode)
+	assert.Equal(t, int32(3), apiHits)
+}
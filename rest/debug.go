@@ -0,0 +1,345 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDebugBufferSize = 100
+	defaultDebugBodyLimit  = 4096
+)
+
+// DebugEntry is a single request/response exchange captured by a DebugRecorder
+type DebugEntry struct {
+	StartedAt       time.Time
+	Duration        time.Duration
+	Method          string
+	URL             string
+	RequestHeaders  http.Header
+	RequestBody     string
+	StatusCode      int
+	ResponseHeaders http.Header
+	ResponseBody    string
+	Error           string
+}
+
+// DebugRecorder captures every request and response made by a RestClient into a fixed size ring buffer.
+// Bodies are truncated to MaxBodyBytes and secrets are redacted before being stored.
+type DebugRecorder struct {
+	// MaxBodyBytes is the max number of bytes stored for each body. Defaults to 4096
+	MaxBodyBytes int
+	// RedactHeaders are the headers whose values are replaced by [REDACTED]
+	RedactHeaders []string
+	// RedactFields are the JSON body fields, form fields and query params whose values are replaced by [REDACTED]
+	RedactFields []string
+
+	mu      sync.Mutex
+	entries []DebugEntry
+	next    int
+	full    bool
+}
+
+// NewDebugRecorder creates a new DebugRecorder that keeps the last size exchanges
+func NewDebugRecorder(size int) *DebugRecorder {
+	if size <= 0 {
+		size = defaultDebugBufferSize
+	}
+	return &DebugRecorder{
+		MaxBodyBytes:  defaultDebugBodyLimit,
+		RedactHeaders: defaultRedactedHeaders,
+		RedactFields:  defaultRedactedFields,
+		entries:       make([]DebugEntry, size),
+	}
+}
+
+// WithDebug enables the debug mode of the client. Every request done by the client will be captured by rec
+func (c *RestClient) WithDebug(rec *DebugRecorder) *RestClient {
+	c.Debug = rec
+	return c
+}
+
+// Entries returns the captured exchanges, oldest first
+func (d *DebugRecorder) Entries() []DebugEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.full {
+		return append([]DebugEntry(nil), d.entries[:d.next]...)
+	}
+	res := make([]DebugEntry, 0, len(d.entries))
+	res = append(res, d.entries[d.next:]...)
+	return append(res, d.entries[:d.next]...)
+}
+
+// Reset removes all the captured exchanges
+func (d *DebugRecorder) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries = make([]DebugEntry, len(d.entries))
+	d.next = 0
+	d.full = false
+}
+
+func (d *DebugRecorder) capture(req *http.Request, reqBody []byte, res *Response, started time.Time) {
+	if d == nil || req == nil {
+		return
+	}
+	entry := DebugEntry{
+		StartedAt:      started,
+		Duration:       time.Duration(res.Duration) * time.Millisecond,
+		Method:         req.Method,
+		URL:            d.redactor().url(req.URL),
+		RequestHeaders: d.redactor().header(req.Header),
+		RequestBody:    d.redactBody(reqBody, req.Header.Get("Content-Type")),
+		StatusCode:     res.StatusCode,
+	}
+	if res.Headers != nil {
+		entry.ResponseHeaders = d.redactor().header(res.Headers)
+	}
+	if res.BodyBytes != nil {
+		entry.ResponseBody = d.redactBody(res.BodyBytes, res.Headers.Get("Content-Type"))
+	}
+	if res.Error != nil {
+		entry.Error = res.Error.Error()
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.entries[d.next] = entry
+	d.next = (d.next + 1) % len(d.entries)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+func (d *DebugRecorder) redactor() redactor {
+	return redactor{headers: d.RedactHeaders, fields: d.RedactFields}
+}
+
+func (d *DebugRecorder) redactBody(b []byte, contentType string) string {
+	if len(b) == 0 {
+		return ""
+	}
+	b = d.redactor().body(b, contentType)
+	limit := d.MaxBodyBytes
+	if limit <= 0 {
+		limit = defaultDebugBodyLimit
+	}
+	if len(b) > limit {
+		return string(b[:limit]) + fmt.Sprintf("...[truncated %d bytes]", len(b)-limit)
+	}
+	return string(b)
+}
+
+// Curl returns the curl command equivalent to the captured request
+func (e DebugEntry) Curl() string {
+	return curlCommand(e.Method, e.URL, e.RequestHeaders, e.RequestBody)
+}
+
+// Curl returns the curl command equivalent to the request. Secrets are NOT redacted
+func (r *Request) Curl() string {
+	u := r.baseURLOrDefault() + r.URL
+	headers := r.Headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	if r.AuthorizationToken != nil {
+		headers.Set("Authorization", "Bearer "+*r.AuthorizationToken)
+	}
+	body := ""
+	if r.Body != nil {
+		b, err := json.Marshal(r.Body)
+		if err == nil {
+			body = string(b)
+		}
+	}
+	return curlCommand(r.Method, u, headers, body)
+}
+
+func curlCommand(method, u string, headers http.Header, body string) string {
+	var sb strings.Builder
+	sb.WriteString("curl -X " + method + " " + shellQuote(u))
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		sb.WriteString(" -H " + shellQuote(k+": "+strings.Join(headers[k], ",")))
+	}
+	if body != "" {
+		sb.WriteString(" --data-raw " + shellQuote(body))
+	}
+	return sb.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harBody        `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harBody struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// HAR exports the captured exchanges as HAR 1.2 JSON
+func (d *DebugRecorder) HAR() ([]byte, error) {
+	entries := d.Entries()
+	har := harLog{Log: harContent{
+		Version: "1.2",
+		Creator: harCreator{Name: "go-lib/rest", Version: "1.0"},
+		Entries: make([]harEntry, 0, len(entries)),
+	}}
+	for _, e := range entries {
+		ms := float64(e.Duration) / float64(time.Millisecond)
+		req := harRequest{
+			Method:      e.Method,
+			URL:         e.URL,
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.RequestHeaders),
+			QueryString: harQuery(e.URL),
+			HeadersSize: -1,
+			BodySize:    len(e.RequestBody),
+		}
+		if e.RequestBody != "" {
+			req.PostData = &harPostData{MimeType: mimeType(e.RequestHeaders), Text: e.RequestBody}
+		}
+		har.Log.Entries = append(har.Log.Entries, harEntry{
+			StartedDateTime: e.StartedAt.Format(time.RFC3339Nano),
+			Time:            ms,
+			Request:         req,
+			Response: harResponse{
+				Status:      e.StatusCode,
+				StatusText:  http.StatusText(e.StatusCode),
+				HTTPVersion: "HTTP/1.1",
+				Cookies:     []harNameValue{},
+				Headers:     harHeaders(e.ResponseHeaders),
+				Content: harBody{
+					Size:     len(e.ResponseBody),
+					MimeType: mimeType(e.ResponseHeaders),
+					Text:     e.ResponseBody,
+				},
+				HeadersSize: -1,
+				BodySize:    len(e.ResponseBody),
+			},
+			Timings: harTimings{Send: 0, Wait: ms, Receive: 0},
+			Comment: e.Error,
+		})
+	}
+	return json.Marshal(har)
+}
+
+func harHeaders(h http.Header) []harNameValue {
+	res := []harNameValue{}
+	keys := make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range h[k] {
+			res = append(res, harNameValue{Name: k, Value: v})
+		}
+	}
+	return res
+}
+
+func harQuery(rawURL string) []harNameValue {
+	res := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return res
+	}
+	q := u.Query()
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range q[k] {
+			res = append(res, harNameValue{Name: k, Value: v})
+		}
+	}
+	return res
+}
+
+func mimeType(h http.Header) string {
+	if ct := h.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=abc")
+		_, _ = w.Write([]byte(`{"access_token":"secret-token","name":"` + strings.Repeat("a", 64) + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test the debug recorder captures and redacts the exchanges
+func TestDebugRecorderRedactsAndTruncates(t *testing.T) {
+	server := newEchoServer(t)
+	rec := NewDebugRecorder(10)
+	rec.MaxBodyBytes = 40
+	client := NewCustomRestClient(Config{BaseURL: server.URL}).WithDebug(rec)
+
+	res := client.Post("/login?api_key=123&page=1", map[string]string{"user": "john", "password": "1234"}).
+		WithAuthorizationToken("my-token").
+		Do()
+	assert.Nil(t, res.Error)
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, http.MethodPost, entry.Method)
+	assert.Equal(t, redactedValue, entry.RequestHeaders.Get("Authorization"))
+	assert.Equal(t, redactedValue, entry.ResponseHeaders.Get("Set-Cookie"))
+	assert.Contains(t, entry.URL, "api_key=%5BREDACTED%5D")
+	assert.Contains(t, entry.URL, "page=1")
+	assert.JSONEq(t, `{"user":"john","password":"[REDACTED]"}`, entry.RequestBody)
+	assert.NotContains(t, entry.ResponseBody, "secret-token")
+	assert.Contains(t, entry.ResponseBody, "...[truncated")
+	assert.Equal(t, 200, entry.StatusCode)
+}
+
+// Test the credentials in the userinfo of the URL are redacted
+func TestDebugRecorderRedactsUserinfo(t *testing.T) {
+	server := newEchoServer(t)
+	rec := NewDebugRecorder(10)
+	client := NewCustomRestClient(Config{BaseURL: strings.Replace(server.URL, "http://", "http://john:1234@", 1)}).WithDebug(rec)
+
+	assert.Nil(t, client.Get("/users").Do().Error)
+	entry := rec.Entries()[0]
+	assert.NotContains(t, entry.URL, "1234")
+	assert.NotContains(t, entry.URL, "john")
+	assert.Contains(t, entry.URL, "//%5BREDACTED%5D@127.0.0.1")
+	assert.NotContains(t, entry.Curl(), "1234")
+}
+
+// Test the ring buffer keeps only the last exchanges
+func TestDebugRecorderRingBuffer(t *testing.T) {
+	server := newEchoServer(t)
+	rec := NewDebugRecorder(2)
+	client := NewCustomRestClient(Config{BaseURL: server.URL}).WithDebug(rec)
+
+	client.Get("/1").Do()
+	client.Get("/2").Do()
+	client.Get("/3").Do()
+
+	entries := rec.Entries()
+	assert.Len(t, entries, 2)
+	assert.True(t, strings.HasSuffix(entries[0].URL, "/2"))
+	assert.True(t, strings.HasSuffix(entries[1].URL, "/3"))
+
+	rec.Reset()
+	assert.Empty(t, rec.Entries())
+}
+
+// Test the HAR export
+func TestDebugRecorderHAR(t *testing.T) {
+	server := newEchoServer(t)
+	rec := NewDebugRecorder(10)
+	client := NewCustomRestClient(Config{BaseURL: server.URL}).WithDebug(rec)
+	client.Get("/users?page=2").Do()
+
+	b, err := rec.HAR()
+	assert.Nil(t, err)
+
+	var har map[string]interface{}
+	assert.Nil(t, json.Unmarshal(b, &har))
+	log := har["log"].(map[string]interface{})
+	assert.Equal(t, "1.2", log["version"])
+	entries := log["entries"].([]interface{})
+	assert.Len(t, entries, 1)
+	req := entries[0].(map[string]interface{})["request"].(map[string]interface{})
+	assert.Equal(t, "GET", req["method"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "page", "value": "2"}}, req["queryString"])
+	res := entries[0].(map[string]interface{})["response"].(map[string]interface{})
+	assert.Equal(t, float64(200), res["status"])
+	assert.Equal(t, "application/json", res["content"].(map[string]interface{})["mimeType"])
+}
+
+// Test the curl command generation
+func TestRequestCurl(t *testing.T) {
+	client := NewCustomRestClient(Config{BaseURL: "https://api.example.com"})
+	curl := client.Post("/users", map[string]string{"name": "O'Brien"}).
+		WithHeader("X-Request-Id", "1").
+		WithAuthorizationToken("abc").
+		Curl()
+	assert.Equal(t, `curl -X POST 'https://api.example.com/users' -H 'Authorization: Bearer abc' -H 'X-Request-Id: 1' --data-raw '{"name":"O'\''Brien"}'`, curl)
+}
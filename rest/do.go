@@ -11,30 +11,176 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+
+	"github.com/abraham-corales/go-lib/cache"
 )
 
+// retryInterval is the wait before the first retry of a request
+const retryInterval = 50 * time.Millisecond
+
 // Do perform the http request taking in consideration the fields of the request
 // return the response
 func (r *Request) Do() *Response {
-	var ctx context.Context
-	var cancel context.CancelFunc
-	if r.isMocked {
-		return handleMockRequest(r, r.URL, r.Method)
+	if r.mock != nil {
+		response := r.mock.handle(r)
+		response.decodeProblem()
+		r.validateSchema(response)
+		return response
 	}
 
-	url := r.client.Config.BaseURL + r.URL
+	for _, intercept := range r.client.ReqInterceptors {
+		intercept(r)
+	}
+	url := r.baseURLOrDefault() + r.URL
 
 	if r.cached {
 		log.Printf("Checking cache for url: %s", url)
-		_, cachedResponse := r.client.Cache.Get(r.ctx, r.Method+url)
-		if cachedResponse != nil {
-			return cachedResponse.(*Response)
+		_, value := r.client.Cache.Get(r.ctx, r.Method+url)
+		if cached, ok := cache.As[*CachedResponse](value); ok && cached != nil {
+			return cached.response()
+		}
+	}
+
+	var b []byte
+	if r.Body != nil {
+		var err error
+		b, err = json.Marshal(r.Body)
+		if err != nil {
+			return &Response{
+				StatusCode: 800,
+				Error:      err,
+			}
+		}
+	}
+
+	response := r.sendWithRetries(url, b)
+	if response != nil {
+		for _, intercept := range r.client.ResInterceptors {
+			intercept(response)
+		}
+	}
+	response.decodeProblem()
+	r.validateSchema(response)
+
+	if r.cached && response != nil && response.Error == nil && (r.cacheCondition == nil || r.cacheCondition(response)) {
+		log.Printf("Caching response for url: %s with ttl: %v", url, r.cacheTTL)
+		r.client.Cache.SaveWithTTL(r.ctx, r.Method+url, newCachedResponse(response), r.cacheTTL)
+	}
+
+	//create a response object
+	return response
+}
+
+// sendWithRetries sends the request and retries the idempotent ones that failed with a transport error or a 5xx,
+// up to the Retries of the request or, if it has none, of the client. The wait between attempts doubles from retryInterval
+func (r *Request) sendWithRetries(url string, b []byte) *Response {
+	retries := r.Retries
+	if retries == 0 {
+		retries = r.client.Config.Retries
+	}
+	wait := retryInterval
+	for attempt := 0; ; attempt++ {
+		var response *Response
+		if r.baseURL == nil && r.client.endpoints != nil {
+			response = r.sendWithFailover(b)
+		} else {
+			response = r.send(url, b)
 		}
+		if attempt >= retries || !r.isIdempotent() || !isRetryable(response) {
+			return response
+		}
+		select {
+		case <-time.After(wait):
+		case <-r.context().Done():
+			return response
+		}
+		wait *= 2
+	}
+}
+
+// isRetryable returns true if the response is a transport error or a 5xx. The errors of the client, like a
+// failed token request, are not retried
+func isRetryable(response *Response) bool {
+	if response == nil || response.StatusCode == 800 {
+		return false
 	}
+	return response.Error != nil || response.StatusCode >= http.StatusInternalServerError
+}
+
+// sendWithFailover sends the request to the endpoints of the client. Idempotent requests are sent to the
+// next endpoint if the previous one failed
+func (r *Request) sendWithFailover(b []byte) *Response {
+	pool := r.client.endpoints
+	tried := make(map[*endpoint]bool)
+	var response *Response
+	for {
+		ep := pool.pick(tried)
+		if ep == nil {
+			return response
+		}
+		tried[ep] = true
+		response = r.send(ep.url+r.URL, b)
+		failed := response == nil || response.Error != nil || response.StatusCode >= http.StatusInternalServerError
+		pool.release(ep, failed)
+		if !failed || !r.isIdempotent() {
+			return response
+		}
+	}
+}
+
+// isIdempotent returns true if the request can be safely sent more than once
+func (r *Request) isIdempotent() bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return r.Headers.Get(IdempotencyKeyHeader) != ""
+}
+
+// send performs the http request against url with b as the body. The bearer token is, in order of precedence,
+// the one set with WithAuthorizationToken, the one of the caller if the client forwards it, and the one of the
+// TokenSource of the client
+func (r *Request) send(url string, b []byte) *Response {
+	ts := r.client.TokenSource
+	if r.AuthorizationToken != nil {
+		return r.sendOnce(url, b, *r.AuthorizationToken)
+	}
+	if token, ok := r.forwardedToken(); ok {
+		return r.sendOnce(url, b, token)
+	}
+	if ts == nil {
+		return r.sendOnce(url, b, "")
+	}
+
+	token, err := ts.Token(r.context())
+	if err != nil {
+		return &Response{
+			StatusCode: 800,
+			Error:      errors.Wrap(err, "error getting authorization token"),
+		}
+	}
+	response := r.sendOnce(url, b, token)
+
+	invalidating, ok := ts.(InvalidatingTokenSource)
+	if !ok || response == nil || response.StatusCode != http.StatusUnauthorized {
+		return response
+	}
+	invalidating.Invalidate(token)
+	token, err = ts.Token(r.context())
+	if err != nil {
+		return response
+	}
+	return r.sendOnce(url, b, token)
+}
+
+// sendOnce performs the http request against url with b as the body and token as the bearer token
+func (r *Request) sendOnce(url string, b []byte, token string) *Response {
+	var ctx context.Context
+	var cancel context.CancelFunc
 
 	if r.TimeoutInMillis > 0 {
 		time := time.Duration(r.TimeoutInMillis) * time.Millisecond
-		ctx, cancel = context.WithTimeout(r.ctx, time)
+		ctx, cancel = context.WithTimeout(r.context(), time)
 		defer cancel()
 	} else {
 		ctx = r.ctx
@@ -44,28 +190,20 @@ func (r *Request) Do() *Response {
 	var req *http.Request
 	var err error
 
-	if r.Body != nil {
-		var b []byte
-		b, err = json.Marshal(r.Body)
-		if err != nil {
-			return &Response{
-				StatusCode: 800,
-				Error:      err,
-			}
-		}
+	if b != nil {
 		req, err = http.NewRequest(r.Method, url, bytes.NewBuffer(b))
 	} else {
 		req, err = http.NewRequest(r.Method, url, nil)
 	}
 
-	if r.ctx != nil {
-		req = req.WithContext(ctx)
-	}
-
 	if err != nil {
 		return nil
 	}
 
+	if ctx != nil {
+		req = req.WithContext(ctx)
+	}
+
 	if r.Headers != nil {
 		for k, v := range r.Headers {
 			headersConcat := strings.Join(v, ",")
@@ -73,8 +211,19 @@ func (r *Request) Do() *Response {
 		}
 	}
 
-	if r.AuthorizationToken != nil {
-		req.Header.Set("Authorization", "Bearer "+*r.AuthorizationToken)
+	r.forwardInternalHeaders(req)
+
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	for _, signer := range r.client.Signers {
+		if err := signer.Sign(req, b); err != nil {
+			return &Response{
+				StatusCode: 800,
+				Error:      errors.Wrap(err, "error signing request"),
+			}
+		}
 	}
 
 	start := time.Now()
@@ -92,21 +241,25 @@ func (r *Request) Do() *Response {
 		if res != nil {
 			statusCode = res.StatusCode
 		}
-		return &Response{
+		response := &Response{
 			StatusCode: statusCode,
 			Duration:   elapsed,
 			Error:      errors.Wrap(err, "error on url: "+url),
 		}
+		r.client.Debug.capture(req, b, response, start)
+		return response
 	}
 
 	bodyBytes := []byte{}
 	if res.Body != nil {
 		bodyBytes, err = io.ReadAll(res.Body)
 		if err != nil {
-			return &Response{
+			response := &Response{
 				StatusCode: 800,
 				Error:      errors.Wrap(err, "error reading response body"),
 			}
+			r.client.Debug.capture(req, b, response, start)
+			return response
 		}
 	}
 
@@ -119,12 +272,6 @@ func (r *Request) Do() *Response {
 		Error:     nil,
 		Duration:  elapsed,
 	}
-
-	if r.cached {
-		log.Printf("Caching response for url: %s with ttl: %v", url, r.cacheTTL)
-		r.client.Cache.SaveWithTTL(r.ctx, r.Method+url, response, r.cacheTTL)
-	}
-
-	//create a response object
+	r.client.Debug.capture(req, b, response, start)
 	return response
 }
@@ -0,0 +1,169 @@
+package rest
+
+import (
+	"sync"
+	"time"
+)
+
+// EndpointPolicy defines how a RestClient configured with several base URLs chooses the endpoint of each request
+type EndpointPolicy int
+
+const (
+	// RoundRobin sends each request to the next healthy endpoint
+	RoundRobin EndpointPolicy = iota
+	// LeastInFlight sends each request to the healthy endpoint with fewer requests in progress
+	LeastInFlight
+	// PriorityFailover sends every request to the first healthy endpoint, in the order they were configured
+	PriorityFailover
+)
+
+const (
+	defaultEjectAfterFailures = 3
+	defaultEjectionDuration   = 30 * time.Second
+)
+
+// EndpointStatus is a snapshot of the passive health tracking of an endpoint
+type EndpointStatus struct {
+	URL                 string
+	InFlight            int
+	ConsecutiveFailures int
+	Healthy             bool
+	EjectedUntil        time.Time
+}
+
+type endpoint struct {
+	url          string
+	inFlight     int
+	failures     int
+	ejectedUntil time.Time
+}
+
+// endpointPool selects the endpoint of each request and ejects the ones failing consecutively
+type endpointPool struct {
+	mu          sync.Mutex
+	endpoints   []*endpoint
+	policy      EndpointPolicy
+	next        int
+	maxFailures int
+	ejection    time.Duration
+	now         func() time.Time
+}
+
+func newEndpointPool(urls []string, policy EndpointPolicy, maxFailures int, ejection time.Duration) *endpointPool {
+	if maxFailures <= 0 {
+		maxFailures = defaultEjectAfterFailures
+	}
+	if ejection <= 0 {
+		ejection = defaultEjectionDuration
+	}
+	p := &endpointPool{
+		policy:      policy,
+		maxFailures: maxFailures,
+		ejection:    ejection,
+		now:         time.Now,
+	}
+	for _, u := range urls {
+		p.endpoints = append(p.endpoints, &endpoint{url: u})
+	}
+	return p
+}
+
+// pick returns the endpoint for the next request, nil if every endpoint was already tried.
+// Ejected endpoints are only used if there is no healthy one left
+func (p *endpointPool) pick(tried map[*endpoint]bool) *endpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.readmit(now)
+
+	candidates := make([]int, 0, len(p.endpoints))
+	for i, ep := range p.endpoints {
+		if !tried[ep] && !now.Before(ep.ejectedUntil) {
+			candidates = append(candidates, i)
+		}
+	}
+	if len(candidates) == 0 {
+		for i, ep := range p.endpoints {
+			if !tried[ep] {
+				candidates = append(candidates, i)
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	chosen := candidates[0]
+	switch p.policy {
+	case RoundRobin:
+		for _, i := range candidates {
+			if i >= p.next {
+				chosen = i
+				break
+			}
+		}
+		p.next = (chosen + 1) % len(p.endpoints)
+	case LeastInFlight:
+		for _, i := range candidates {
+			if p.endpoints[i].inFlight < p.endpoints[chosen].inFlight {
+				chosen = i
+			}
+		}
+	}
+
+	ep := p.endpoints[chosen]
+	ep.inFlight++
+	return ep
+}
+
+// release records the result of a request sent to ep
+func (p *endpointPool) release(ep *endpoint, failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ep.inFlight--
+	if !failed {
+		ep.failures = 0
+		return
+	}
+	ep.failures++
+	if ep.failures >= p.maxFailures {
+		ep.ejectedUntil = p.now().Add(p.ejection)
+	}
+}
+
+// readmit returns the endpoints whose ejection ended to the pool with a clean failure count, so a single
+// failure does not eject them again. p.mu must be held
+func (p *endpointPool) readmit(now time.Time) {
+	for _, ep := range p.endpoints {
+		if !ep.ejectedUntil.IsZero() && !now.Before(ep.ejectedUntil) {
+			ep.failures = 0
+			ep.ejectedUntil = time.Time{}
+		}
+	}
+}
+
+func (p *endpointPool) status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	p.readmit(now)
+	res := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, ep := range p.endpoints {
+		res = append(res, EndpointStatus{
+			URL:                 ep.url,
+			InFlight:            ep.inFlight,
+			ConsecutiveFailures: ep.failures,
+			Healthy:             !now.Before(ep.ejectedUntil),
+			EjectedUntil:        ep.ejectedUntil,
+		})
+	}
+	return res
+}
+
+// Endpoints returns the health status of the endpoints configured with Config.BaseURLs
+func (c *RestClient) Endpoints() []EndpointStatus {
+	if c.endpoints == nil {
+		return nil
+	}
+	return c.endpoints.status()
+}
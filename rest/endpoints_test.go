@@ -0,0 +1,131 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCountingServer(t *testing.T, status int, hits *int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test the base URL override only applies to the request
+func TestWithCustomBaseURLIsRequestScoped(t *testing.T) {
+	var defaultHits, customHits int32
+	defaultServer := newCountingServer(t, 200, &defaultHits)
+	customServer := newCountingServer(t, 200, &customHits)
+	client := NewCustomRestClient(Config{BaseURL: defaultServer.URL})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Get("/").WithCustomBaseURL(customServer.URL).Do()
+		}()
+		go func() {
+			defer wg.Done()
+			client.Get("/").Do()
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(10), defaultHits)
+	assert.Equal(t, int32(10), customHits)
+	assert.Equal(t, defaultServer.URL, client.Config.BaseURL)
+}
+
+// Test round robin spreads the requests
+func TestEndpointsRoundRobin(t *testing.T) {
+	var firstHits, secondHits int32
+	first := newCountingServer(t, 200, &firstHits)
+	second := newCountingServer(t, 200, &secondHits)
+	client := NewCustomRestClient(Config{BaseURLs: []string{first.URL, second.URL}, EndpointPolicy: RoundRobin})
+
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, 200, client.Get("/").Do().StatusCode)
+	}
+	assert.Equal(t, int32(3), firstHits)
+	assert.Equal(t, int32(3), secondHits)
+}
+
+// Test priority failover and the passive ejection of failing endpoints
+func TestEndpointsPriorityFailover(t *testing.T) {
+	var primaryHits, secondaryHits int32
+	primary := newCountingServer(t, 503, &primaryHits)
+	secondary := newCountingServer(t, 200, &secondaryHits)
+	client := NewCustomRestClient(Config{
+		BaseURLs:           []string{primary.URL, secondary.URL},
+		EndpointPolicy:     PriorityFailover,
+		EjectAfterFailures: 2,
+		EjectionDuration:   time.Minute,
+	})
+
+	assert.Equal(t, 200, client.Get("/").Do().StatusCode)
+	assert.Equal(t, int32(1), primaryHits)
+	assert.Equal(t, int32(1), secondaryHits)
+
+	// non idempotent requests are not sent twice
+	assert.Equal(t, 503, client.Post("/", nil).Do().StatusCode)
+	assert.Equal(t, int32(2), primaryHits)
+
+	status := client.Endpoints()
+	assert.False(t, status[0].Healthy)
+	assert.True(t, status[1].Healthy)
+
+	assert.Equal(t, 200, client.Post("/", nil).Do().StatusCode)
+	assert.Equal(t, int32(2), primaryHits)
+	assert.Equal(t, int32(2), secondaryHits)
+}
+
+// Test least in flight picks the less busy endpoint and ejected endpoints are used as last resort
+func TestEndpointPoolLeastInFlight(t *testing.T) {
+	pool := newEndpointPool([]string{"a", "b", "c"}, LeastInFlight, 1, time.Minute)
+	a := pool.pick(nil)
+	b := pool.pick(nil)
+	assert.Equal(t, "a", a.url)
+	assert.Equal(t, "b", b.url)
+	assert.Equal(t, "c", pool.pick(nil).url)
+
+	pool.release(a, false)
+	assert.Equal(t, "a", pool.pick(nil).url)
+
+	pool.release(b, true)
+	tried := map[*endpoint]bool{}
+	for _, ep := range pool.endpoints {
+		if ep.url != "b" {
+			tried[ep] = true
+		}
+	}
+	assert.Equal(t, "b", pool.pick(tried).url)
+}
+
+// Test an endpoint readmitted after its ejection needs the configured failures to be ejected again
+func TestEndpointPoolReadmission(t *testing.T) {
+	now := time.Now()
+	pool := newEndpointPool([]string{"a", "b"}, PriorityFailover, 2, time.Minute)
+	pool.now = func() time.Time { return now }
+
+	pool.release(pool.pick(nil), true)
+	pool.release(pool.pick(nil), true)
+	assert.Equal(t, "b", pool.pick(nil).url)
+
+	now = now.Add(time.Minute)
+	a := pool.pick(nil)
+	assert.Equal(t, "a", a.url)
+	assert.Equal(t, 0, pool.status()[0].ConsecutiveFailures)
+	pool.release(a, true)
+	assert.True(t, pool.status()[0].Healthy)
+	assert.Equal(t, "a", pool.pick(nil).url)
+}
@@ -0,0 +1,104 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// PathNotFoundError is returned when a gjson path does not exist in the body
+type PathNotFoundError struct {
+	Path string
+}
+
+func (e *PathNotFoundError) Error() string {
+	return fmt.Sprintf("path %q not found in response body", e.Path)
+}
+
+// Get returns the value of the gjson path in the body, see https://github.com/tidwall/gjson/blob/master/SYNTAX.md
+// Example:
+//
+//	city := res.Get("user.address.city").String()
+//	total := res.Get("items.#.price").Array()
+func (r *Response) Get(path string) gjson.Result {
+	return gjson.GetBytes(r.BodyBytes, path)
+}
+
+// MapPathTo unmarshalls the value of the gjson path into bindTo. A pointer MUST be passed in.
+// A *PathNotFoundError is returned if the path does not exist
+func (r *Response) MapPathTo(path string, bindTo interface{}) error {
+	value := r.Get(path)
+	if !value.Exists() {
+		return &PathNotFoundError{Path: path}
+	}
+	if err := json.Unmarshal([]byte(value.Raw), bindTo); err != nil {
+		return errors.Wrapf(err, "error mapping path %q", path)
+	}
+	return nil
+}
+
+// Extract returns the value of the gjson path of the body as a T.
+// Example:
+//
+//	ids, err := rest.Extract[[]int](res, "data.users.#.id")
+func Extract[T any](res *Response, path string) (T, error) {
+	var value T
+	err := res.MapPathTo(path, &value)
+	return value, err
+}
+
+// MapPathsTo fills the fields of the struct pointed by bindTo with the values of the paths in their gjson tags.
+// Missing paths leave the field untouched unless the tag has the required option. Embedded structs are filled too.
+// Example:
+//
+//	var order struct {
+//		ID       string  `gjson:"data.order.id,required"`
+//		Total    float64 `gjson:"data.order.totals.grand"`
+//		Customer string  `gjson:"data.order.customer.email"`
+//	}
+//	err := res.MapPathsTo(&order)
+func (r *Response) MapPathsTo(bindTo interface{}) error {
+	v := reflect.ValueOf(bindTo)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("MapPathsTo needs a pointer to a struct")
+	}
+	if !gjson.ValidBytes(r.BodyBytes) {
+		return errors.New("response body is not valid JSON")
+	}
+	return r.mapPaths(v.Elem())
+}
+
+func (r *Response) mapPaths(v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag, tagged := field.Tag.Lookup("gjson")
+		if !tagged {
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				if err := r.mapPaths(v.Field(i)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		path, opts, _ := strings.Cut(tag, ",")
+		value := r.Get(path)
+		if !value.Exists() {
+			if opts == "required" {
+				return &PathNotFoundError{Path: path}
+			}
+			continue
+		}
+		if err := json.Unmarshal([]byte(value.Raw), v.Field(i).Addr().Interface()); err != nil {
+			return errors.Wrapf(err, "error mapping path %q to field %s", path, field.Name)
+		}
+	}
+	return nil
+}
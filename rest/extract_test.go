@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var orderBody = []byte(`{"data":{"order":{"id":"o-1","totals":{"grand":12.5},
+	"items":[{"sku":"a","qty":1},{"sku":"b","qty":2}],"customer":{"email":"ana@example.com"}}}}`)
+
+// Test single path extraction
+func TestGetAndExtract(t *testing.T) {
+	res := &Response{BodyBytes: orderBody}
+	assert.Equal(t, "o-1", res.Get("data.order.id").String())
+	assert.Equal(t, int64(2), res.Get("data.order.items.#").Int())
+
+	skus, err := Extract[[]string](res, "data.order.items.#.sku")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a", "b"}, skus)
+
+	type item struct {
+		SKU string `json:"sku"`
+		Qty int    `json:"qty"`
+	}
+	var last item
+	assert.Nil(t, res.MapPathTo("data.order.items.1", &last))
+	assert.Equal(t, item{SKU: "b", Qty: 2}, last)
+
+	_, err = Extract[int](res, "data.order.discount")
+	var notFound *PathNotFoundError
+	assert.True(t, errors.As(err, &notFound))
+	assert.Equal(t, "data.order.discount", notFound.Path)
+
+	_, err = Extract[int](res, "data.order.id")
+	assert.NotNil(t, err)
+}
+
+// Test multi path extraction with gjson tags
+func TestMapPathsTo(t *testing.T) {
+	type Meta struct {
+		Items int `gjson:"data.order.items.#"`
+	}
+	type order struct {
+		Meta
+		ID       string   `gjson:"data.order.id,required"`
+		Total    float64  `gjson:"data.order.totals.grand"`
+		Email    string   `gjson:"data.order.customer.email"`
+		Qty      []int    `gjson:"data.order.items.#.qty"`
+		Discount *float64 `gjson:"data.order.discount"`
+		Ignored  string   `gjson:"-"`
+		Untagged string
+	}
+	res := &Response{BodyBytes: orderBody}
+
+	var o order
+	assert.Nil(t, res.MapPathsTo(&o))
+	assert.Equal(t, order{Meta: Meta{Items: 2}, ID: "o-1", Total: 12.5, Email: "ana@example.com", Qty: []int{1, 2}}, o)
+
+	var missing struct {
+		Discount float64 `gjson:"data.order.discount,required"`
+	}
+	var notFound *PathNotFoundError
+	assert.True(t, errors.As(res.MapPathsTo(&missing), &notFound))
+
+	assert.NotNil(t, res.MapPathsTo(o))
+	assert.NotNil(t, (&Response{BodyBytes: []byte(`{`)}).MapPathsTo(&o))
+}
@@ -0,0 +1,80 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// IdentityForwarding defines what the client forwards from the Principal found in the context of a request
+type IdentityForwarding int
+
+const (
+	// ForwardNothing does not forward the identity of the caller. It is the default
+	ForwardNothing IdentityForwarding = 0
+	// ForwardBearerToken sends the bearer token of the caller in the Authorization header. A token set with
+	// WithAuthorizationToken takes precedence, and the TokenSource of the client is used when the caller has no token
+	ForwardBearerToken IdentityForwarding = 1 << 0
+	// ForwardInternalHeaders sends the X-Scopes, X-Client-Id and X-Auth0-Client headers used between internal services
+	ForwardInternalHeaders IdentityForwarding = 1 << 1
+)
+
+// Principal is the authenticated caller of an inbound request
+type Principal struct {
+	// Token is the bearer token of the caller. Set it only once the token was validated, it is forwarded as is
+	Token       string
+	Scopes      string
+	ClientID    string
+	Auth0Client string
+	Email       string
+	Audience    []string
+}
+
+type principalKey struct{}
+
+// ContextWithPrincipal returns a copy of ctx carrying p
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the Principal carried by ctx
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	if ctx == nil {
+		return Principal{}, false
+	}
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// forwardedToken returns the bearer token of the caller if the client forwards it
+func (r *Request) forwardedToken() (string, bool) {
+	if r.client.Config.ForwardIdentity&ForwardBearerToken == 0 {
+		return "", false
+	}
+	p, ok := PrincipalFromContext(r.ctx)
+	if !ok || p.Token == "" {
+		return "", false
+	}
+	return strings.TrimPrefix(p.Token, "Bearer "), true
+}
+
+// forwardInternalHeaders sets the internal identity headers of the caller, unless the request already set them
+func (r *Request) forwardInternalHeaders(req *http.Request) {
+	if r.client.Config.ForwardIdentity&ForwardInternalHeaders == 0 {
+		return
+	}
+	p, ok := PrincipalFromContext(r.ctx)
+	if !ok {
+		return
+	}
+	headers := map[string]string{
+		"X-Scopes":       p.Scopes,
+		"X-Client-Id":    p.ClientID,
+		"X-Auth0-Client": p.Auth0Client,
+	}
+	for k, v := range headers {
+		if v != "" && req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newHeadersServer(t *testing.T, received *http.Header) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*received = r.Header.Clone()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Test the identity of the caller is only forwarded under the policy of the client
+func TestForwardIdentity(t *testing.T) {
+	var received http.Header
+	server := newHeadersServer(t, &received)
+	ctx := ContextWithPrincipal(context.Background(), Principal{
+		Token:       "caller-token",
+		Scopes:      "read write",
+		ClientID:    "cli-1",
+		Auth0Client: "auth0-1",
+	})
+
+	NewCustomRestClient(Config{BaseURL: server.URL}).Get("/").WithContext(ctx).Do()
+	assert.Empty(t, received.Get("Authorization"))
+	assert.Empty(t, received.Get("X-Scopes"))
+
+	NewCustomRestClient(Config{BaseURL: server.URL, ForwardIdentity: ForwardBearerToken}).Get("/").WithContext(ctx).Do()
+	assert.Equal(t, "Bearer caller-token", received.Get("Authorization"))
+	assert.Empty(t, received.Get("X-Scopes"))
+
+	client := NewCustomRestClient(Config{BaseURL: server.URL, ForwardIdentity: ForwardInternalHeaders})
+	client.Get("/").WithContext(ctx).WithHeader("X-Client-Id", "override").Do()
+	assert.Empty(t, received.Get("Authorization"))
+	assert.Equal(t, "read write", received.Get("X-Scopes"))
+	assert.Equal(t, "override", received.Get("X-Client-Id"))
+	assert.Equal(t, "auth0-1", received.Get("X-Auth0-Client"))
+
+	client = NewCustomRestClient(Config{BaseURL: server.URL, ForwardIdentity: ForwardBearerToken | ForwardInternalHeaders})
+	client.Get("/").WithContext(ctx).WithAuthorizationToken("explicit").Do()
+	assert.Equal(t, "Bearer explicit", received.Get("Authorization"))
+	assert.Equal(t, "cli-1", received.Get("X-Client-Id"))
+}
+
+type staticTokenSource string
+
+func (s staticTokenSource) Token(ctx context.Context) (string, error) {
+	return string(s), nil
+}
+
+// Test the precedence of the explicit token, the forwarded token and the token source
+func TestForwardBearerTokenPrecedence(t *testing.T) {
+	var received http.Header
+	server := newHeadersServer(t, &received)
+	client := NewCustomRestClient(Config{BaseURL: server.URL, ForwardIdentity: ForwardBearerToken}).
+		WithTokenSource(staticTokenSource("service-token"))
+	caller := ContextWithPrincipal(context.Background(), Principal{Token: "caller-token"})
+
+	client.Get("/").WithContext(caller).WithAuthorizationToken("explicit").Do()
+	assert.Equal(t, "Bearer explicit", received.Get("Authorization"))
+
+	client.Get("/").WithContext(caller).Do()
+	assert.Equal(t, "Bearer caller-token", received.Get("Authorization"))
+
+	client.Get("/").WithContext(ContextWithPrincipal(context.Background(), Principal{ClientID: "cli-1"})).Do()
+	assert.Equal(t, "Bearer service-token", received.Get("Authorization"))
+
+	client.Get("/").Do()
+	assert.Equal(t, "Bearer service-token", received.Get("Authorization"))
+}
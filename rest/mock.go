@@ -1,13 +1,26 @@
 package rest
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 type MockClient struct {
+	// BaseURL is removed from the URL of the requests before matching them, so the mocks never include it
 	BaseURL string
 	test    *testing.T
+
+	mu           sync.Mutex
+	mockCalls    map[string]MockResponse
+	requestStack []MockCall
+	requestsDone map[string]int
+	// requested has the calls requested at least once, including the ones answered with an InternalError
+	requested    map[string]bool
+	expectations []*Expectation
 }
 
 // MockCall is a struct that contains the information of a request that was made
@@ -19,139 +32,238 @@ type MockCall struct {
 
 // NewDefaultMockClient creates a new mock client with the default base URL
 func NewDefaultMockClient(t *testing.T) *MockClient {
-	mockCalls = make(map[string]MockResponse)
-	requestsDone = make(map[string]int)
-	requestStack = make([]MockCall, 0)
-	return &MockClient{
-		test: t,
-	}
+	return NewMockClient(t, "")
 }
 
-// NewMockClient creates a new mock client with the given base URL
+// NewMockClient creates a new mock client with the given base URL.
+// When the test ends it fails if any of the mocked calls was never requested.
+// With a nil t, the calls without a mock return an error instead of failing the test
 func NewMockClient(t *testing.T, baseURL string) *MockClient {
-	mockCalls = make(map[string]MockResponse)
-	requestsDone = make(map[string]int)
-	requestStack = make([]MockCall, 0)
-	return &MockClient{
-		test:    t,
-		BaseURL: baseURL,
+	c := &MockClient{
+		test:         t,
+		BaseURL:      baseURL,
+		mockCalls:    make(map[string]MockResponse),
+		requestStack: make([]MockCall, 0),
+		requestsDone: make(map[string]int),
+		requested:    make(map[string]bool),
+	}
+	if t != nil {
+		t.Cleanup(func() { c.AssertExpectations(t) })
 	}
+	return c
 }
 
-var mockCalls map[string]MockResponse
-var requestStack []MockCall
-var requestsDone map[string]int
-var mutex sync.Mutex
-
 // MockResponse is a struct that contains the information of a mocked response
 type MockResponse struct {
 	StatusCode    int
 	JSONBody      string
 	InternalError error
+	Headers       http.Header
+	// Latency delays the response. The delay is interrupted if the context of the request is done
+	Latency time.Duration
 }
 
 func (c *MockClient) Get(url string) *Request {
 	return &Request{
-		Method:   "GET",
-		URL:      url,
-		isMocked: true,
-		t:        c.test,
+		Method: "GET",
+		URL:    url,
+		mock:   c,
 	}
 }
 
 func (c *MockClient) Post(url string, body interface{}) *Request {
 	return &Request{
-		Body:     body,
-		Method:   "POST",
-		URL:      url,
-		isMocked: true,
-		t:        c.test,
+		Body:   body,
+		Method: "POST",
+		URL:    url,
+		mock:   c,
 	}
 }
 
 func (c *MockClient) Put(url string, body interface{}) *Request {
 	return &Request{
-		Method:   "PUT",
-		Body:     body,
-		URL:      url,
-		isMocked: true,
-		t:        c.test,
+		Method: "PUT",
+		Body:   body,
+		URL:    url,
+		mock:   c,
 	}
 }
 
 func (c *MockClient) Delete(url string) *Request {
 	return &Request{
-		Method:   "DELETE",
-		URL:      url,
-		isMocked: true,
-		t:        c.test,
+		Method: "DELETE",
+		URL:    url,
+		mock:   c,
+	}
+}
+
+func (c *MockClient) Patch(url string, body interface{}) *Request {
+	return &Request{
+		Method: "PATCH",
+		Body:   body,
+		URL:    url,
+		mock:   c,
+	}
+}
+
+func (c *MockClient) Head(url string) *Request {
+	return &Request{
+		Method: "HEAD",
+		URL:    url,
+		mock:   c,
+	}
+}
+
+func (c *MockClient) Options(url string) *Request {
+	return &Request{
+		Method: "OPTIONS",
+		URL:    url,
+		mock:   c,
 	}
 }
 
 func (c *MockClient) Request(method string, url string, body interface{}) *Request {
 	return &Request{
-		Method:   method,
-		URL:      url,
-		Body:     body,
-		isMocked: true,
-		t:        c.test,
+		Method: method,
+		URL:    url,
+		Body:   body,
+		mock:   c,
 	}
 }
 
 // SetMockCall is a function that can be used to mock the response of a request. DONT include the baseURL of the client
 func (c *MockClient) SetMockCall(method string, url string, response MockResponse) {
-	if mockCalls == nil {
-		mockCalls = make(map[string]MockResponse)
-	}
-	mockCalls[method+url] = response
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mockCalls[method+url] = response
 }
 
 func (c *MockClient) CountRequestsDone(method string, url string) int {
-	if res, ok := requestsDone[method+url]; ok {
-		return res
-	}
-	return 0
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requestsDone[method+url]
 }
 
+// GetRequestStack returns a copy of the requests handled by the client, in order
 func (c *MockClient) GetRequestStack() []MockCall {
-	return requestStack
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]MockCall(nil), c.requestStack...)
 }
 
 func (c *MockClient) ClearMockCalls() {
-	mockCalls = make(map[string]MockResponse)
-
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mockCalls = make(map[string]MockResponse)
 }
 
-func handleMockRequest(r *Request, url, method string) *Response {
-	if r.isMocked {
-		mutex.Lock()
-		defer mutex.Unlock()
-		mock, ok := mockCalls[method+url]
-		if !ok {
-			r.t.Logf("No mock found for %s", method+url)
-			r.t.Fail()
+// AssertExpectations fails the test if any of the mocked calls was never requested or if an expectation
+// did not receive the number of calls set with Times.
+// It is called automatically when the test that created the client ends
+func (c *MockClient) AssertExpectations(t interface {
+	Errorf(format string, args ...interface{})
+}) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ok := true
+	for _, e := range c.expectations {
+		if msg, unmet := e.unmet(); unmet {
+			t.Errorf("%s", msg)
+			ok = false
+		}
+	}
+	for key := range c.mockCalls {
+		if !c.requested[key] {
+			t.Errorf("Mock %s was set but never requested", key)
+			ok = false
 		}
+	}
+	return ok
+}
+
+func (c *MockClient) handle(r *Request) *Response {
+	if c.BaseURL != "" && strings.HasPrefix(r.URL, c.BaseURL) {
+		stripped := *r
+		stripped.URL = strings.TrimPrefix(r.URL, c.BaseURL)
+		r = &stripped
+	}
+
+	c.mu.Lock()
+	mock := c.findMock(r)
+	c.requested[r.Method+r.URL] = true
+	if mock.InternalError == nil {
+		c.requestsDone[r.Method+r.URL] = c.requestsDone[r.Method+r.URL] + 1
+		c.requestStack = append(c.requestStack, MockCall{
+			URL:          r.URL,
+			ResponseBody: mock.JSONBody,
+			RequestBody:  r.Body,
+		})
+	}
+	c.mu.Unlock()
 
-		if mock.InternalError != nil {
+	if mock.Latency > 0 {
+		var done <-chan struct{}
+		if r.ctx != nil {
+			done = r.ctx.Done()
+		}
+		select {
+		case <-time.After(mock.Latency):
+		case <-done:
 			return &Response{
 				StatusCode: 500,
-				Error:      mockCalls[method+url].InternalError,
+				Duration:   mock.Latency.Milliseconds(),
+				Error:      r.ctx.Err(),
 			}
 		}
-		requestsDone[method+url] = requestsDone[method+url] + 1
-		requestStack = append(requestStack, MockCall{
-			URL:          url,
-			ResponseBody: mock.JSONBody,
-			RequestBody:  r.Body,
-		})
+	}
 
-		bodyBytes := []byte(mock.JSONBody)
+	if mock.InternalError != nil {
 		return &Response{
-			StatusCode:  mockCalls[method+url].StatusCode,
-			Error:       nil,
-			RawResponse: nil,
-			BodyBytes:   bodyBytes,
+			StatusCode: 500,
+			Error:      mock.InternalError,
+		}
+	}
+
+	bodyBytes := []byte(mock.JSONBody)
+	return &Response{
+		StatusCode:  mock.StatusCode,
+		Headers:     mock.Headers,
+		Error:       nil,
+		RawResponse: nil,
+		BodyBytes:   bodyBytes,
+		Duration:    mock.Latency.Milliseconds(),
+	}
+}
+
+// findMock looks for the response of r in the expectations first and then in the calls set with SetMockCall.
+// It fails the test if there is none, or returns an error without a test. c.mu must be held
+func (c *MockClient) findMock(r *Request) MockResponse {
+	exhausted := false
+	for _, e := range c.expectations {
+		if !e.matches(r) {
+			continue
+		}
+		if res, ok := e.next(); ok {
+			return res
 		}
+		exhausted = true
+	}
+
+	key := r.Method + r.URL
+	if mock, ok := c.mockCalls[key]; ok {
+		return mock
+	}
+
+	if exhausted {
+		if c.test != nil {
+			c.test.Errorf("Too many calls to %s", key)
+		}
+		return MockResponse{StatusCode: 500, InternalError: fmt.Errorf("mock: too many calls to %s", key)}
+	}
+	if c.test == nil {
+		return MockResponse{StatusCode: 500, InternalError: fmt.Errorf("mock: no mock found for %s", key)}
 	}
-	return nil
+	c.test.Logf("No mock found for %s", key)
+	c.test.Fail()
+	return MockResponse{}
 }
@@ -0,0 +1,270 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/gjson"
+)
+
+// Matcher decides if a mocked Request satisfies an Expectation
+type Matcher interface {
+	Match(r *Request) bool
+	String() string
+}
+
+type matcherFunc struct {
+	description string
+	match       func(r *Request) bool
+}
+
+func (m matcherFunc) Match(r *Request) bool {
+	return m.match(r)
+}
+
+func (m matcherFunc) String() string {
+	return m.description
+}
+
+// MatchFunc creates a Matcher from a custom function. description is used when reporting unmet expectations
+func MatchFunc(description string, fn func(r *Request) bool) Matcher {
+	return matcherFunc{description: description, match: fn}
+}
+
+// MatchURL matches the path of the request exactly, ignoring the query string
+func MatchURL(u string) Matcher {
+	return MatchFunc("url="+u, func(r *Request) bool {
+		return requestPath(r) == u
+	})
+}
+
+// MatchURLRegex matches the full URL of the request, including the query string, against a regular expression
+func MatchURLRegex(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return MatchFunc("url~"+pattern, func(r *Request) bool {
+		return re.MatchString(r.URL)
+	})
+}
+
+// MatchURLGlob matches the path of the request against a glob pattern. Example: /users/*/orders
+func MatchURLGlob(pattern string) Matcher {
+	return MatchFunc("url glob "+pattern, func(r *Request) bool {
+		ok, err := path.Match(pattern, requestPath(r))
+		return err == nil && ok
+	})
+}
+
+// MatchQuery matches if the query param key of the request has the given value
+func MatchQuery(key, value string) Matcher {
+	return MatchFunc(fmt.Sprintf("query %s=%s", key, value), func(r *Request) bool {
+		u, err := url.Parse(r.URL)
+		if err != nil {
+			return false
+		}
+		values, ok := u.Query()[key]
+		if !ok {
+			return false
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// MatchHeader matches if the header key of the request has the given value
+func MatchHeader(key, value string) Matcher {
+	return MatchFunc(fmt.Sprintf("header %s=%s", key, value), func(r *Request) bool {
+		return r.Headers.Get(key) == value
+	})
+}
+
+// MatchJSONBody matches if the body of the request is semantically equal to the expected JSON
+func MatchJSONBody(expected string) Matcher {
+	var want interface{}
+	if err := json.Unmarshal([]byte(expected), &want); err != nil {
+		panic("rest: invalid JSON passed to MatchJSONBody: " + err.Error())
+	}
+	return MatchFunc("json body "+expected, func(r *Request) bool {
+		var got interface{}
+		if err := json.Unmarshal(requestBodyBytes(r), &got); err != nil {
+			return false
+		}
+		return reflect.DeepEqual(want, got)
+	})
+}
+
+// MatchBodyPath matches if the gjson path of the request body satisfies predicate
+// Example:
+//
+//	rest.MatchBodyPath("user.age", func(v gjson.Result) bool { return v.Int() > 18 })
+func MatchBodyPath(p string, predicate func(v gjson.Result) bool) Matcher {
+	return MatchFunc("body path "+p, func(r *Request) bool {
+		return predicate(gjson.GetBytes(requestBodyBytes(r), p))
+	})
+}
+
+func requestPath(r *Request) string {
+	if i := strings.IndexByte(r.URL, '?'); i >= 0 {
+		return r.URL[:i]
+	}
+	return r.URL
+}
+
+func requestBodyBytes(r *Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	if b, ok := r.Body.([]byte); ok {
+		return b
+	}
+	if s, ok := r.Body.(string); ok {
+		return []byte(s)
+	}
+	b, err := json.Marshal(r.Body)
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+// Expectation is a mocked call registered with MockClient.Expect.
+// The responses are served in order and the last one is repeated once the sequence is exhausted.
+// Example:
+//
+//	client.Expect("POST", rest.MatchURL("/payments")).
+//		Respond(rest.MockResponse{StatusCode: 503}).
+//		Respond(rest.MockResponse{StatusCode: 503}).
+//		Respond(rest.MockResponse{StatusCode: 201, JSONBody: `{"id":1}`}).
+//		Times(3)
+type Expectation struct {
+	method    string
+	matchers  []Matcher
+	responses []MockResponse
+	times     int
+	calls     int
+	// extra counts the calls refused once the expectation was called Times(n)
+	extra int
+	mu    sync.Mutex
+}
+
+const (
+	// atLeastOnce is the default number of calls of an expectation: unlimited, but it must be requested
+	atLeastOnce = -1
+	anyTimes    = -2
+)
+
+// Expect registers a new expectation for the given method. The request must satisfy all the matchers
+func (c *MockClient) Expect(method string, matchers ...Matcher) *Expectation {
+	e := &Expectation{method: method, matchers: matchers, times: atLeastOnce}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.expectations = append(c.expectations, e)
+	return e
+}
+
+// Respond appends a response to the sequence of the expectation
+func (e *Expectation) Respond(responses ...MockResponse) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.responses = append(e.responses, responses...)
+	return e
+}
+
+// RespondTimes appends the response n times to the sequence of the expectation
+func (e *Expectation) RespondTimes(response MockResponse, n int) *Expectation {
+	for i := 0; i < n; i++ {
+		e.Respond(response)
+	}
+	return e
+}
+
+// Times sets the exact number of calls expected, 0 like Never. Extra calls fail the test and missing calls are
+// reported when it ends. Without Times, an expectation can be called any number of times but at least once
+func (e *Expectation) Times(n int) *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.times = max(n, 0)
+	return e
+}
+
+// Never expects no call, any call fails the test
+func (e *Expectation) Never() *Expectation {
+	return e.Times(0)
+}
+
+// AnyTimes accepts any number of calls, even none
+func (e *Expectation) AnyTimes() *Expectation {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.times = anyTimes
+	return e
+}
+
+// Calls returns the number of requests served by the expectation
+func (e *Expectation) Calls() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.calls
+}
+
+func (e *Expectation) String() string {
+	parts := make([]string, 0, len(e.matchers)+1)
+	parts = append(parts, e.method)
+	for _, m := range e.matchers {
+		parts = append(parts, m.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e *Expectation) matches(r *Request) bool {
+	if e.method != r.Method {
+		return false
+	}
+	for _, m := range e.matchers {
+		if !m.Match(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// next returns the response for the next call, false if the expectation was already called Times(n)
+func (e *Expectation) next() (MockResponse, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.times >= 0 && e.calls >= e.times {
+		e.extra++
+		return MockResponse{}, false
+	}
+	e.calls++
+	if len(e.responses) == 0 {
+		return MockResponse{StatusCode: 200}, true
+	}
+	idx := e.calls - 1
+	if idx >= len(e.responses) {
+		idx = len(e.responses) - 1
+	}
+	return e.responses[idx], true
+}
+
+// unmet returns a description of the problem if the expectation was not satisfied
+func (e *Expectation) unmet() (string, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.times >= 0 && (e.calls != e.times || e.extra > 0) {
+		return fmt.Sprintf("Expectation %s was called %d times, expected %d", e, e.calls+e.extra, e.times), true
+	}
+	if e.times == atLeastOnce && e.calls == 0 {
+		return fmt.Sprintf("Expectation %s was set but never requested", e), true
+	}
+	return "", false
+}
@@ -0,0 +1,185 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/gjson"
+)
+
+type errorRecorder struct {
+	errors []string
+}
+
+func (e *errorRecorder) Errorf(format string, args ...interface{}) {
+	e.errors = append(e.errors, fmt.Sprintf(format, args...))
+}
+
+// Test mock clients running in parallel don't share state
+func TestMockClientParallel(t *testing.T) {
+	for i := 0; i < 5; i++ {
+		i := i
+		t.Run(fmt.Sprintf("client-%d", i), func(t *testing.T) {
+			t.Parallel()
+			client := NewMockClient(t, "")
+			body := fmt.Sprintf(`{"id":%d}`, i)
+			client.SetMockCall("GET", "/users", MockResponse{StatusCode: 200, JSONBody: body})
+
+			var wg sync.WaitGroup
+			for j := 0; j < 10; j++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					res := client.Get("/users").Do()
+					assert.Equal(t, body, string(res.BodyBytes))
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, 10, client.CountRequestsDone("GET", "/users"))
+			assert.Len(t, client.GetRequestStack(), 10)
+		})
+	}
+}
+
+// Test a client without a test returns errors for the calls without a mock, the base URL is not part of the
+// mocked URLs and the internal errors are not counted as requests done
+func TestMockClientWithoutTest(t *testing.T) {
+	client := NewMockClient(nil, "https://api.example.com")
+	client.SetMockCall("GET", "/users", MockResponse{StatusCode: 200, JSONBody: `[]`})
+	client.SetMockCall("GET", "/down", MockResponse{InternalError: fmt.Errorf("connection refused")})
+
+	assert.Equal(t, 200, client.Get("https://api.example.com/users").Do().StatusCode)
+	assert.Equal(t, 200, client.Get("/users").Do().StatusCode)
+	assert.Equal(t, 2, client.CountRequestsDone("GET", "/users"))
+
+	res := client.Get("/missing").Do()
+	assert.EqualError(t, res.Error, "mock: no mock found for GET/missing")
+
+	assert.EqualError(t, client.Get("/down").Do().Error, "connection refused")
+	assert.Equal(t, 0, client.CountRequestsDone("GET", "/down"))
+	assert.Len(t, client.GetRequestStack(), 2)
+	assert.True(t, client.AssertExpectations(&errorRecorder{}))
+}
+
+// Test the requests keep a reference to the client that created them
+func TestMockRequestKeepsClient(t *testing.T) {
+	first := NewDefaultMockClient(t)
+	second := NewDefaultMockClient(t)
+	first.SetMockCall("POST", "/users", MockResponse{StatusCode: 201, JSONBody: `{}`})
+	second.SetMockCall("POST", "/users", MockResponse{StatusCode: 409, JSONBody: `{}`})
+
+	req := first.Post("/users", map[string]string{"name": "john"})
+	assert.Equal(t, 409, second.Post("/users", nil).Do().StatusCode)
+	assert.Equal(t, 201, req.Do().StatusCode)
+
+	assert.Equal(t, 1, first.CountRequestsDone("POST", "/users"))
+	assert.Equal(t, 1, second.CountRequestsDone("POST", "/users"))
+	assert.Equal(t, map[string]string{"name": "john"}, first.GetRequestStack()[0].RequestBody)
+}
+
+// Test unused mocks are reported
+func TestMockClientAssertExpectations(t *testing.T) {
+	client := NewDefaultMockClient(t)
+	client.SetMockCall("GET", "/used", MockResponse{StatusCode: 200})
+	client.SetMockCall("GET", "/unused", MockResponse{StatusCode: 200})
+	client.Get("/used").Do()
+
+	rec := &errorRecorder{}
+	assert.False(t, client.AssertExpectations(rec))
+	assert.Equal(t, []string{"Mock GET/unused was set but never requested"}, rec.errors)
+
+	client.Get("/unused").Do()
+	assert.True(t, client.AssertExpectations(&errorRecorder{}))
+}
+
+// Test ordered response sequences
+func TestMockExpectationSequence(t *testing.T) {
+	client := NewDefaultMockClient(t)
+	client.Expect("POST", MatchURL("/payments")).
+		RespondTimes(MockResponse{StatusCode: 503}, 2).
+		Respond(MockResponse{StatusCode: 201, JSONBody: `{"id":1}`, Headers: http.Header{"Location": {"/payments/1"}}}).
+		Times(3)
+
+	assert.Equal(t, 503, client.Post("/payments", nil).Do().StatusCode)
+	assert.Equal(t, 503, client.Post("/payments", nil).Do().StatusCode)
+	res := client.Post("/payments", nil).Do()
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, "/payments/1", res.Headers.Get("Location"))
+	assert.True(t, client.AssertExpectations(&errorRecorder{}))
+}
+
+// Test the request matchers
+func TestMockMatchers(t *testing.T) {
+	client := NewDefaultMockClient(t)
+	byRegex := client.Expect("GET", MatchURLRegex(`^/users/\d+$`)).Respond(MockResponse{StatusCode: 200, JSONBody: "regex"})
+	byGlob := client.Expect("GET", MatchURLGlob("/users/*/orders"), MatchQuery("status", "paid")).Respond(MockResponse{StatusCode: 200, JSONBody: "glob"})
+	byHeader := client.Expect("GET", MatchURL("/me"), MatchHeader("X-Tenant", "a")).Respond(MockResponse{StatusCode: 200, JSONBody: "header"})
+	byJSON := client.Expect("POST", MatchURL("/users"), MatchJSONBody(`{"name":"john","age":30}`)).Respond(MockResponse{StatusCode: 201, JSONBody: "json"})
+	byPath := client.Expect("POST", MatchURL("/users"), MatchBodyPath("age", func(v gjson.Result) bool { return v.Int() < 18 })).Respond(MockResponse{StatusCode: 400, JSONBody: "path"})
+	byFunc := client.Expect("DELETE", MatchFunc("no body", func(r *Request) bool { return r.Body == nil })).Respond(MockResponse{StatusCode: 204})
+
+	assert.Equal(t, "regex", string(client.Get("/users/12").Do().BodyBytes))
+	assert.Equal(t, "glob", string(client.Get("/users/12/orders?status=paid").Do().BodyBytes))
+	assert.Equal(t, "header", string(client.Get("/me").WithHeader("X-Tenant", "a").Do().BodyBytes))
+	assert.Equal(t, "json", string(client.Post("/users", map[string]interface{}{"age": 30, "name": "john"}).Do().BodyBytes))
+	assert.Equal(t, "path", string(client.Post("/users", map[string]interface{}{"age": 10, "name": "tim"}).Do().BodyBytes))
+	assert.Equal(t, 204, client.Delete("/users/1").Do().StatusCode)
+
+	for _, e := range []*Expectation{byRegex, byGlob, byHeader, byJSON, byPath, byFunc} {
+		assert.Equal(t, 1, e.Calls(), e.String())
+	}
+}
+
+// Test the artificial latency respects the context of the request
+func TestMockLatency(t *testing.T) {
+	client := NewDefaultMockClient(t)
+	client.Expect("GET", MatchURL("/slow")).Respond(MockResponse{StatusCode: 200, Latency: 50 * time.Millisecond})
+
+	start := time.Now()
+	res := client.Get("/slow").Do()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	res = client.Get("/slow").WithContext(ctx).Do()
+	assert.ErrorIs(t, res.Error, context.DeadlineExceeded)
+}
+
+// Test unmet expectations are reported
+func TestMockUnmetExpectations(t *testing.T) {
+	client := NewDefaultMockClient(t)
+	client.Expect("GET", MatchURL("/twice")).Times(2)
+	client.Expect("GET", MatchURL("/never"))
+	client.Get("/twice").Do()
+
+	rec := &errorRecorder{}
+	assert.False(t, client.AssertExpectations(rec))
+	assert.ElementsMatch(t, []string{
+		"Expectation GET url=/twice was called 1 times, expected 2",
+		"Expectation GET url=/never was set but never requested",
+	}, rec.errors)
+
+	client.Get("/twice").Do()
+	client.Get("/never").Do()
+}
+
+// Test Never fails on any call and AnyTimes accepts no call
+func TestMockNeverAndAnyTimes(t *testing.T) {
+	client := NewMockClient(nil, "")
+	client.Expect("DELETE", MatchURL("/users/1")).Never()
+	client.Expect("GET", MatchURL("/users")).AnyTimes()
+	rec := &errorRecorder{}
+	assert.True(t, client.AssertExpectations(rec))
+
+	res := client.Delete("/users/1").Do()
+	assert.EqualError(t, res.Error, "mock: too many calls to DELETE/users/1")
+	assert.False(t, client.AssertExpectations(rec))
+	assert.Equal(t, []string{"Expectation DELETE url=/users/1 was called 1 times, expected 0"}, rec.errors)
+}
@@ -0,0 +1,128 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// ProblemContentType is the media type of the RFC 9457 problem details
+const ProblemContentType = "application/problem+json"
+
+// ProblemDetails is an RFC 9457 problem details object. Members other than the standard ones are kept in Extensions.
+// When a response has the application/problem+json content type it is decoded into Response.Error:
+//
+//	var problem *rest.ProblemDetails
+//	if errors.As(res.Error, &problem) {
+//		log.Printf("%s: %s", problem.Title, problem.Detail)
+//	}
+type ProblemDetails struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Extensions map[string]interface{}
+}
+
+var problemMembers = map[string]bool{"type": true, "title": true, "status": true, "detail": true, "instance": true}
+
+func (p *ProblemDetails) Error() string {
+	title := p.Title
+	if title == "" {
+		title = http.StatusText(p.Status)
+	}
+	if p.Detail != "" {
+		return fmt.Sprintf("%d %s: %s", p.Status, title, p.Detail)
+	}
+	return fmt.Sprintf("%d %s", p.Status, title)
+}
+
+func (p ProblemDetails) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		if !problemMembers[k] {
+			m[k] = v
+		}
+	}
+	m["type"] = p.Type
+	if p.Type == "" {
+		m["type"] = "about:blank"
+	}
+	if p.Title != "" {
+		m["title"] = p.Title
+	}
+	if p.Status != 0 {
+		m["status"] = p.Status
+	}
+	if p.Detail != "" {
+		m["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		m["instance"] = p.Instance
+	}
+	return json.Marshal(m)
+}
+
+func (p *ProblemDetails) UnmarshalJSON(b []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return err
+	}
+	*p = ProblemDetails{}
+	// members with the wrong type are ignored, as required by RFC 9457
+	_ = json.Unmarshal(m["type"], &p.Type)
+	_ = json.Unmarshal(m["title"], &p.Title)
+	_ = json.Unmarshal(m["status"], &p.Status)
+	_ = json.Unmarshal(m["detail"], &p.Detail)
+	_ = json.Unmarshal(m["instance"], &p.Instance)
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	for k, raw := range m {
+		if problemMembers[k] {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		if p.Extensions == nil {
+			p.Extensions = make(map[string]interface{})
+		}
+		p.Extensions[k] = v
+	}
+	return nil
+}
+
+// Problem returns the problem details of the response, false if it is not a problem+json response
+func (r *Response) Problem() (*ProblemDetails, bool) {
+	var p *ProblemDetails
+	if errors.As(r.Error, &p) {
+		return p, true
+	}
+	return nil, false
+}
+
+// IsProblemContentType returns true if contentType is application/problem+json, parameters are ignored
+func IsProblemContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == ProblemContentType
+}
+
+// decodeProblem sets the problem details of the body as the error of a failed problem+json response
+func (r *Response) decodeProblem() {
+	if r == nil || r.Error != nil || r.StatusCode < http.StatusBadRequest || !IsProblemContentType(r.Headers.Get("Content-Type")) {
+		return
+	}
+	p := &ProblemDetails{}
+	if err := json.Unmarshal(r.BodyBytes, p); err != nil {
+		return
+	}
+	if p.Status == 0 {
+		p.Status = r.StatusCode
+	}
+	r.Error = p
+}
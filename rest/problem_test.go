@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test problem+json responses are decoded into a ProblemDetails error
+func TestProblemResponseIsDecoded(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`{"type":"https://example.com/probs/out-of-credit","title":"You do not have enough credit.",
+			"detail":"Your current balance is 30, but that costs 50.","instance":"/account/12345/msgs/abc","balance":30}`))
+	}))
+	defer server.Close()
+	client := NewCustomRestClient(Config{BaseURL: server.URL})
+
+	res := client.Get("/account/12345/msgs/abc").Do()
+	assert.Equal(t, 403, res.StatusCode)
+
+	var problem *ProblemDetails
+	assert.True(t, errors.As(res.Error, &problem))
+	assert.Equal(t, "https://example.com/probs/out-of-credit", problem.Type)
+	assert.Equal(t, "You do not have enough credit.", problem.Title)
+	assert.Equal(t, 403, problem.Status)
+	assert.Equal(t, "/account/12345/msgs/abc", problem.Instance)
+	assert.Equal(t, float64(30), problem.Extensions["balance"])
+	assert.Equal(t, "403 You do not have enough credit.: Your current balance is 30, but that costs 50.", problem.Error())
+
+	p, ok := res.Problem()
+	assert.True(t, ok)
+	assert.Same(t, problem, p)
+}
+
+// Test other errors and successful responses are not decoded
+func TestNonProblemResponses(t *testing.T) {
+	mock := NewDefaultMockClient(t)
+	mock.SetMockCall("GET", "/json", MockResponse{StatusCode: 404, JSONBody: `{"title":"x"}`,
+		Headers: http.Header{"Content-Type": {"application/json"}}})
+	mock.SetMockCall("GET", "/ok", MockResponse{StatusCode: 200, JSONBody: `{"title":"x"}`,
+		Headers: http.Header{"Content-Type": {ProblemContentType}}})
+	mock.SetMockCall("GET", "/problem", MockResponse{StatusCode: 409, JSONBody: `{"title":"Conflict","status":"bad"}`,
+		Headers: http.Header{"Content-Type": {ProblemContentType}}})
+
+	for _, path := range []string{"/json", "/ok"} {
+		res := mock.Get(path).Do()
+		assert.Nil(t, res.Error, path)
+		_, ok := res.Problem()
+		assert.False(t, ok, path)
+	}
+
+	res := mock.Get("/problem").Do()
+	p, ok := res.Problem()
+	assert.True(t, ok)
+	assert.Equal(t, 409, p.Status)
+	assert.Equal(t, "about:blank", p.Type)
+}
+
+// Test the extensions are flattened when a problem is encoded
+func TestProblemMarshal(t *testing.T) {
+	b, err := json.Marshal(ProblemDetails{Status: 400, Detail: "bad", Extensions: map[string]interface{}{"errors": []string{"name"}, "status": 1}})
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"type":"about:blank","status":400,"detail":"bad","errors":["name"]}`, string(b))
+}
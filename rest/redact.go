@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const redactedValue = "[REDACTED]"
+
+// defaultRedactedHeaders are the headers whose values are never written to the debug buffer or to a cassette
+var defaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Amz-Security-Token",
+}
+
+// defaultRedactedFields are the JSON body fields, form fields and query params whose values are never written to the debug buffer or to a cassette.
+// The match is case-insensitive and done by substring, so "client_secret" is covered by "secret".
+var defaultRedactedFields = []string{
+	"password",
+	"secret",
+	"token",
+	"api_key",
+	"apikey",
+}
+
+// redactor replaces the values of secret headers, query params, form fields and JSON fields by [REDACTED]
+type redactor struct {
+	headers []string
+	fields  []string
+}
+
+func (r redactor) header(h http.Header) http.Header {
+	res := h.Clone()
+	for _, k := range r.headers {
+		if _, ok := res[http.CanonicalHeaderKey(k)]; ok {
+			res.Set(k, redactedValue)
+		}
+	}
+	return res
+}
+
+// url redacts the userinfo and the secret query params of u
+func (r redactor) url(u *url.URL) string {
+	clone := *u
+	if clone.User != nil {
+		clone.User = url.User(redactedValue)
+	}
+	if q := clone.Query(); r.values(q) {
+		clone.RawQuery = q.Encode()
+	}
+	return clone.String()
+}
+
+// values redacts the secret fields of v, it returns false if there is none
+func (r redactor) values(v url.Values) bool {
+	changed := false
+	for k := range v {
+		if r.isSecretField(k) {
+			v.Set(k, redactedValue)
+			changed = true
+		}
+	}
+	return changed
+}
+
+// body redacts b if it is a form, by the media type of contentType, or a JSON document.
+// Other bodies are returned untouched
+func (r redactor) body(b []byte, contentType string) []byte {
+	if mediaType, _, _ := mime.ParseMediaType(contentType); mediaType == "application/x-www-form-urlencoded" {
+		form, err := url.ParseQuery(string(b))
+		if err != nil || !r.values(form) {
+			return b
+		}
+		return []byte(form.Encode())
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return b
+	}
+	redacted, err := json.Marshal(r.value(v))
+	if err != nil {
+		return b
+	}
+	return redacted
+}
+
+func (r redactor) value(v interface{}) interface{} {
+	switch typed := v.(type) {
+	case map[string]interface{}:
+		for k, val := range typed {
+			if r.isSecretField(k) {
+				typed[k] = redactedValue
+				continue
+			}
+			typed[k] = r.value(val)
+		}
+	case []interface{}:
+		for i, val := range typed {
+			typed[i] = r.value(val)
+		}
+	}
+	return v
+}
+
+func (r redactor) isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	for _, f := range r.fields {
+		if strings.Contains(name, strings.ToLower(f)) {
+			return true
+		}
+	}
+	return false
+}
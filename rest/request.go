@@ -2,11 +2,16 @@ package rest
 
 import (
 	"context"
+	"encoding/gob"
 	"net/http"
-	"testing"
 	"time"
+
+	"github.com/google/uuid"
 )
 
+// IdempotencyKeyHeader is the header carrying the key that lets the server deduplicate unsafe requests
+const IdempotencyKeyHeader = "Idempotency-Key"
+
 type Request struct {
 	Method             string
 	URL                string
@@ -17,12 +22,14 @@ type Request struct {
 	AuthorizationToken *string
 	cached             bool
 	cacheTTL           time.Duration
+	cacheCondition     func(*Response) bool
 	client             *RestClient
+	baseURL            *string
 	ctx                context.Context
 	newRelicTrace      bool
 	pomeloTrace        bool
-	isMocked           bool
-	t                  *testing.T
+	mock               *MockClient
+	schema             *Schema
 }
 
 type Response struct {
@@ -34,6 +41,27 @@ type Response struct {
 	Error       error
 }
 
+// CachedResponse is the part of a Response stored by WithCache. It has no RawResponse nor Error, so any
+// cache.Codec can serialize it
+type CachedResponse struct {
+	StatusCode int
+	Headers    http.Header
+	Body       []byte
+}
+
+func init() {
+	gob.Register(&CachedResponse{})
+}
+
+func newCachedResponse(r *Response) *CachedResponse {
+	return &CachedResponse{StatusCode: r.StatusCode, Headers: r.Headers, Body: r.BodyBytes}
+}
+
+// response returns the cached response as a Response without RawResponse
+func (c *CachedResponse) response() *Response {
+	return &Response{StatusCode: c.StatusCode, Headers: c.Headers, BodyBytes: c.Body}
+}
+
 func (r *Request) WithHeader(key, value string) *Request {
 	if r.Headers == nil {
 		r.Headers = make(http.Header)
@@ -87,20 +115,59 @@ func (r *Request) WithRetries(retries int) *Request {
 	return r
 }
 
+// WithCache caches the successful responses in the Cache of the client for ttl, as a CachedResponse.
+// A ttl of 0 caches them without expiration. The responses read from the cache have no RawResponse
 func (r *Request) WithCache(ttl time.Duration) *Request {
 	r.cached = true
 	r.cacheTTL = ttl
 	return r
 }
 
+// WithCacheCondition caches the successful responses of a request set with WithCache only if cond returns true.
+// Useful for APIs that report errors in the body of a 200
+func (r *Request) WithCacheCondition(cond func(*Response) bool) *Request {
+	r.cacheCondition = cond
+	return r
+}
+
 func (r *Request) WithAuthorizationToken(token string) *Request {
 	r.AuthorizationToken = &token
 	return r
 }
 
+// WithCustomBaseURL overrides the base URL of the client for this request only
 func (r *Request) WithCustomBaseURL(baseURL string) *Request {
-	if r.client != nil {
-		r.client.Config.BaseURL = baseURL
-	}
+	r.baseURL = &baseURL
 	return r
 }
+
+// baseURLOrDefault returns the base URL of the request, the one of the client if it was not overridden
+func (r *Request) baseURLOrDefault() string {
+	if r.baseURL != nil {
+		return *r.baseURL
+	}
+	if r.client == nil {
+		return ""
+	}
+	if r.client.Config.BaseURL == "" && len(r.client.Config.BaseURLs) > 0 {
+		return r.client.Config.BaseURLs[0]
+	}
+	return r.client.Config.BaseURL
+}
+
+// WithIdempotencyKey sends key in the Idempotency-Key header. If no key is passed a random one is generated.
+// The key is kept in the request, so calling Do again on the same request (or any failover) reuses it
+// Example:
+//
+//	req := client.Post("/payments", payment).WithIdempotencyKey()
+//	res := req.Do()
+//	if res.Error != nil {
+//		res = req.Do() // same Idempotency-Key
+//	}
+func (r *Request) WithIdempotencyKey(key ...string) *Request {
+	k := uuid.NewString()
+	if len(key) > 0 && key[0] != "" {
+		k = key[0]
+	}
+	return r.WithHeader(IdempotencyKeyHeader, k)
+}
@@ -0,0 +1,337 @@
+// Package resttest provides an httptest backed fake server that exercises the real rest.RestClient,
+// including its cache, headers, timeouts and transport, instead of bypassing Request.Do like rest.MockClient.
+package resttest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+// Response is a canned response served by a Route. Reset closes the connection abruptly instead of answering
+type Response struct {
+	StatusCode int
+	Body       string
+	Headers    http.Header
+	Delay      time.Duration
+	Reset      bool
+}
+
+// ReceivedRequest is a request received by the Server
+type ReceivedRequest struct {
+	Method  string
+	Path    string
+	Query   url.Values
+	Headers http.Header
+	Body    []byte
+}
+
+// Route declares the canned responses of the requests matching a method and a path pattern.
+// The responses are served in order and the last one is repeated once the sequence is exhausted.
+type Route struct {
+	// mu is the mutex of the server, the route is read by its handler while the test may still declare it
+	mu        *sync.Mutex
+	method    string
+	pattern   string
+	responses []Response
+	headers   http.Header
+	delay     time.Duration
+	times     int
+	calls     int
+	asserts   []func(t *testing.T, r ReceivedRequest)
+}
+
+// Server is a fake HTTP server with a routing DSL.
+// Example:
+//
+//	server := resttest.NewServer(t)
+//	server.On("GET", "/users/*").FailTimes(2, 503).ReplyJSON(200, user)
+//	res := server.Client().Get("/users/1").Do()
+type Server struct {
+	*httptest.Server
+	t        *testing.T
+	mu       sync.Mutex
+	routes   []*Route
+	received []ReceivedRequest
+}
+
+// NewServer starts a new Server. It is closed and its routes are verified when the test ends
+func NewServer(t *testing.T) *Server {
+	s := &Server{t: t}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(func() {
+		s.Close()
+		s.AssertExpectations(t)
+	})
+	return s
+}
+
+// Client returns a real RestClient pointed at the server
+func (s *Server) Client() *rest.RestClient {
+	return s.ClientWithConfig(rest.Config{})
+}
+
+// ClientWithConfig returns a real RestClient pointed at the server with the given configuration. BaseURL is overwritten
+func (s *Server) ClientWithConfig(cfg rest.Config) *rest.RestClient {
+	cfg.BaseURL = s.URL
+	client := rest.NewCustomRestClient(cfg)
+	client.Cache = cache.NewMemoryCache("resttest", 100, time.Hour, false)
+	return client
+}
+
+// On declares a new route. pattern is matched against the path of the request and supports globs. Example: /users/*/orders
+func (s *Server) On(method, pattern string) *Route {
+	r := &Route{mu: &s.mu, method: method, pattern: pattern, headers: make(http.Header)}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.routes = append(s.routes, r)
+	return r
+}
+
+// Requests returns the requests received by the server, in order
+func (s *Server) Requests() []ReceivedRequest {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]ReceivedRequest(nil), s.received...)
+}
+
+// CountRequests returns the number of requests received for the given method and path
+func (s *Server) CountRequests(method, p string) int {
+	count := 0
+	for _, r := range s.Requests() {
+		if r.Method == method && r.Path == p {
+			count++
+		}
+	}
+	return count
+}
+
+// AssertExpectations fails the test if a route was never requested or did not receive the number of calls set with Times
+func (s *Server) AssertExpectations(t *testing.T) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ok := true
+	for _, r := range s.routes {
+		if r.times > 0 && r.calls != r.times {
+			t.Errorf("Route %s %s was called %d times, expected %d", r.method, r.pattern, r.calls, r.times)
+			ok = false
+		} else if r.calls == 0 {
+			t.Errorf("Route %s %s was declared but never requested", r.method, r.pattern)
+			ok = false
+		}
+	}
+	return ok
+}
+
+// Reply appends a response to the sequence of the route
+func (r *Route) Reply(statusCode int, body string) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, Response{StatusCode: statusCode, Body: body})
+	return r
+}
+
+// ReplyJSON appends a response with v marshalled as JSON to the sequence of the route
+func (r *Route) ReplyJSON(statusCode int, v interface{}) *Route {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic("resttest: invalid JSON reply: " + err.Error())
+	}
+	return r.ReplyWith(Response{
+		StatusCode: statusCode,
+		Body:       string(b),
+		Headers:    http.Header{"Content-Type": {"application/json"}},
+	})
+}
+
+// ReplyWith appends custom responses to the sequence of the route
+func (r *Route) ReplyWith(responses ...Response) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.responses = append(r.responses, responses...)
+	return r
+}
+
+// FailTimes appends n responses with the given status code. Useful to simulate 5xx bursts
+func (r *Route) FailTimes(n int, statusCode int) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < n; i++ {
+		r.responses = append(r.responses, Response{StatusCode: statusCode, Body: http.StatusText(statusCode)})
+	}
+	return r
+}
+
+// ResetTimes appends n responses that close the connection abruptly
+func (r *Route) ResetTimes(n int) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := 0; i < n; i++ {
+		r.responses = append(r.responses, Response{Reset: true})
+	}
+	return r
+}
+
+// Header sets a header on every response of the route
+func (r *Route) Header(key, value string) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers.Set(key, value)
+	return r
+}
+
+// Delay delays every response of the route
+func (r *Route) Delay(d time.Duration) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.delay = d
+	return r
+}
+
+// Times sets the exact number of calls expected for the route
+func (r *Route) Times(n int) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.times = n
+	return r
+}
+
+// Assert registers a function that is called with every request received by the route
+func (r *Route) Assert(fn func(t *testing.T, req ReceivedRequest)) *Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.asserts = append(r.asserts, fn)
+	return r
+}
+
+// ExpectHeader asserts every request of the route has the header key with the given value
+func (r *Route) ExpectHeader(key, value string) *Route {
+	return r.Assert(func(t *testing.T, req ReceivedRequest) {
+		if got := req.Headers.Get(key); got != value {
+			t.Errorf("%s %s: expected header %s=%q, got %q", req.Method, req.Path, key, value, got)
+		}
+	})
+}
+
+// ExpectJSONBody asserts every request of the route has a body semantically equal to the expected JSON
+func (r *Route) ExpectJSONBody(expected string) *Route {
+	return r.Assert(func(t *testing.T, req ReceivedRequest) {
+		var want, got interface{}
+		if err := json.Unmarshal([]byte(expected), &want); err != nil {
+			t.Errorf("invalid expected JSON: %v", err)
+			return
+		}
+		if err := json.Unmarshal(req.Body, &got); err != nil || !reflect.DeepEqual(want, got) {
+			t.Errorf("%s %s: expected body %s, got %s", req.Method, req.Path, expected, req.Body)
+		}
+	})
+}
+
+func (r *Route) matches(method, p string) bool {
+	if r.method != method {
+		return false
+	}
+	ok, err := path.Match(r.pattern, p)
+	return err == nil && ok
+}
+
+func (r *Route) next() Response {
+	r.calls++
+	if len(r.responses) == 0 {
+		return Response{StatusCode: http.StatusOK}
+	}
+	idx := r.calls - 1
+	if idx >= len(r.responses) {
+		idx = len(r.responses) - 1
+	}
+	return r.responses[idx]
+}
+
+func (s *Server) serve(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	received := ReceivedRequest{
+		Method:  req.Method,
+		Path:    req.URL.Path,
+		Query:   req.URL.Query(),
+		Headers: req.Header.Clone(),
+		Body:    body,
+	}
+
+	s.mu.Lock()
+	s.received = append(s.received, received)
+	var route *Route
+	for _, r := range s.routes {
+		if r.matches(req.Method, req.URL.Path) {
+			route = r
+			break
+		}
+	}
+	if route == nil {
+		s.mu.Unlock()
+		s.t.Errorf("resttest: no route for %s %s", req.Method, req.URL.Path)
+		http.Error(w, "no route", http.StatusNotFound)
+		return
+	}
+	res := route.next()
+	headers := route.headers.Clone()
+	delay := route.delay
+	asserts := route.asserts
+	s.mu.Unlock()
+
+	for _, fn := range asserts {
+		fn(s.t, received)
+	}
+
+	if res.Delay > 0 {
+		delay = res.Delay
+	}
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-req.Context().Done():
+			return
+		}
+	}
+
+	if res.Reset {
+		resetConnection(w)
+		return
+	}
+
+	for k, v := range headers {
+		w.Header()[k] = v
+	}
+	for k, v := range res.Headers {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, strings.NewReader(res.Body))
+}
+
+// resetConnection closes the underlying TCP connection sending a RST instead of a FIN
+func resetConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("resttest: connection reset not supported")
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		return
+	}
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		_ = tcp.SetLinger(0)
+	}
+	conn.Close()
+}
@@ -0,0 +1,143 @@
+package resttest
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/cache/cachetest"
+	"github.com/abraham-corales/go-lib/rest"
+	"github.com/stretchr/testify/assert"
+)
+
+// Test canned responses and request assertions go through the real client
+func TestServerRoutes(t *testing.T) {
+	server := NewServer(t)
+	server.On("POST", "/users").
+		ExpectHeader("X-Tenant", "a").
+		ExpectJSONBody(`{"name":"john"}`).
+		ReplyJSON(201, map[string]int{"id": 1}).
+		Times(1)
+	server.On("GET", "/users/*").Header("X-Version", "2").Reply(200, `{"id":1}`)
+
+	client := server.Client()
+	res := client.Post("/users", map[string]string{"name": "john"}).WithHeader("X-Tenant", "a").Do()
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 201, res.StatusCode)
+	assert.Equal(t, "application/json", res.Headers.Get("Content-Type"))
+
+	res = client.Get("/users/1").Do()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "2", res.Headers.Get("X-Version"))
+
+	requests := server.Requests()
+	assert.Len(t, requests, 2)
+	assert.Equal(t, "/users", requests[0].Path)
+	assert.Equal(t, 1, server.CountRequests("GET", "/users/1"))
+}
+
+// Test the cache of the client avoids reaching the server, with a memory and a redis cache
+func TestServerClientCache(t *testing.T) {
+	redis := cachetest.NewRedisServer(t, "")
+	caches := map[string]cache.Spec{
+		"memory": cache.NewMemoryCache("responses", 10, time.Minute, false),
+		"redis":  cache.NewRedisCache("responses", time.Minute, cache.RedisConfig{Addr: redis.Addr(), KeyPrefix: "json:"}),
+		"gob": cache.NewRedisCache("responses", time.Minute,
+			cache.RedisConfig{Addr: redis.Addr(), KeyPrefix: "gob:", Codec: cache.GobCodec{}}),
+	}
+	for name, spec := range caches {
+		t.Run(name, func(t *testing.T) {
+			server := NewServer(t)
+			server.On("GET", "/users").Header("X-Version", "2").Reply(200, `[{"name":"john"}]`)
+			client := server.Client()
+			client.Cache = spec
+
+			first := client.Get("/users").WithCache(time.Minute).Do()
+			second := client.Get("/users").WithCache(time.Minute).Do()
+			assert.Nil(t, second.Error)
+			assert.Equal(t, 1, server.CountRequests("GET", "/users"))
+			assert.Equal(t, first.StatusCode, second.StatusCode)
+			assert.Equal(t, first.BodyBytes, second.BodyBytes)
+			assert.Equal(t, "2", second.Headers.Get("X-Version"))
+			assert.Nil(t, second.RawResponse)
+		})
+	}
+}
+
+// Test the fault injection
+func TestServerFaults(t *testing.T) {
+	server := NewServer(t)
+	server.On("GET", "/flaky").FailTimes(2, http.StatusServiceUnavailable).Reply(200, "ok")
+	server.On("POST", "/reset").ResetTimes(1).Reply(200, "ok")
+	server.On("GET", "/slow").Delay(200*time.Millisecond).Reply(200, "ok")
+	client := server.Client()
+
+	assert.Equal(t, 503, client.Get("/flaky").Do().StatusCode)
+	assert.Equal(t, 503, client.Get("/flaky").Do().StatusCode)
+	assert.Equal(t, 200, client.Get("/flaky").Do().StatusCode)
+
+	// POST is used because net/http transparently retries idempotent requests on reused connections
+	assert.NotNil(t, client.Post("/reset", nil).Do().Error)
+	assert.Equal(t, "ok", string(client.Post("/reset", nil).Do().BodyBytes))
+
+	res := client.Get("/slow").WithTimeout(20).Do()
+	assert.NotNil(t, res.Error)
+	assert.Contains(t, res.Error.Error(), "deadline exceeded")
+}
+
+// Test the retries of the client and of the request go through the server
+func TestServerClientRetries(t *testing.T) {
+	server := NewServer(t)
+	server.On("GET", "/flaky").FailTimes(2, http.StatusBadGateway).Reply(200, "ok")
+	server.On("POST", "/orders").FailTimes(1, http.StatusServiceUnavailable).Reply(201, "created")
+	server.On("GET", "/down").Reply(http.StatusServiceUnavailable, "down")
+	client := server.ClientWithConfig(rest.Config{Retries: 2})
+
+	res := client.Get("/flaky").Do()
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, 3, server.CountRequests("GET", "/flaky"))
+
+	// unsafe requests without an Idempotency-Key are not retried
+	assert.Equal(t, 503, client.Post("/orders", nil).Do().StatusCode)
+	assert.Equal(t, 1, server.CountRequests("POST", "/orders"))
+
+	assert.Equal(t, 503, client.Get("/down").WithRetries(1).Do().StatusCode)
+	assert.Equal(t, 2, server.CountRequests("GET", "/down"))
+}
+
+// Test the interceptors of the client see the requests and the responses
+func TestServerClientInterceptors(t *testing.T) {
+	server := NewServer(t)
+	server.On("GET", "/users").ExpectHeader("X-Tenant", "a").Reply(200, `[]`)
+	var statuses []int
+	client := server.Client().
+		WithRequestInterceptors(func(r *rest.Request) { r.WithHeader("X-Tenant", "a") }).
+		WithResponseInterceptors(func(r *rest.Response) { statuses = append(statuses, r.StatusCode) })
+
+	assert.Nil(t, client.Get("/users").Do().Error)
+	assert.Equal(t, []int{200}, statuses)
+}
+
+// Test the routes can be declared while the server handles requests
+func TestServerConcurrentRoutes(t *testing.T) {
+	server := NewServer(t)
+	route := server.On("GET", "/users").Reply(200, `[]`)
+	client := server.Client()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			client.Get("/users").Do()
+		}()
+		go func() {
+			defer wg.Done()
+			route.Header("X-Version", "2").Reply(200, `[]`)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 10, server.CountRequests("GET", "/users"))
+}
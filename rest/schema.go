@@ -0,0 +1,168 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// Schema is a compiled JSON Schema. Draft 2020-12 is used unless the schema declares another $schema
+type Schema struct {
+	schema *jsonschema.Schema
+}
+
+// CompileSchema compiles a JSON Schema, the schema is compiled once and can be shared by many requests
+func CompileSchema(schema []byte) (*Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing schema")
+	}
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	if err := compiler.AddResource("schema.json", doc); err != nil {
+		return nil, errors.Wrap(err, "error adding schema")
+	}
+	compiled, err := compiler.Compile("schema.json")
+	if err != nil {
+		return nil, errors.Wrap(err, "error compiling schema")
+	}
+	return &Schema{schema: compiled}, nil
+}
+
+// MustCompileSchema is like CompileSchema but panics if the schema is invalid. Useful for package level schemas
+func MustCompileSchema(schema string) *Schema {
+	s, err := CompileSchema([]byte(schema))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// SchemaViolation is a value of the body that does not match the schema
+type SchemaViolation struct {
+	// Path is the JSON pointer of the value in the body, empty for the root
+	Path string
+	// Keyword is the JSON pointer of the failed keyword in the schema, references are resolved
+	Keyword string
+	Message string
+}
+
+// SchemaValidationError is returned when the body does not match the schema.
+// Example:
+//
+//	var invalid *rest.SchemaValidationError
+//	if errors.As(res.Error, &invalid) {
+//		for _, v := range invalid.Violations {
+//			log.Printf("%s: %s", v.Path, v.Message)
+//		}
+//	}
+type SchemaValidationError struct {
+	Violations []SchemaViolation
+}
+
+func (e *SchemaValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		path := v.Path
+		if path == "" {
+			path = "/"
+		}
+		msgs[i] = path + ": " + v.Message
+	}
+	return "body does not match the schema: " + strings.Join(msgs, "; ")
+}
+
+// Validate returns a *SchemaValidationError if body does not match the schema
+func (s *Schema) Validate(body []byte) error {
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return &SchemaValidationError{Violations: []SchemaViolation{{Message: "invalid JSON: " + err.Error()}}}
+	}
+	err = s.schema.Validate(instance)
+	if err == nil {
+		return nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	out := &SchemaValidationError{}
+	collectViolations(verr, &out.Violations)
+	return out
+}
+
+// collectViolations appends the leaves of the error tree, they are the actual failed keywords
+func collectViolations(err *jsonschema.ValidationError, violations *[]SchemaViolation) {
+	if len(err.Causes) == 0 {
+		// SchemaURL is the dereferenced location of the subschema, like schema.json#/properties/id
+		_, location, _ := strings.Cut(err.SchemaURL, "#")
+		*violations = append(*violations, SchemaViolation{
+			Path:    jsonPointer(err.InstanceLocation),
+			Keyword: location + jsonPointer(err.ErrorKind.KeywordPath()),
+			Message: err.ErrorKind.LocalizedString(schemaPrinter),
+		})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectViolations(cause, violations)
+	}
+}
+
+// jsonPointer builds an RFC 6901 JSON pointer from its reference tokens
+func jsonPointer(tokens []string) string {
+	var sb strings.Builder
+	for _, t := range tokens {
+		sb.WriteString("/")
+		sb.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+	}
+	return sb.String()
+}
+
+// ExpectSchema validates the body of the successful (2xx) responses against schema.
+// If the body does not match, Response.Error is a *SchemaValidationError and the response is not cached.
+// Example:
+//
+//	var userSchema = rest.MustCompileSchema(`{"type":"object","required":["id"],"properties":{"id":{"type":"integer"}}}`)
+//
+//	res := client.Get("/users/1").ExpectSchema(userSchema).Do()
+func (r *Request) ExpectSchema(schema *Schema) *Request {
+	r.schema = schema
+	return r
+}
+
+// validateSchema sets the validation error of the body in the response if the request expects a schema
+func (r *Request) validateSchema(response *Response) {
+	if r.schema == nil || response == nil || response.Error != nil ||
+		response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return
+	}
+	if err := r.schema.Validate(response.BodyBytes); err != nil {
+		response.Error = err
+	}
+}
+
+// MapToStrict is like MapTo but returns an error if the body has fields that are not in bindTo
+// or if there is data after the JSON value
+func (r *Response) MapToStrict(bindTo interface{}) error {
+	if r.BodyBytes == nil {
+		return errors.New("response body is nil")
+	}
+	decoder := json.NewDecoder(bytes.NewReader(r.BodyBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(bindTo); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON value at offset %d", decoder.InputOffset())
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package rest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var userSchema = MustCompileSchema(`{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["id", "name"],
+	"properties": {
+		"id": {"type": "integer"},
+		"name": {"type": "string"},
+		"tags": {"type": "array", "items": {"$ref": "#/$defs/tag"}}
+	},
+	"$defs": {"tag": {"type": "string", "maxLength": 3}}
+}`)
+
+// Test the body is validated before it is returned
+func TestExpectSchema(t *testing.T) {
+	mock := NewDefaultMockClient(t)
+	mock.SetMockCall("GET", "/users/1", MockResponse{StatusCode: 200, JSONBody: `{"id":1,"name":"ana","tags":["a"]}`})
+	mock.SetMockCall("GET", "/users/2", MockResponse{StatusCode: 200, JSONBody: `{"id":"2","tags":["a","long"]}`})
+	mock.SetMockCall("GET", "/users/3", MockResponse{StatusCode: 404, JSONBody: `{"message":"not found"}`})
+
+	res := mock.Get("/users/1").ExpectSchema(userSchema).Do()
+	assert.Nil(t, res.Error)
+
+	res = mock.Get("/users/2").ExpectSchema(userSchema).Do()
+	var invalid *SchemaValidationError
+	assert.True(t, errors.As(res.Error, &invalid))
+	assert.ElementsMatch(t, []string{"", "/id", "/tags/1"}, paths(invalid))
+	for _, v := range invalid.Violations {
+		assert.NotEmpty(t, v.Message)
+		switch v.Path {
+		case "":
+			assert.Equal(t, "/required", v.Keyword)
+			assert.Contains(t, v.Message, "name")
+		case "/id":
+			assert.Equal(t, "/properties/id/type", v.Keyword)
+		case "/tags/1":
+			assert.Equal(t, "/$defs/tag/maxLength", v.Keyword)
+		}
+	}
+
+	// error responses have their own shape
+	res = mock.Get("/users/3").ExpectSchema(userSchema).Do()
+	assert.Nil(t, res.Error)
+}
+
+// Test invalid JSON and pointer escaping
+func TestSchemaValidate(t *testing.T) {
+	schema := MustCompileSchema(`{"properties":{"a/b~c":{"type":"string"}}}`)
+	assert.Nil(t, schema.Validate([]byte(`{"a/b~c":"x"}`)))
+
+	err := schema.Validate([]byte(`{"a/b~c":1}`))
+	var invalid *SchemaValidationError
+	assert.True(t, errors.As(err, &invalid))
+	assert.Equal(t, []string{"/a~1b~0c"}, paths(invalid))
+
+	err = schema.Validate([]byte(`{`))
+	assert.True(t, errors.As(err, &invalid))
+	assert.Contains(t, err.Error(), "invalid JSON")
+
+	_, err = CompileSchema([]byte(`{"type":"unknown"}`))
+	assert.NotNil(t, err)
+}
+
+// Test strict decoding rejects unknown fields
+func TestMapToStrict(t *testing.T) {
+	type user struct {
+		ID int `json:"id"`
+	}
+	var u user
+	assert.Nil(t, (&Response{BodyBytes: []byte(`{"id":1}`)}).MapToStrict(&u))
+	assert.Equal(t, 1, u.ID)
+
+	err := (&Response{BodyBytes: []byte(`{"id":1,"email":"a@b.c"}`)}).MapToStrict(&u)
+	assert.EqualError(t, err, `json: unknown field "email"`)
+	assert.Nil(t, (&Response{BodyBytes: []byte(`{"id":1,"email":"a@b.c"}`)}).MapTo(&u))
+
+	assert.NotNil(t, (&Response{BodyBytes: []byte(`{"id":1} {"id":2}`)}).MapToStrict(&u))
+}
+
+func paths(err *SchemaValidationError) []string {
+	out := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		out[i] = v.Path
+	}
+	return out
+}
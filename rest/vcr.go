@@ -0,0 +1,291 @@
+package rest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// VCRMode defines if a VCR records real interactions or replays them from the cassette
+type VCRMode int
+
+const (
+	// VCRModeReplay serves the interactions from the cassette and never reaches the network
+	VCRModeReplay VCRMode = iota
+	// VCRModeRecord proxies every call to the real server and overwrites the cassette
+	VCRModeRecord
+	// VCRModeAuto replays the cassette if it exists, otherwise it records a new one
+	VCRModeAuto
+)
+
+// ErrInteractionNotFound is returned in replay mode when no interaction of the cassette matches the request
+var ErrInteractionNotFound = errors.New("vcr: no interaction found for request")
+
+// VCROptions configures how a VCR matches and sanitizes interactions
+type VCROptions struct {
+	Mode VCRMode
+	// MatchBody also compares the request body when looking for an interaction. JSON bodies are compared semantically
+	MatchBody bool
+	// MatchHeaders are the request headers that must be equal when looking for an interaction
+	MatchHeaders []string
+	// SanitizeHeaders are the headers whose values are replaced by [REDACTED] before writing. Defaults to the usual auth headers
+	SanitizeHeaders []string
+	// SanitizeFields are the JSON body fields and query params whose values are replaced by [REDACTED] before writing
+	SanitizeFields []string
+	// Transport is used to reach the real server while recording. Defaults to http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// Interaction is a request/response pair stored in a cassette
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the sanitized request of an Interaction
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the sanitized response of an Interaction
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+// VCR is an http.RoundTripper that records the interactions with a real server into a cassette file (JSON lines)
+// and replays them offline afterward.
+// Example:
+//
+//	vcr, err := rest.NewVCR("testdata/users.jsonl", rest.VCROptions{Mode: rest.VCRModeAuto})
+//	client := rest.NewCustomRestClient(rest.Config{BaseURL: "https://api.example.com", Transport: vcr})
+type VCR struct {
+	path         string
+	opts         VCROptions
+	redactor     redactor
+	recording    bool
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewVCR creates a VCR backed by the cassette at path
+func NewVCR(path string, opts VCROptions) (*VCR, error) {
+	if opts.SanitizeHeaders == nil {
+		opts.SanitizeHeaders = defaultRedactedHeaders
+	}
+	if opts.SanitizeFields == nil {
+		opts.SanitizeFields = defaultRedactedFields
+	}
+	if opts.Transport == nil {
+		opts.Transport = http.DefaultTransport
+	}
+	v := &VCR{
+		path:     path,
+		opts:     opts,
+		redactor: redactor{headers: opts.SanitizeHeaders, fields: opts.SanitizeFields},
+	}
+
+	recording := opts.Mode == VCRModeRecord
+	if opts.Mode == VCRModeAuto {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			recording = true
+		}
+	}
+	v.recording = recording
+
+	if recording {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return nil, err
+		}
+		return v, os.WriteFile(path, nil, 0o644)
+	}
+	return v, v.load()
+}
+
+// Recording returns true if the VCR is proxying calls to the real server
+func (v *VCR) Recording() bool {
+	return v.recording
+}
+
+// Interactions returns the interactions of the cassette
+func (v *VCR) Interactions() []Interaction {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([]Interaction(nil), v.interactions...)
+}
+
+func (v *VCR) load() error {
+	f, err := os.Open(v.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var i Interaction
+		if err := json.Unmarshal(line, &i); err != nil {
+			return fmt.Errorf("vcr: invalid cassette %s: %w", v.path, err)
+		}
+		v.interactions = append(v.interactions, i)
+	}
+	v.used = make([]bool, len(v.interactions))
+	return scanner.Err()
+}
+
+// RoundTrip records or replays the request depending on the mode of the VCR
+func (v *VCR) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	recorded := v.sanitizeRequest(req, body)
+
+	if v.recording {
+		return v.record(req, recorded)
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	idx := -1
+	for i := range v.interactions {
+		if v.matches(v.interactions[i].Request, recorded) {
+			if !v.used[i] {
+				idx = i
+				break
+			}
+			if idx < 0 {
+				// all the matching interactions were already served, reuse the first one
+				idx = i
+			}
+		}
+	}
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: %s %s", ErrInteractionNotFound, recorded.Method, recorded.URL)
+	}
+	v.used[idx] = true
+	res := v.interactions[idx].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", res.StatusCode, http.StatusText(res.StatusCode)),
+		StatusCode:    res.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        res.Headers.Clone(),
+		Body:          io.NopCloser(strings.NewReader(res.Body)),
+		ContentLength: int64(len(res.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (v *VCR) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	res, err := v.opts.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(resBody))
+
+	interaction := Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Headers:    v.redactor.header(res.Header),
+			Body:       string(v.redactor.body(resBody, res.Header.Get("Content-Type"))),
+		},
+	}
+	line, err := json.Marshal(interaction)
+	if err != nil {
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	f, err := os.OpenFile(v.path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	v.interactions = append(v.interactions, interaction)
+	v.used = append(v.used, true)
+	return res, nil
+}
+
+func (v *VCR) sanitizeRequest(req *http.Request, body []byte) RecordedRequest {
+	recorded := RecordedRequest{
+		Method:  req.Method,
+		URL:     v.redactor.url(req.URL),
+		Headers: v.redactor.header(req.Header),
+	}
+	if len(body) > 0 {
+		recorded.Body = string(v.redactor.body(body, req.Header.Get("Content-Type")))
+	}
+	return recorded
+}
+
+func (v *VCR) matches(recorded, req RecordedRequest) bool {
+	if recorded.Method != req.Method || recorded.URL != req.URL {
+		return false
+	}
+	for _, h := range v.opts.MatchHeaders {
+		if recorded.Headers.Get(h) != req.Headers.Get(h) {
+			return false
+		}
+	}
+	if v.opts.MatchBody {
+		return equalBodies(recorded.Body, req.Body)
+	}
+	return true
+}
+
+// equalBodies compares two bodies semantically if both are JSON, byte by byte otherwise
+func equalBodies(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var ja, jb interface{}
+	if json.Unmarshal([]byte(a), &ja) != nil || json.Unmarshal([]byte(b), &jb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(ja, jb)
+}
+
+// readRequestBody reads the body of req and restores it so the request can still be sent
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,108 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test a cassette recorded once can be replayed offline
+func TestVCRRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":1,"token":"server-secret"}`))
+	}))
+	cassette := filepath.Join(t.TempDir(), "cassettes", "users.jsonl")
+
+	recorder, err := NewVCR(cassette, VCROptions{Mode: VCRModeAuto, MatchBody: true})
+	assert.Nil(t, err)
+	assert.True(t, recorder.Recording())
+	client := NewCustomRestClient(Config{BaseURL: server.URL, Transport: recorder})
+	res := client.Post("/users", map[string]string{"name": "john", "password": "1234"}).
+		WithAuthorizationToken("client-secret").
+		Do()
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 1, calls)
+	server.Close()
+
+	content, err := os.ReadFile(cassette)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "client-secret")
+	assert.NotContains(t, string(content), "server-secret")
+	assert.NotContains(t, string(content), "1234")
+
+	replayer, err := NewVCR(cassette, VCROptions{Mode: VCRModeAuto, MatchBody: true})
+	assert.Nil(t, err)
+	assert.False(t, replayer.Recording())
+	client = NewCustomRestClient(Config{BaseURL: server.URL, Transport: replayer})
+
+	res = client.Post("/users", map[string]string{"password": "other", "name": "john"}).Do()
+	assert.Nil(t, res.Error)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.JSONEq(t, `{"id":1,"token":"[REDACTED]"}`, string(res.BodyBytes))
+	assert.Equal(t, 1, calls)
+
+	res = client.Post("/users", map[string]string{"name": "jane"}).Do()
+	assert.NotNil(t, res.Error)
+	assert.Contains(t, res.Error.Error(), ErrInteractionNotFound.Error())
+}
+
+// Test replay mode fails if the cassette does not exist
+func TestVCRReplayWithoutCassette(t *testing.T) {
+	_, err := NewVCR(filepath.Join(t.TempDir(), "missing.jsonl"), VCROptions{Mode: VCRModeReplay})
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+// Test header matching
+func TestVCRMatchHeaders(t *testing.T) {
+	cassette := filepath.Join(t.TempDir(), "headers.jsonl")
+	err := os.WriteFile(cassette, []byte(`{"request":{"method":"GET","url":"http://api/users","headers":{"X-Tenant":["a"]}},"response":{"status_code":200,"body":"a"}}
+{"request":{"method":"GET","url":"http://api/users","headers":{"X-Tenant":["b"]}},"response":{"status_code":200,"body":"b"}}
+`), 0o644)
+	assert.Nil(t, err)
+
+	replayer, err := NewVCR(cassette, VCROptions{Mode: VCRModeReplay, MatchHeaders: []string{"X-Tenant"}})
+	assert.Nil(t, err)
+	client := NewCustomRestClient(Config{BaseURL: "http://api", Transport: replayer})
+
+	assert.Equal(t, "b", string(client.Get("/users").WithHeader("X-Tenant", "b").Do().BodyBytes))
+	assert.Equal(t, "a", string(client.Get("/users").WithHeader("X-Tenant", "a").Do().BodyBytes))
+	assert.NotNil(t, client.Get("/users").WithHeader("X-Tenant", "c").Do().Error)
+}
+
+// Test the secrets of a form-encoded token request are not recorded
+func TestVCRRedactsFormBodies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"server-token","expires_in":3600}`))
+	}))
+	defer server.Close()
+	cassette := filepath.Join(t.TempDir(), "token.jsonl")
+	recorder, err := NewVCR(cassette, VCROptions{Mode: VCRModeRecord})
+	assert.Nil(t, err)
+
+	source := NewClientCredentialsTokenSource(ClientCredentialsConfig{
+		TokenURL:     server.URL,
+		ClientID:     "cli-1",
+		ClientSecret: "client-secret",
+		AuthInParams: true,
+		HTTPClient:   &http.Client{Transport: recorder},
+	})
+	_, err = source.Token(context.Background())
+	assert.Nil(t, err)
+
+	content, err := os.ReadFile(cassette)
+	assert.Nil(t, err)
+	assert.NotContains(t, string(content), "client-secret")
+	assert.NotContains(t, string(content), "server-token")
+	assert.Contains(t, string(content), "client_id=cli-1")
+	assert.Contains(t, string(content), "client_secret=%5BREDACTED%5D")
+}
@@ -0,0 +1,314 @@
+// Package sigv4 implements AWS Signature Version 4 request signing and presigned URLs, so RestClient can call
+// AWS style APIs like API Gateway with IAM auth or S3 compatible stores.
+package sigv4
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm = "AWS4-HMAC-SHA256"
+	// UnsignedPayload is the payload hash used when the body is not signed, like in streaming uploads
+	UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+	timeFormat  = "20060102T150405Z"
+	shortFormat = "20060102"
+
+	headerDate          = "X-Amz-Date"
+	headerSecurityToken = "X-Amz-Security-Token"
+	headerContentSHA256 = "X-Amz-Content-Sha256"
+)
+
+// ignoredHeaders are never signed because proxies and the transport may change them
+var ignoredHeaders = map[string]bool{
+	"authorization":   true,
+	"user-agent":      true,
+	"x-amzn-trace-id": true,
+	"expect":          true,
+	"connection":      true,
+}
+
+// Credentials are the AWS credentials used to sign. SessionToken is only set for temporary credentials
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsProvider returns the credentials to sign each request, so they can be refreshed
+type CredentialsProvider interface {
+	Retrieve(ctx context.Context) (Credentials, error)
+}
+
+// StaticCredentials is a CredentialsProvider that always returns the same credentials
+type StaticCredentials Credentials
+
+func (c StaticCredentials) Retrieve(_ context.Context) (Credentials, error) {
+	return Credentials(c), nil
+}
+
+// EnvCredentials reads the credentials from AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN
+type EnvCredentials struct{}
+
+func (EnvCredentials) Retrieve(_ context.Context) (Credentials, error) {
+	c := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return c, errors.New("sigv4: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are not set")
+	}
+	return c, nil
+}
+
+// Signer is a rest.RequestSigner that signs the requests of a RestClient with AWS Signature Version 4.
+// Example:
+//
+//	signer := &sigv4.Signer{Credentials: sigv4.EnvCredentials{}, Region: "us-east-1", Service: "execute-api"}
+//	client := rest.NewCustomRestClient(cfg).WithSigners(signer)
+type Signer struct {
+	Credentials CredentialsProvider
+	Region      string
+	Service     string
+	// UnsignedPayload skips the hash of the body and signs UNSIGNED-PAYLOAD instead. Useful for streaming uploads to S3
+	UnsignedPayload bool
+	// DisableURIPathEscaping avoids escaping the path twice. It must be true for S3 and is set automatically for the s3 service
+	DisableURIPathEscaping bool
+	// Now returns the signing time. Defaults to time.Now
+	Now func() time.Time
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to req.
+// body is the exact payload of the request
+func (s *Signer) Sign(req *http.Request, body []byte) error {
+	creds, err := s.Credentials.Retrieve(req.Context())
+	if err != nil {
+		return err
+	}
+	payloadHash := UnsignedPayload
+	if !s.UnsignedPayload {
+		payloadHash = HashPayload(body)
+	}
+	return s.SignWithPayloadHash(req, creds, payloadHash, s.now())
+}
+
+// SignWithPayloadHash signs req with the given credentials, payload hash and time
+func (s *Signer) SignWithPayloadHash(req *http.Request, creds Credentials, payloadHash string, t time.Time) error {
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return errors.New("sigv4: empty credentials")
+	}
+	t = t.UTC()
+	amzDate := t.Format(timeFormat)
+
+	req.Header.Del("Authorization")
+	req.Header.Set(headerDate, amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set(headerSecurityToken, creds.SessionToken)
+	}
+	if s.isS3() || payloadHash == UnsignedPayload {
+		req.Header.Set(headerContentSHA256, payloadHash)
+	}
+
+	signedHeaders, canonicalHeaders := canonicalHeaders(req)
+	canonical := strings.Join([]string{
+		req.Method,
+		s.canonicalURI(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := s.scope(t)
+	signature := s.signature(creds.SecretAccessKey, t, stringToSign(amzDate, scope, canonical))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+// Presign returns a presigned URL for req valid for expires. The body of the request is not signed
+// for S3 (UNSIGNED-PAYLOAD), for other services the request must not have a body
+func (s *Signer) Presign(ctx context.Context, req *http.Request, expires time.Duration) (string, error) {
+	creds, err := s.Credentials.Retrieve(ctx)
+	if err != nil {
+		return "", err
+	}
+	return s.PresignWithTime(req, creds, expires, s.now())
+}
+
+// PresignWithTime returns a presigned URL for req with the given credentials and signing time
+func (s *Signer) PresignWithTime(req *http.Request, creds Credentials, expires time.Duration, t time.Time) (string, error) {
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return "", errors.New("sigv4: empty credentials")
+	}
+	t = t.UTC()
+	amzDate := t.Format(timeFormat)
+	scope := s.scope(t)
+
+	clone := req.Clone(req.Context())
+	signedHeaders, canonicalHeaders := canonicalHeaders(clone)
+
+	query := clone.URL.Query()
+	query.Set("X-Amz-Algorithm", algorithm)
+	query.Set("X-Amz-Credential", creds.AccessKeyID+"/"+scope)
+	query.Set("X-Amz-Date", amzDate)
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires/time.Second), 10))
+	query.Set("X-Amz-SignedHeaders", signedHeaders)
+	if creds.SessionToken != "" {
+		query.Set(headerSecurityToken, creds.SessionToken)
+	}
+
+	payloadHash := HashPayload(nil)
+	if s.isS3() || s.UnsignedPayload {
+		payloadHash = UnsignedPayload
+	}
+	canonical := strings.Join([]string{
+		clone.Method,
+		s.canonicalURI(clone.URL),
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	query.Set("X-Amz-Signature", s.signature(creds.SecretAccessKey, t, stringToSign(amzDate, scope, canonical)))
+	u := *clone.URL
+	u.RawQuery = canonicalQuery(query)
+	return u.String(), nil
+}
+
+// HashPayload returns the hex SHA-256 of the payload
+func HashPayload(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Signer) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func (s *Signer) isS3() bool {
+	return s.Service == "s3"
+}
+
+func (s *Signer) scope(t time.Time) string {
+	return strings.Join([]string{t.Format(shortFormat), s.Region, s.Service, "aws4_request"}, "/")
+}
+
+func (s *Signer) signature(secret string, t time.Time, toSign string) string {
+	key := hmacSHA256([]byte("AWS4"+secret), t.Format(shortFormat))
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, s.Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+func (s *Signer) canonicalURI(u *url.URL) string {
+	p := u.Path
+	if p == "" {
+		p = "/"
+	}
+	encoded := uriEncode(p, false)
+	if !s.DisableURIPathEscaping && !s.isS3() {
+		encoded = uriEncode(encoded, false)
+	}
+	return encoded
+}
+
+func stringToSign(amzDate, scope, canonical string) string {
+	sum := sha256.Sum256([]byte(canonical))
+	return strings.Join([]string{algorithm, amzDate, scope, hex.EncodeToString(sum[:])}, "\n")
+}
+
+// canonicalHeaders returns the signed headers and the canonical headers block of req, host included
+func canonicalHeaders(req *http.Request) (string, string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	values := map[string]string{"host": host}
+	for k, v := range req.Header {
+		name := strings.ToLower(k)
+		if ignoredHeaders[name] || name == "host" {
+			continue
+		}
+		trimmed := make([]string, len(v))
+		for i, value := range v {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for k := range values {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), sb.String()
+}
+
+// canonicalQuery encodes the query params sorted by key and then by value
+func canonicalQuery(q url.Values) string {
+	type pair struct{ key, value string }
+	pairs := make([]pair, 0, len(q))
+	for k, values := range q {
+		for _, v := range values {
+			pairs = append(pairs, pair{uriEncode(k, true), uriEncode(v, true)})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].key != pairs[j].key {
+			return pairs[i].key < pairs[j].key
+		}
+		return pairs[i].value < pairs[j].value
+	})
+	encoded := make([]string, len(pairs))
+	for i, p := range pairs {
+		encoded[i] = p.key + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// uriEncode escapes every byte but the unreserved characters of RFC 3986. Slashes are kept unless encodeSlash
+func uriEncode(s string, encodeSlash bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			sb.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			sb.WriteByte(c)
+		default:
+			fmt.Fprintf(&sb, "%%%02X", c)
+		}
+	}
+	return sb.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
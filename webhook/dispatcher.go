@@ -0,0 +1,399 @@
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+const (
+	defaultInitialInterval = 30 * time.Second
+	defaultMaxInterval     = 4 * time.Hour
+	defaultMaxAttempts     = 10
+	defaultPollInterval    = time.Second
+	defaultWorkers         = 4
+	defaultSignatureHeader = "Webhook-Signature"
+	maxRecordedBody        = 512
+
+	// IDHeader has the event id, receivers use it to drop duplicates
+	IDHeader = "Webhook-Id"
+	// TypeHeader has the event type
+	TypeHeader = "Webhook-Event"
+)
+
+// DeliveryStatus is the state of a delivery
+type DeliveryStatus string
+
+const (
+	StatusPending   DeliveryStatus = "pending"
+	StatusDelivered DeliveryStatus = "delivered"
+	// StatusDead is a delivery that ran out of attempts, it stays in the dead-letter queue until it is replayed
+	StatusDead DeliveryStatus = "dead"
+)
+
+// Attempt is the result of sending a delivery once
+type Attempt struct {
+	At         time.Time     `json:"at"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+	// ResponseBody has the first bytes of the response, to debug the failures
+	ResponseBody string `json:"response_body,omitempty"`
+}
+
+// Delivery is a webhook sent to an endpoint and the record of its attempts
+type Delivery struct {
+	ID        string          `json:"id"`
+	URL       string          `json:"url"`
+	EventID   string          `json:"event_id"`
+	EventType string          `json:"event_type,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	Status    DeliveryStatus  `json:"status"`
+	Attempts  []Attempt       `json:"attempts,omitempty"`
+	// Failures is the number of failed attempts since the delivery was created or replayed
+	Failures      int       `json:"failures"`
+	NextAttemptAt time.Time `json:"next_attempt_at"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (d *Delivery) isDue(now time.Time) bool {
+	return d.Status == StatusPending && !d.NextAttemptAt.After(now)
+}
+
+func (d *Delivery) clone() *Delivery {
+	c := *d
+	c.Payload = append(json.RawMessage(nil), d.Payload...)
+	c.Attempts = append([]Attempt(nil), d.Attempts...)
+	return &c
+}
+
+// Message is a webhook to send
+type Message struct {
+	// URL is the absolute URL of the endpoint
+	URL string
+	// EventID is sent in the Webhook-Id header. Defaults to a random uuid
+	EventID string
+	Type    string
+	// Payload is marshalled to JSON, json.RawMessage and []byte are sent as they are
+	Payload interface{}
+}
+
+// DispatcherConfig configures a Dispatcher
+type DispatcherConfig struct {
+	// Client sends the webhooks, its signers and interceptors are applied too. Required
+	Client rest.Client
+	// Store persists the deliveries. Defaults to a MemoryStore
+	Store Store
+	// Secrets returns the secrets used to sign the deliveries to an endpoint. Several secrets are used while they
+	// are rotated, each one adds a v1 signature. Without secrets the webhooks are not signed
+	Secrets func(d *Delivery) []string
+	// SignatureHeader defaults to Webhook-Signature. Its format is the Stripe one, t=<unix>,v1=<hex hmac>,
+	// so StripeVerifier{Header: "Webhook-Signature"} verifies it
+	SignatureHeader string
+	// InitialInterval is the wait after the first failure, it doubles after each failure up to MaxInterval.
+	// Defaults to 30 seconds and 4 hours: the 9 waits between the default 10 attempts add up to about 4h15m,
+	// the longest one is 2h08m so the cap is not reached
+	InitialInterval time.Duration
+	MaxInterval     time.Duration
+	// MaxAttempts is the number of attempts before the delivery goes to the dead-letter queue. Defaults to 10
+	MaxAttempts int
+	// PollInterval is the time between the checks of due deliveries in Run. Defaults to 1 second
+	PollInterval time.Duration
+	// Workers is the number of deliveries sent at the same time. Defaults to 4
+	Workers int
+	// OnDead is called when a delivery goes to the dead-letter queue. Optional
+	OnDead func(d *Delivery)
+	now    func() time.Time
+}
+
+// Dispatcher delivers webhooks with retries. The deliveries are persisted in the Store before they are sent,
+// so they are not lost if the process stops. A receiver may get a webhook more than once,
+// the Webhook-Id header lets it drop the duplicates.
+// Example:
+//
+//	store, _ := webhook.NewFileStore("/var/lib/app/webhooks")
+//	dispatcher := webhook.NewDispatcher(webhook.DispatcherConfig{
+//		Client:  rest.NewCustomRestClient(rest.Config{TimeoutInMillis: 10000}),
+//		Store:   store,
+//		Secrets: func(d *webhook.Delivery) []string { return secretsByURL[d.URL] },
+//	})
+//	go dispatcher.Run(ctx)
+//
+//	dispatcher.Enqueue(ctx, webhook.Message{URL: endpoint, Type: "payment.created", Payload: payment})
+type Dispatcher struct {
+	cfg DispatcherConfig
+	// mu serializes the passes over the due deliveries
+	mu sync.Mutex
+}
+
+// StaticSecrets signs the deliveries to every endpoint with the same secrets
+func StaticSecrets(secrets ...string) func(d *Delivery) []string {
+	return func(*Delivery) []string { return secrets }
+}
+
+func NewDispatcher(cfg DispatcherConfig) *Dispatcher {
+	if cfg.Client == nil {
+		panic("webhook: Client is required")
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.SignatureHeader == "" {
+		cfg.SignatureHeader = defaultSignatureHeader
+	}
+	if cfg.InitialInterval <= 0 {
+		cfg.InitialInterval = defaultInitialInterval
+	}
+	if cfg.MaxInterval <= 0 {
+		cfg.MaxInterval = defaultMaxInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultMaxAttempts
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
+	return &Dispatcher{cfg: cfg}
+}
+
+// Enqueue persists a delivery of msg, it is sent by Run or DeliverDue
+func (d *Dispatcher) Enqueue(ctx context.Context, msg Message) (*Delivery, error) {
+	payload, err := encodePayload(msg.Payload)
+	if err != nil {
+		return nil, err
+	}
+	now := d.cfg.now()
+	delivery := &Delivery{
+		ID:            uuid.NewString(),
+		URL:           msg.URL,
+		EventID:       msg.EventID,
+		EventType:     msg.Type,
+		Payload:       payload,
+		Status:        StatusPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+	if delivery.EventID == "" {
+		delivery.EventID = uuid.NewString()
+	}
+	if err := d.cfg.Store.Save(ctx, delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// Run sends the due deliveries every PollInterval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverDue(ctx); err != nil {
+			log.Printf("webhook: error delivering: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue sends the deliveries due now and returns how many were attempted. Useful to run the dispatcher from a cron
+func (d *Dispatcher) DeliverDue(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	due, err := d.cfg.Store.Due(ctx, d.cfg.now(), 0)
+	if err != nil {
+		return 0, err
+	}
+	sem := make(chan struct{}, d.cfg.Workers)
+	errs := make(chan error, len(due))
+	var wg sync.WaitGroup
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(delivery *Delivery) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			if err := d.attempt(ctx, delivery); err != nil {
+				errs <- err
+			}
+		}(delivery)
+	}
+	wg.Wait()
+	close(errs)
+	return len(due), <-errs
+}
+
+// Get returns a delivery with its attempts
+func (d *Dispatcher) Get(ctx context.Context, id string) (*Delivery, error) {
+	return d.cfg.Store.Get(ctx, id)
+}
+
+// DeadLetters returns the deliveries that ran out of attempts
+func (d *Dispatcher) DeadLetters(ctx context.Context) ([]*Delivery, error) {
+	return d.cfg.Store.List(ctx, StatusDead)
+}
+
+// Replay moves a dead delivery back to pending, it is sent in the next pass with all its attempts again.
+// The previous attempts are kept in the record
+func (d *Dispatcher) Replay(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delivery, err := d.cfg.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if delivery.Status != StatusDead {
+		return fmt.Errorf("webhook: delivery %s is %s, only dead deliveries can be replayed", id, delivery.Status)
+	}
+	delivery.Status = StatusPending
+	delivery.Failures = 0
+	delivery.NextAttemptAt = d.cfg.now()
+	return d.cfg.Store.Save(ctx, delivery)
+}
+
+// attempt sends the delivery once and saves the result
+func (d *Dispatcher) attempt(ctx context.Context, delivery *Delivery) error {
+	start := d.cfg.now()
+	req := d.cfg.Client.Post("", delivery.Payload).
+		WithCustomBaseURL(delivery.URL).
+		WithContext(ctx).
+		WithHeader("Content-Type", "application/json").
+		WithHeader(IDHeader, delivery.EventID)
+	if delivery.EventType != "" {
+		req.WithHeader(TypeHeader, delivery.EventType)
+	}
+	if d.cfg.Secrets != nil {
+		if secrets := d.cfg.Secrets(delivery); len(secrets) > 0 {
+			req.WithHeader(d.cfg.SignatureHeader, Signature(secrets, start, delivery.Payload))
+		}
+	}
+	res := req.Do()
+	if ctx.Err() != nil {
+		// the attempt was interrupted by the shutdown, it is retried in the next run
+		return nil
+	}
+
+	attempt := Attempt{At: start, Duration: d.cfg.now().Sub(start)}
+	var retryAfter time.Duration
+	switch {
+	case res == nil:
+		attempt.Error = "invalid request"
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		attempt.StatusCode = res.StatusCode
+	default:
+		attempt.StatusCode = res.StatusCode
+		if res.Error != nil {
+			attempt.Error = res.Error.Error()
+		} else {
+			attempt.Error = http.StatusText(res.StatusCode)
+		}
+		attempt.ResponseBody = truncate(string(res.BodyBytes), maxRecordedBody)
+		retryAfter = parseRetryAfter(res.Headers.Get("Retry-After"))
+	}
+	delivery.Attempts = append(delivery.Attempts, attempt)
+
+	switch {
+	case attempt.Error == "":
+		delivery.Status = StatusDelivered
+	case attempt.StatusCode == http.StatusGone:
+		// the receiver asks to stop sending to the endpoint
+		d.kill(delivery)
+	default:
+		delivery.Failures++
+		if delivery.Failures >= d.cfg.MaxAttempts {
+			d.kill(delivery)
+			break
+		}
+		wait := d.backoff(delivery.Failures)
+		if retryAfter > wait {
+			wait = retryAfter
+		}
+		delivery.NextAttemptAt = d.cfg.now().Add(wait)
+	}
+	if err := d.cfg.Store.Save(ctx, delivery); err != nil {
+		return errors.Wrapf(err, "error saving delivery %s", delivery.ID)
+	}
+	return nil
+}
+
+func (d *Dispatcher) kill(delivery *Delivery) {
+	delivery.Status = StatusDead
+	if d.cfg.OnDead != nil {
+		d.cfg.OnDead(delivery.clone())
+	}
+}
+
+// backoff returns the wait after the nth consecutive failure
+func (d *Dispatcher) backoff(failures int) time.Duration {
+	wait := d.cfg.InitialInterval
+	for i := 1; i < failures && wait < d.cfg.MaxInterval; i++ {
+		wait *= 2
+	}
+	if wait > d.cfg.MaxInterval {
+		wait = d.cfg.MaxInterval
+	}
+	return wait
+}
+
+// Signature returns the signature header of payload signed at t with each secret: t=<unix>,v1=<hex>[,v1=<hex>...]
+func Signature(secrets []string, t time.Time, payload []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+	signed := append([]byte(timestamp+"."), payload...)
+	parts := []string{"t=" + timestamp}
+	for _, secret := range secrets {
+		parts = append(parts, "v1="+hex.EncodeToString(computeHMAC(sha256.New, []byte(secret), signed)))
+	}
+	return strings.Join(parts, ",")
+}
+
+// encodePayload returns the payload as the rest client sends it, compact JSON with the HTML characters escaped,
+// so the bytes signed are the bytes sent
+func encodePayload(payload interface{}) (json.RawMessage, error) {
+	if b, ok := payload.([]byte); ok {
+		payload = json.RawMessage(b)
+	}
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "error encoding payload")
+	}
+	return b, nil
+}
+
+func parseRetryAfter(value string) time.Duration {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return 0
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
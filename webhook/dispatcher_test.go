@@ -0,0 +1,209 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newDispatcher(cfg DispatcherConfig, clk *clock) *Dispatcher {
+	cfg.Client = rest.NewCustomRestClient(rest.Config{TimeoutInMillis: 2000})
+	cfg.now = clk.Now
+	return NewDispatcher(cfg)
+}
+
+// statusServer answers each request with the next status, the last one is repeated
+func statusServer(statuses ...int) (*httptest.Server, *int) {
+	var mu sync.Mutex
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		status := statuses[min(calls, len(statuses)-1)]
+		calls++
+		mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte("status body"))
+	}))
+	return srv, &calls
+}
+
+// Test the webhooks of a dispatcher are accepted by the receiver middleware
+func TestDispatcherSignsForReceiver(t *testing.T) {
+	clk := &clock{now: testNow}
+	var received *Event
+	app := newApp(Config{Verifier: StripeVerifier{Secrets: []string{"whsec"}, Header: defaultSignatureHeader, IDHeader: IDHeader}}, func(c *fiber.Ctx) error {
+		received = GetEvent(c)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.RequestURI, r.URL.Path = "", "/webhook"
+		res, err := app.Test(r)
+		assert.Nil(t, err)
+		w.WriteHeader(res.StatusCode)
+	}))
+	defer srv.Close()
+
+	d := newDispatcher(DispatcherConfig{Secrets: StaticSecrets("whsec_old", "whsec")}, clk)
+	delivery, err := d.Enqueue(context.Background(), Message{URL: srv.URL, EventID: "evt_1", Type: "payment.created", Payload: map[string]string{"note": "<b>"}})
+	assert.Nil(t, err)
+
+	n, err := d.DeliverDue(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	if assert.NotNil(t, received) {
+		assert.Equal(t, "evt_1", received.ID)
+		assert.Equal(t, `{"note":"\u003cb\u003e"}`, string(received.Payload))
+	}
+
+	stored, err := d.Get(context.Background(), delivery.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, StatusDelivered, stored.Status)
+	assert.Len(t, stored.Attempts, 1)
+	assert.Equal(t, 204, stored.Attempts[0].StatusCode)
+}
+
+// Test the failed deliveries wait with exponential backoff and Retry-After
+func TestDispatcherBackoff(t *testing.T) {
+	clk := &clock{now: testNow}
+	srv, calls := statusServer(500, 500, 200)
+	defer srv.Close()
+	d := newDispatcher(DispatcherConfig{}, clk)
+	ctx := context.Background()
+	delivery, _ := d.Enqueue(ctx, Message{URL: srv.URL, Payload: []byte(`{"a": 1}`)})
+
+	d.DeliverDue(ctx)
+	stored, _ := d.Get(ctx, delivery.ID)
+	assert.Equal(t, StatusPending, stored.Status)
+	assert.Equal(t, testNow.Add(30*time.Second), stored.NextAttemptAt)
+	assert.Equal(t, "status body", stored.Attempts[0].ResponseBody)
+
+	n, _ := d.DeliverDue(ctx)
+	assert.Equal(t, 0, n)
+
+	clk.Add(30 * time.Second)
+	d.DeliverDue(ctx)
+	stored, _ = d.Get(ctx, delivery.ID)
+	assert.Equal(t, clk.Now().Add(time.Minute), stored.NextAttemptAt)
+
+	clk.Add(time.Minute)
+	d.DeliverDue(ctx)
+	stored, _ = d.Get(ctx, delivery.ID)
+	assert.Equal(t, StatusDelivered, stored.Status)
+	assert.Len(t, stored.Attempts, 3)
+	assert.Equal(t, 3, *calls)
+
+	assert.Equal(t, 4*time.Hour, d.backoff(20))
+	var window time.Duration
+	for failures := 1; failures < defaultMaxAttempts; failures++ {
+		window += d.backoff(failures)
+	}
+	assert.Equal(t, 4*time.Hour+15*time.Minute+30*time.Second, window)
+
+	retry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer retry.Close()
+	delivery, _ = d.Enqueue(ctx, Message{URL: retry.URL, Payload: []byte(`{}`)})
+	d.DeliverDue(ctx)
+	stored, _ = d.Get(ctx, delivery.ID)
+	assert.Equal(t, clk.Now().Add(10*time.Minute), stored.NextAttemptAt)
+}
+
+// Test the deliveries go to the dead-letter queue and can be replayed
+func TestDispatcherDeadLetters(t *testing.T) {
+	clk := &clock{now: testNow}
+	srv, calls := statusServer(500, 500, 200)
+	defer srv.Close()
+	var dead []string
+	d := newDispatcher(DispatcherConfig{MaxAttempts: 2, OnDead: func(d *Delivery) { dead = append(dead, d.ID) }}, clk)
+	ctx := context.Background()
+	delivery, _ := d.Enqueue(ctx, Message{URL: srv.URL, Payload: []byte(`{}`)})
+
+	assert.NotNil(t, d.Replay(ctx, delivery.ID))
+	for i := 0; i < 3; i++ {
+		d.DeliverDue(ctx)
+		clk.Add(time.Hour)
+	}
+	assert.Equal(t, 2, *calls)
+	letters, err := d.DeadLetters(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, []string{delivery.ID}, dead)
+
+	assert.Nil(t, d.Replay(ctx, delivery.ID))
+	d.DeliverDue(ctx)
+	stored, _ := d.Get(ctx, delivery.ID)
+	assert.Equal(t, StatusDelivered, stored.Status)
+	assert.Len(t, stored.Attempts, 3)
+
+	gone, _ := statusServer(http.StatusGone)
+	defer gone.Close()
+	delivery, _ = d.Enqueue(ctx, Message{URL: gone.URL, Payload: []byte(`{}`)})
+	d.DeliverDue(ctx)
+	stored, _ = d.Get(ctx, delivery.ID)
+	assert.Equal(t, StatusDead, stored.Status)
+	assert.Len(t, stored.Attempts, 1)
+
+	assert.Equal(t, ErrDeliveryNotFound, d.Replay(ctx, "missing"))
+}
+
+// Test the pending deliveries of a FileStore survive a restart
+func TestFileStore(t *testing.T) {
+	clk := &clock{now: testNow}
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.Nil(t, err)
+	srv, _ := statusServer(200)
+	defer srv.Close()
+	ctx := context.Background()
+
+	d := newDispatcher(DispatcherConfig{Store: store}, clk)
+	first, _ := d.Enqueue(ctx, Message{URL: srv.URL, Payload: []byte(`{"n":1}`)})
+	clk.Add(time.Second)
+	second, _ := d.Enqueue(ctx, Message{URL: srv.URL, Payload: []byte(`{"n":2}`)})
+
+	store, _ = NewFileStore(dir)
+	due, err := store.Due(ctx, clk.Now(), 0)
+	assert.Nil(t, err)
+	if assert.Len(t, due, 2) {
+		assert.Equal(t, first.ID, due[0].ID)
+		assert.Equal(t, second.ID, due[1].ID)
+		assert.Equal(t, `{"n":2}`, string(due[1].Payload))
+	}
+
+	d = newDispatcher(DispatcherConfig{Store: store}, clk)
+	n, err := d.DeliverDue(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	delivered, _ := store.List(ctx, StatusDelivered)
+	assert.Len(t, delivered, 2)
+
+	_, err = store.Get(ctx, "../missing")
+	assert.Equal(t, ErrDeliveryNotFound, err)
+}
@@ -0,0 +1,151 @@
+// Package webhook verifies the webhooks received from providers, like Stripe or GitHub, and drops the replayed ones.
+// It also delivers webhooks to other services with signatures, retries and a dead-letter queue.
+package webhook
+
+import (
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/problem"
+)
+
+const (
+	defaultTolerance = 5 * time.Minute
+	defaultDedupTTL  = 24 * time.Hour
+	defaultLockTTL   = time.Minute
+	eventLocal       = "webhook_event"
+	// DuplicateHeader is set in the responses to the events already processed
+	DuplicateHeader = "Webhook-Duplicate"
+)
+
+// ErrTimestampOutOfTolerance is returned when the signed timestamp is too old or in the future
+var ErrTimestampOutOfTolerance = errors.New("timestamp outside the tolerance")
+
+// Event is a verified webhook
+type Event struct {
+	// ID is the id of the event given by the provider, empty if the provider has none
+	ID string
+	// Type is the type of the event, when the provider sends it in a header
+	Type string
+	// Timestamp is the signed time of the event, zero if the provider does not sign it
+	Timestamp time.Time
+	// Payload is the verified body
+	Payload []byte
+}
+
+// Decode unmarshalls the payload into v
+func (e *Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+// Config configures the webhook middleware
+type Config struct {
+	Verifier Verifier
+	// Tolerance is the max difference between the signed timestamp and now. Defaults to 5 minutes
+	Tolerance time.Duration
+	// Events remembers the ids of the processed events to acknowledge the duplicates without running the handler.
+	// Optional, without it the duplicates are not detected. The ids are claimed with cache.Claim, so a NewRedisCache
+	// shared by the instances also detects an event delivered to two of them at once
+	Events cache.Spec
+	// DedupTTL is the time the ids are remembered. Defaults to 24 hours
+	DedupTTL time.Duration
+	now      func() time.Time
+}
+
+// EventRecord is what Events remembers of an event id
+type EventRecord struct {
+	// Processed is false while the event is being processed
+	Processed bool
+}
+
+func init() {
+	gob.Register(&EventRecord{})
+}
+
+// New returns a middleware that rejects the webhooks without a valid signature or with an old timestamp with a 401.
+// The verified event is injected in the fiber context, see GetEvent. When Events is set, an event already processed
+// gets a 200 without running the handler, and one being processed gets a 409 so the provider retries it later.
+// The event is only remembered if the handler answers with a 2xx, so failed events can be retried. If Events fails
+// to claim the id, the event is rejected with a 503.
+// Example:
+//
+//	app.Post("/webhooks/stripe", webhook.New(webhook.Config{
+//		Verifier: webhook.StripeVerifier{Secrets: []string{os.Getenv("STRIPE_WEBHOOK_SECRET")}},
+//		Events:   cache.NewMemoryCache("webhooks", 10000, 24*time.Hour, false),
+//	}), func(c *fiber.Ctx) error {
+//		var event stripe.Event
+//		if err := webhook.GetEvent(c).Decode(&event); err != nil {
+//			return err
+//		}
+//		...
+//	})
+func New(cfg Config) fiber.Handler {
+	if cfg.Verifier == nil {
+		panic("webhook: Verifier is required")
+	}
+	if cfg.Tolerance <= 0 {
+		cfg.Tolerance = defaultTolerance
+	}
+	if cfg.DedupTTL <= 0 {
+		cfg.DedupTTL = defaultDedupTTL
+	}
+	if cfg.now == nil {
+		cfg.now = time.Now
+	}
+	// claims the ids when Events has no SaveIfAbsent
+	var mu sync.Mutex
+
+	return func(c *fiber.Ctx) error {
+		// the body buffer is reused by fasthttp after the request, handlers may keep the event
+		body := append([]byte(nil), c.Body()...)
+		event, err := cfg.Verifier.Verify(c, body)
+		if err != nil {
+			return problem.Send(c, problem.New(fiber.StatusUnauthorized, err.Error()))
+		}
+		if !event.Timestamp.IsZero() {
+			skew := cfg.now().Sub(event.Timestamp)
+			if skew > cfg.Tolerance || skew < -cfg.Tolerance {
+				return problem.Send(c, problem.New(fiber.StatusUnauthorized, ErrTimestampOutOfTolerance.Error()))
+			}
+		}
+		c.Locals(eventLocal, event)
+
+		if cfg.Events == nil || event.ID == "" {
+			return c.Next()
+		}
+		key := "webhook:" + event.ID
+		ctx := c.UserContext()
+
+		record, claimed, err := cache.Claim(ctx, cfg.Events, &mu, key, &EventRecord{}, defaultLockTTL)
+		if err != nil {
+			return problem.Send(c, problem.New(fiber.StatusServiceUnavailable, "The event could not be checked."))
+		}
+		if !claimed {
+			if record.Processed {
+				c.Set(DuplicateHeader, "true")
+				return c.SendStatus(fiber.StatusOK)
+			}
+			return problem.Send(c, problem.New(fiber.StatusConflict, "The event is being processed."))
+		}
+
+		err = c.Next()
+		if status := c.Response().StatusCode(); err != nil || status < 200 || status >= 300 {
+			cfg.Events.Delete(ctx, key)
+			return err
+		}
+		cfg.Events.SaveWithTTL(ctx, key, &EventRecord{Processed: true}, cfg.DedupTTL)
+		return nil
+	}
+}
+
+// GetEvent returns the event verified by the middleware, nil if the request did not go through it
+func GetEvent(c *fiber.Ctx) *Event {
+	event, _ := c.Locals(eventLocal).(*Event)
+	return event
+}
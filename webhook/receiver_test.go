@@ -0,0 +1,218 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/cache"
+	"github.com/abraham-corales/go-lib/cache/cachetest"
+)
+
+var testNow = time.Unix(1700000000, 0)
+
+func newApp(cfg Config, handler fiber.Handler) *fiber.App {
+	cfg.now = func() time.Time { return testNow }
+	app := fiber.New()
+	app.Post("/webhook", New(cfg), handler)
+	return app
+}
+
+func send(t *testing.T, app *fiber.App, body string, headers map[string]string) *http.Response {
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := app.Test(req)
+	assert.Nil(t, err)
+	return res
+}
+
+func stripeHeader(secret string, ts int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts, body)
+	return fmt.Sprintf("t=%d,v1=%s,v0=deadbeef", ts, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Test Stripe signatures, timestamps and the verified event
+func TestStripeVerifier(t *testing.T) {
+	body := `{"id":"evt_1","type":"invoice.paid"}`
+	app := newApp(Config{Verifier: StripeVerifier{Secrets: []string{"whsec_old", "whsec_new"}}}, func(c *fiber.Ctx) error {
+		var payload struct{ Type string }
+		event := GetEvent(c)
+		assert.Nil(t, event.Decode(&payload))
+		return c.SendString(event.ID + " " + payload.Type + " " + strconv.FormatInt(event.Timestamp.Unix(), 10))
+	})
+
+	res := send(t, app, body, map[string]string{"Stripe-Signature": stripeHeader("whsec_new", testNow.Unix(), body)})
+	assert.Equal(t, 200, res.StatusCode)
+	b, _ := io.ReadAll(res.Body)
+	assert.Equal(t, "evt_1 invoice.paid 1700000000", string(b))
+
+	cases := map[string]string{
+		"wrong secret":  stripeHeader("whsec_other", testNow.Unix(), body),
+		"tampered body": stripeHeader("whsec_new", testNow.Unix(), `{"id":"evt_2"}`),
+		"too old":       stripeHeader("whsec_new", testNow.Add(-6*time.Minute).Unix(), body),
+		"future":        stripeHeader("whsec_new", testNow.Add(6*time.Minute).Unix(), body),
+		"no v1":         fmt.Sprintf("t=%d", testNow.Unix()),
+		"missing":       "",
+	}
+	for name, header := range cases {
+		res := send(t, app, body, map[string]string{"Stripe-Signature": header})
+		assert.Equal(t, 401, res.StatusCode, name)
+		assert.Equal(t, "application/problem+json", res.Header.Get("Content-Type"), name)
+	}
+}
+
+// Test the example of the GitHub documentation
+func TestGitHubVerifier(t *testing.T) {
+	var kept []*Event
+	app := newApp(Config{Verifier: GitHubVerifier{Secrets: []string{"It's a Secret to Everybody"}}}, func(c *fiber.Ctx) error {
+		kept = append(kept, GetEvent(c))
+		return c.SendString(GetEvent(c).ID + " " + GetEvent(c).Type)
+	})
+	headers := map[string]string{
+		"X-Hub-Signature-256": "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17",
+		"X-GitHub-Delivery":   "72d3162e-cc78-11e3-81ab-4c9367dc0958",
+		"X-GitHub-Event":      "push",
+	}
+
+	res := send(t, app, "Hello, World!", headers)
+	assert.Equal(t, 200, res.StatusCode)
+	b, _ := io.ReadAll(res.Body)
+	assert.Equal(t, "72d3162e-cc78-11e3-81ab-4c9367dc0958 push", string(b))
+
+	// the kept event does not change when the request buffers are reused
+	headers["X-GitHub-Delivery"] = "00000000-0000-0000-0000-000000000000"
+	headers["X-GitHub-Event"] = "ping"
+	assert.Equal(t, 200, send(t, app, "Hello, World!", headers).StatusCode)
+	assert.Equal(t, "72d3162e-cc78-11e3-81ab-4c9367dc0958", kept[0].ID)
+	assert.Equal(t, "push", kept[0].Type)
+
+	assert.Equal(t, 401, send(t, app, "Hello, World?", headers).StatusCode)
+}
+
+// Test a generic HMAC verifier with base64 signatures and the id in the body
+func TestHMACVerifier(t *testing.T) {
+	verifier := HMACVerifier{
+		Secrets:         []string{"secret"},
+		SignatureHeader: "X-Signature",
+		TimestampHeader: "X-Timestamp",
+		IDPath:          "event.id",
+		Encoding:        Base64,
+	}
+	app := newApp(Config{Verifier: verifier}, func(c *fiber.Ctx) error {
+		return c.SendString(GetEvent(c).ID)
+	})
+	body := `{"event":{"id":"e-9"}}`
+	ts := strconv.FormatInt(testNow.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(ts + "." + body))
+
+	res := send(t, app, body, map[string]string{"X-Signature": base64.StdEncoding.EncodeToString(mac.Sum(nil)), "X-Timestamp": ts})
+	assert.Equal(t, 200, res.StatusCode)
+	b, _ := io.ReadAll(res.Body)
+	assert.Equal(t, "e-9", string(b))
+
+	res = send(t, app, body, map[string]string{"X-Signature": base64.StdEncoding.EncodeToString(mac.Sum(nil)), "X-Timestamp": "x"})
+	assert.Equal(t, 401, res.StatusCode)
+}
+
+// Test replayed events are acknowledged without running the handler, unless it failed
+func TestDuplicateEvents(t *testing.T) {
+	redis := cachetest.NewRedisServer(t, "")
+	caches := map[string]cache.Spec{
+		"memory": cache.NewMemoryCache("webhooks", 100, time.Hour, false),
+		"redis":  cache.NewRedisCache("webhooks", time.Hour, cache.RedisConfig{Addr: redis.Addr()}),
+	}
+	for name, events := range caches {
+		t.Run(name, func(t *testing.T) {
+			calls := 0
+			fail := true
+			app := newApp(Config{
+				Verifier: StripeVerifier{Secrets: []string{"whsec"}},
+				Events:   events,
+			}, func(c *fiber.Ctx) error {
+				calls++
+				if fail {
+					return c.SendStatus(fiber.StatusInternalServerError)
+				}
+				return c.SendStatus(fiber.StatusNoContent)
+			})
+			body := `{"id":"evt_1"}`
+			headers := map[string]string{"Stripe-Signature": stripeHeader("whsec", testNow.Unix(), body)}
+
+			assert.Equal(t, 500, send(t, app, body, headers).StatusCode)
+			fail = false
+			assert.Equal(t, 204, send(t, app, body, headers).StatusCode)
+			res := send(t, app, body, headers)
+			assert.Equal(t, 200, res.StatusCode)
+			assert.Equal(t, "true", res.Header.Get(DuplicateHeader))
+			assert.Equal(t, 2, calls)
+		})
+	}
+}
+
+// Test an event delivered at the same time to two instances sharing the redis cache is processed once
+func TestConcurrentEventsAcrossInstances(t *testing.T) {
+	redis := cachetest.NewRedisServer(t, "")
+	newConfig := func() Config {
+		return Config{
+			Verifier: StripeVerifier{Secrets: []string{"whsec"}},
+			Events:   cache.NewRedisCache("webhooks", time.Hour, cache.RedisConfig{Addr: redis.Addr()}),
+		}
+	}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	first := newApp(newConfig(), func(c *fiber.Ctx) error {
+		close(started)
+		<-release
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	second := newApp(newConfig(), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	body := `{"id":"evt_1"}`
+	headers := map[string]string{"Stripe-Signature": stripeHeader("whsec", testNow.Unix(), body)}
+
+	done := make(chan int)
+	go func() {
+		done <- send(t, first, body, headers).StatusCode
+	}()
+	<-started
+
+	assert.Equal(t, 409, send(t, second, body, headers).StatusCode)
+	close(release)
+	assert.Equal(t, 204, <-done)
+	res := send(t, second, body, headers)
+	assert.Equal(t, 200, res.StatusCode)
+	assert.Equal(t, "true", res.Header.Get(DuplicateHeader))
+}
+
+// Test the events are rejected when the cache can not claim their id
+func TestUnavailableEvents(t *testing.T) {
+	calls := 0
+	app := newApp(Config{
+		Verifier: StripeVerifier{Secrets: []string{"whsec"}},
+		Events:   cache.NewRedisCache("webhooks", time.Hour, cache.RedisConfig{Addr: "127.0.0.1:1"}),
+	}, func(c *fiber.Ctx) error {
+		calls++
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	body := `{"id":"evt_1"}`
+	headers := map[string]string{"Stripe-Signature": stripeHeader("whsec", testNow.Unix(), body)}
+	assert.Equal(t, 503, send(t, app, body, headers).StatusCode)
+	assert.Equal(t, 0, calls)
+}
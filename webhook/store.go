@@ -0,0 +1,202 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrDeliveryNotFound is returned by the stores when there is no delivery with the id
+var ErrDeliveryNotFound = errors.New("webhook: delivery not found")
+
+// Store persists the deliveries of a Dispatcher. A store must be used by a single dispatcher,
+// otherwise the due deliveries would be sent by all of them
+type Store interface {
+	// Save inserts or replaces the delivery
+	Save(ctx context.Context, d *Delivery) error
+	Get(ctx context.Context, id string) (*Delivery, error)
+	// Due returns up to limit pending deliveries whose next attempt is not after now, the oldest first
+	Due(ctx context.Context, now time.Time, limit int) ([]*Delivery, error)
+	// List returns the deliveries with the status, the oldest first
+	List(ctx context.Context, status DeliveryStatus) ([]*Delivery, error)
+}
+
+// MemoryStore keeps the deliveries in memory. They are lost when the process stops, use it for tests
+// or when losing pending webhooks is acceptable
+type MemoryStore struct {
+	mu         sync.Mutex
+	deliveries map[string]*Delivery
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{deliveries: make(map[string]*Delivery)}
+}
+
+func (s *MemoryStore) Save(_ context.Context, d *Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries[d.ID] = d.clone()
+	return nil
+}
+
+func (s *MemoryStore) Get(_ context.Context, id string) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.deliveries[id]
+	if !ok {
+		return nil, ErrDeliveryNotFound
+	}
+	return d.clone(), nil
+}
+
+func (s *MemoryStore) Due(_ context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filterDeliveries(s.values(), func(d *Delivery) bool { return d.isDue(now) }, limit), nil
+}
+
+func (s *MemoryStore) List(_ context.Context, status DeliveryStatus) ([]*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return filterDeliveries(s.values(), func(d *Delivery) bool { return d.Status == status }, 0), nil
+}
+
+func (s *MemoryStore) values() []*Delivery {
+	out := make([]*Delivery, 0, len(s.deliveries))
+	for _, d := range s.deliveries {
+		out = append(out, d.clone())
+	}
+	return out
+}
+
+// FileStore keeps each delivery in a JSON file of a directory, so the pending deliveries survive restarts.
+// Files are written to a temporary file and renamed, a crash never leaves a partial delivery.
+// Due reads the whole directory, it is meant for thousands of deliveries, not millions
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore returns a store in dir, it is created if it does not exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) Save(_ context.Context, d *Delivery) error {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp, err := os.CreateTemp(s.dir, ".delivery-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path(d.ID))
+}
+
+func (s *FileStore) Get(_ context.Context, id string) (*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := readDelivery(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrDeliveryNotFound
+	}
+	return d, err
+}
+
+func (s *FileStore) Due(_ context.Context, now time.Time, limit int) ([]*Delivery, error) {
+	all, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	return filterDeliveries(all, func(d *Delivery) bool { return d.isDue(now) }, limit), nil
+}
+
+func (s *FileStore) List(_ context.Context, status DeliveryStatus) ([]*Delivery, error) {
+	all, err := s.readAll()
+	if err != nil {
+		return nil, err
+	}
+	return filterDeliveries(all, func(d *Delivery) bool { return d.Status == status }, 0), nil
+}
+
+func (s *FileStore) path(id string) string {
+	// ids are generated by the dispatcher, the base name keeps a crafted id inside the directory
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+func (s *FileStore) readAll() ([]*Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Delivery, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		d, err := readDelivery(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, d)
+	}
+	return out, nil
+}
+
+func readDelivery(path string) (*Delivery, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &Delivery{}
+	if err := json.Unmarshal(b, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// filterDeliveries returns the deliveries matching keep sorted by creation, limit <= 0 returns all
+func filterDeliveries(all []*Delivery, keep func(d *Delivery) bool, limit int) []*Delivery {
+	out := make([]*Delivery, 0)
+	for _, d := range all {
+		if keep(d) {
+			out = append(out, d)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].ID < out[j].ID
+		}
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
@@ -0,0 +1,216 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/tidwall/gjson"
+)
+
+var (
+	// ErrMissingSignature is returned when the request has no signature
+	ErrMissingSignature = errors.New("missing signature")
+	// ErrInvalidSignature is returned when no secret produces the signature of the request
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInvalidTimestamp is returned when the signed timestamp can't be parsed
+	ErrInvalidTimestamp = errors.New("invalid timestamp")
+)
+
+// Verifier checks the signature of a webhook request and returns the verified event.
+// The handlers may keep the event, so the strings taken from the headers must be copied with strings.Clone:
+// fasthttp reuses their buffers
+type Verifier interface {
+	Verify(c *fiber.Ctx, body []byte) (*Event, error)
+}
+
+// StripeVerifier verifies Stripe style signatures: a header like t=1492774577,v1=5257a869...
+// with the hex HMAC-SHA256 of "timestamp.body". The event id is the id field of the body, or the IDHeader.
+// Several secrets can be set while they are rotated, and several v1 signatures are accepted
+type StripeVerifier struct {
+	Secrets []string
+	// Header defaults to Stripe-Signature
+	Header string
+	// Scheme is the key of the signatures in the header. Defaults to v1
+	Scheme string
+	// IDHeader has the event id. Optional, set it to webhook.IDHeader to verify the webhooks of a Dispatcher
+	IDHeader string
+}
+
+func (v StripeVerifier) Verify(c *fiber.Ctx, body []byte) (*Event, error) {
+	header, scheme := v.Header, v.Scheme
+	if header == "" {
+		header = "Stripe-Signature"
+	}
+	if scheme == "" {
+		scheme = "v1"
+	}
+	value := c.Get(header)
+	if value == "" {
+		return nil, ErrMissingSignature
+	}
+
+	var timestamp string
+	var signatures [][]byte
+	for _, part := range strings.Split(value, ",") {
+		k, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			timestamp = val
+		case scheme:
+			if sig, err := hex.DecodeString(val); err == nil {
+				signatures = append(signatures, sig)
+			}
+		}
+	}
+	if timestamp == "" || len(signatures) == 0 {
+		return nil, ErrMissingSignature
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+
+	signed := append([]byte(timestamp+"."), body...)
+	for _, secret := range v.Secrets {
+		expected := computeHMAC(sha256.New, []byte(secret), signed)
+		for _, sig := range signatures {
+			if hmac.Equal(expected, sig) {
+				event := &Event{ID: gjson.GetBytes(body, "id").String(), Timestamp: time.Unix(unix, 0), Payload: body}
+				if v.IDHeader != "" {
+					event.ID = strings.Clone(c.Get(v.IDHeader))
+				}
+				return event, nil
+			}
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+// GitHubVerifier verifies GitHub style signatures: X-Hub-Signature-256: sha256=<hex HMAC-SHA256 of the body>.
+// The event id is the X-GitHub-Delivery header. GitHub does not sign a timestamp, so only the event ids protect
+// against replays
+type GitHubVerifier struct {
+	Secrets []string
+}
+
+func (v GitHubVerifier) Verify(c *fiber.Ctx, body []byte) (*Event, error) {
+	value := c.Get("X-Hub-Signature-256")
+	hexSig, ok := strings.CutPrefix(value, "sha256=")
+	if !ok {
+		return nil, ErrMissingSignature
+	}
+	sig, err := hex.DecodeString(hexSig)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	for _, secret := range v.Secrets {
+		if hmac.Equal(computeHMAC(sha256.New, []byte(secret), body), sig) {
+			id, eventType := strings.Clone(c.Get("X-GitHub-Delivery")), strings.Clone(c.Get("X-GitHub-Event"))
+			return &Event{ID: id, Type: eventType, Payload: body}, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+// HMACVerifier verifies the HMAC of the body, or of "timestamp.body" when TimestampHeader is set,
+// for providers with their own header names
+// Example:
+//
+//	webhook.HMACVerifier{
+//		Secrets:         []string{secret},
+//		SignatureHeader: "X-Signature",
+//		TimestampHeader: "X-Timestamp",
+//		IDHeader:        "X-Event-Id",
+//		Encoding:        webhook.Base64,
+//	}
+type HMACVerifier struct {
+	Secrets         []string
+	SignatureHeader string
+	// Prefix is removed from the signature, like sha256=
+	Prefix string
+	// TimestampHeader has the unix seconds signed with the body. Optional
+	TimestampHeader string
+	// IDHeader has the event id. Optional, when it is empty IDPath is used
+	IDHeader string
+	// IDPath is the gjson path of the event id in the body. Optional
+	IDPath string
+	// Encoding of the signature. Defaults to Hex
+	Encoding Encoding
+	// Hash defaults to sha256.New
+	Hash func() hash.Hash
+	// SignedPayload builds the signed bytes. Defaults to the body, or "timestamp.body" with a TimestampHeader
+	SignedPayload func(timestamp string, body []byte) []byte
+}
+
+// Encoding is how the signature is encoded in the header
+type Encoding int
+
+const (
+	Hex Encoding = iota
+	Base64
+)
+
+func (v HMACVerifier) Verify(c *fiber.Ctx, body []byte) (*Event, error) {
+	value, _ := strings.CutPrefix(c.Get(v.SignatureHeader), v.Prefix)
+	if value == "" {
+		return nil, ErrMissingSignature
+	}
+	var sig []byte
+	var err error
+	if v.Encoding == Base64 {
+		sig, err = base64.StdEncoding.DecodeString(value)
+	} else {
+		sig, err = hex.DecodeString(value)
+	}
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	event := &Event{Payload: body}
+	var timestamp string
+	if v.TimestampHeader != "" {
+		timestamp = c.Get(v.TimestampHeader)
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return nil, ErrInvalidTimestamp
+		}
+		event.Timestamp = time.Unix(unix, 0)
+	}
+
+	signed := body
+	switch {
+	case v.SignedPayload != nil:
+		signed = v.SignedPayload(timestamp, body)
+	case timestamp != "":
+		signed = append([]byte(timestamp+"."), body...)
+	}
+	newHash := v.Hash
+	if newHash == nil {
+		newHash = sha256.New
+	}
+	for _, secret := range v.Secrets {
+		if hmac.Equal(computeHMAC(newHash, []byte(secret), signed), sig) {
+			if v.IDHeader != "" {
+				event.ID = strings.Clone(c.Get(v.IDHeader))
+			} else if v.IDPath != "" {
+				event.ID = gjson.GetBytes(body, v.IDPath).String()
+			}
+			return event, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+func computeHMAC(newHash func() hash.Hash, secret, data []byte) []byte {
+	mac := hmac.New(newHash, secret)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
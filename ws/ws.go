@@ -0,0 +1,459 @@
+// Package ws is a WebSocket client for long-lived bidirectional channels with the upstreams of a RestClient.
+// It reuses the base URL, default headers, TLS settings and token source of the RestClient, reconnects with
+// backoff, keeps the connection alive with ping/pong and applies backpressure with bounded queues.
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+const (
+	defaultMinBackoff     = 500 * time.Millisecond
+	defaultMaxBackoff     = 30 * time.Second
+	defaultPingInterval   = 30 * time.Second
+	defaultPongTimeout    = 10 * time.Second
+	defaultQueueSize      = 64
+	defaultHandshakeLimit = 10 * time.Second
+)
+
+var (
+	// ErrClosed is returned when the client is closed or gave up reconnecting
+	ErrClosed = errors.New("ws: client closed")
+	// ErrQueueFull is returned by TrySend when the send queue is full
+	ErrQueueFull = errors.New("ws: send queue full")
+)
+
+// Codec encodes and decodes the messages
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	// Binary returns true if the messages are sent as binary frames instead of text frames
+	Binary() bool
+}
+
+// JSONCodec sends the messages as JSON text frames. It is the default codec
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (JSONCodec) Binary() bool                               { return false }
+
+// Options configures a Client. The zero value uses the defaults
+type Options struct {
+	// Header has extra headers for the handshake, added to the DefaultHeaders of the RestClient
+	Header http.Header
+	// MinBackoff and MaxBackoff bound the wait between reconnections, it doubles after each failure.
+	// Defaults to 500ms and 30s
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// MaxReconnects is the number of consecutive failed reconnections before giving up. 0 retries forever
+	MaxReconnects int
+	// PingInterval is the time between pings. The connection is dropped if nothing, not even a pong,
+	// is received for PingInterval + PongTimeout. Defaults to 30s and 10s
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	// SendQueueSize and ReceiveQueueSize are the number of messages buffered in each direction. Defaults to 64.
+	// When the receive queue is full the client stops reading, so the upstream is slowed down by TCP
+	SendQueueSize    int
+	ReceiveQueueSize int
+	// Codec encodes and decodes the messages. Defaults to JSONCodec
+	Codec Codec
+	// OnConnect is called after each connection, before the queued messages are sent.
+	// Use send to subscribe or authenticate again after a reconnection
+	OnConnect func(ctx context.Context, send func(v interface{}) error) error
+	// OnDisconnect is called when an established connection is lost
+	OnDisconnect func(err error)
+}
+
+// Message is a received message
+type Message struct {
+	Binary bool
+	Data   []byte
+	codec  Codec
+}
+
+// Decode decodes the message into v with the codec of the client
+func (m Message) Decode(v interface{}) error {
+	return m.codec.Unmarshal(m.Data, v)
+}
+
+// Client is a WebSocket connection that reconnects automatically.
+// Messages are sent at most once, those being written when the connection is lost are not sent again
+type Client struct {
+	url      string
+	rest     *rest.RestClient
+	opts     Options
+	dialer   *websocket.Dialer
+	send     chan []byte
+	incoming chan Message
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu  sync.Mutex
+	err error
+}
+
+// Dial connects to path, relative to the base URL of client, and keeps the connection open until Close.
+// The http and https schemes of the base URL are changed to ws and wss.
+// Example:
+//
+//	conn, err := ws.Dial(ctx, client, "/v1/stream", ws.Options{
+//		OnConnect: func(ctx context.Context, send func(v interface{}) error) error {
+//			return send(Subscribe{Channel: "prices"})
+//		},
+//	})
+//	defer conn.Close()
+//
+//	for {
+//		price, err := ws.Receive[Price](ctx, conn)
+//		...
+//	}
+func Dial(ctx context.Context, client *rest.RestClient, path string, opts Options) (*Client, error) {
+	opts = withDefaults(opts)
+	c := &Client{
+		url:      wsURL(baseURL(client.Config)) + path,
+		rest:     client,
+		opts:     opts,
+		dialer:   newDialer(client.Config),
+		send:     make(chan []byte, opts.SendQueueSize),
+		incoming: make(chan Message, opts.ReceiveQueueSize),
+		done:     make(chan struct{}),
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	conn, err := c.connect(ctx)
+	if err != nil {
+		c.cancel()
+		return nil, err
+	}
+	go c.run(conn)
+	return c, nil
+}
+
+// Send queues v to be sent. It blocks while the queue is full, until ctx is done
+func (c *Client) Send(ctx context.Context, v interface{}) error {
+	data, err := c.opts.Codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	select {
+	case c.send <- data:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.done:
+		return c.Err()
+	}
+}
+
+// TrySend queues v to be sent, ErrQueueFull is returned if the queue is full
+func (c *Client) TrySend(v interface{}) error {
+	data, err := c.opts.Codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-c.done:
+		return c.Err()
+	default:
+	}
+	select {
+	case c.send <- data:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Receive returns the next message. It blocks until a message arrives, ctx is done or the client is closed
+func (c *Client) Receive(ctx context.Context) (Message, error) {
+	select {
+	case msg, ok := <-c.incoming:
+		if !ok {
+			return Message{}, c.Err()
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	}
+}
+
+// Receive returns the next message of c decoded as a T
+func Receive[T any](ctx context.Context, c *Client) (T, error) {
+	var v T
+	msg, err := c.Receive(ctx)
+	if err != nil {
+		return v, err
+	}
+	err = msg.Decode(&v)
+	return v, err
+}
+
+// Close sends a close frame and closes the connection. Queued messages not sent yet are discarded
+func (c *Client) Close() error {
+	c.setErr(ErrClosed)
+	c.cancel()
+	<-c.done
+	return nil
+}
+
+// Err returns why the client stopped, nil while it is running
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *Client) setErr(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// run serves the connection and reconnects when it is lost, until the client is closed
+func (c *Client) run(conn *websocket.Conn) {
+	defer close(c.done)
+	defer close(c.incoming)
+	for {
+		err := c.serve(conn)
+		if c.ctx.Err() != nil {
+			return
+		}
+		if c.opts.OnDisconnect != nil {
+			c.opts.OnDisconnect(err)
+		}
+		if conn = c.reconnect(); conn == nil {
+			return
+		}
+	}
+}
+
+// reconnect dials with backoff, nil is returned if the client is closed or MaxReconnects is reached
+func (c *Client) reconnect() *websocket.Conn {
+	backoff := c.opts.MinBackoff
+	for attempt := 1; ; attempt++ {
+		// equal jitter, so the clients of a restarted upstream do not reconnect at the same time
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		select {
+		case <-time.After(wait):
+		case <-c.ctx.Done():
+			return nil
+		}
+		conn, err := c.connect(c.ctx)
+		if err == nil {
+			return conn
+		}
+		if c.opts.MaxReconnects > 0 && attempt >= c.opts.MaxReconnects {
+			c.setErr(err)
+			return nil
+		}
+		if backoff *= 2; backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
+
+// connect dials the url with the headers and the token of the RestClient and runs OnConnect
+func (c *Client) connect(ctx context.Context) (*websocket.Conn, error) {
+	header := http.Header{}
+	for k, v := range c.rest.Config.DefaultHeaders {
+		header[k] = v
+	}
+	for k, v := range c.opts.Header {
+		header[k] = v
+	}
+	var token string
+	if ts := c.rest.TokenSource; ts != nil && header.Get("Authorization") == "" {
+		var err error
+		if token, err = ts.Token(ctx); err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+
+	conn, res, err := c.dialer.DialContext(ctx, c.url, header)
+	if res != nil && res.Body != nil {
+		res.Body.Close()
+	}
+	if err != nil {
+		if res != nil && res.StatusCode == http.StatusUnauthorized && token != "" {
+			if its, ok := c.rest.TokenSource.(rest.InvalidatingTokenSource); ok {
+				its.Invalidate(token)
+			}
+		}
+		return nil, err
+	}
+
+	if c.opts.OnConnect != nil {
+		send := func(v interface{}) error {
+			data, err := c.opts.Codec.Marshal(v)
+			if err != nil {
+				return err
+			}
+			conn.SetWriteDeadline(time.Now().Add(c.opts.PongTimeout))
+			return conn.WriteMessage(c.messageType(), data)
+		}
+		if err := c.opts.OnConnect(ctx, send); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// serve reads and writes conn until it fails or the client is closed
+func (c *Client) serve(conn *websocket.Conn) error {
+	stop := make(chan struct{})
+	errc := make(chan error, 2)
+	go func() { errc <- c.readLoop(conn, stop) }()
+	go func() { errc <- c.writeLoop(conn, stop) }()
+
+	var err error
+	select {
+	case err = <-errc:
+	case <-c.ctx.Done():
+		err = c.ctx.Err()
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+			time.Now().Add(time.Second))
+	}
+	close(stop)
+	conn.Close()
+	// wait the other loop, the reader unblocks because the connection is closed
+	<-errc
+	return err
+}
+
+func (c *Client) readLoop(conn *websocket.Conn, stop <-chan struct{}) error {
+	timeout := c.opts.PingInterval + c.opts.PongTimeout
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(timeout))
+	})
+	for {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		select {
+		case c.incoming <- Message{Binary: mt == websocket.BinaryMessage, Data: data, codec: c.opts.Codec}:
+		case <-stop:
+			return nil
+		}
+	}
+}
+
+func (c *Client) writeLoop(conn *websocket.Conn, stop <-chan struct{}) error {
+	ticker := time.NewTicker(c.opts.PingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case data := <-c.send:
+			conn.SetWriteDeadline(time.Now().Add(c.opts.PongTimeout))
+			if err := conn.WriteMessage(c.messageType(), data); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.opts.PongTimeout)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (c *Client) messageType() int {
+	if c.opts.Codec.Binary() {
+		return websocket.BinaryMessage
+	}
+	return websocket.TextMessage
+}
+
+func withDefaults(opts Options) Options {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.PingInterval <= 0 {
+		opts.PingInterval = defaultPingInterval
+	}
+	if opts.PongTimeout <= 0 {
+		opts.PongTimeout = defaultPongTimeout
+	}
+	if opts.SendQueueSize <= 0 {
+		opts.SendQueueSize = defaultQueueSize
+	}
+	if opts.ReceiveQueueSize <= 0 {
+		opts.ReceiveQueueSize = defaultQueueSize
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{}
+	}
+	return opts
+}
+
+func baseURL(cfg rest.Config) string {
+	if cfg.BaseURL == "" && len(cfg.BaseURLs) > 0 {
+		return cfg.BaseURLs[0]
+	}
+	return cfg.BaseURL
+}
+
+// wsURL changes the http schemes to the websocket ones
+func wsURL(u string) string {
+	switch {
+	case strings.HasPrefix(u, "https://"):
+		return "wss://" + strings.TrimPrefix(u, "https://")
+	case strings.HasPrefix(u, "http://"):
+		return "ws://" + strings.TrimPrefix(u, "http://")
+	}
+	return u
+}
+
+// newDialer uses the TLS settings and the proxy of the transport of the RestClient
+func newDialer(cfg rest.Config) *websocket.Dialer {
+	transport, ok := cfg.Transport.(*http.Transport)
+	if !ok {
+		transport, _ = http.DefaultTransport.(*http.Transport)
+	}
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: defaultHandshakeLimit,
+	}
+	if cfg.TimeoutInMillis > 0 {
+		dialer.HandshakeTimeout = time.Duration(cfg.TimeoutInMillis) * time.Millisecond
+	}
+	if transport != nil {
+		if transport.TLSClientConfig != nil {
+			dialer.TLSClientConfig = transport.TLSClientConfig.Clone()
+		}
+		if transport.Proxy != nil {
+			dialer.Proxy = transport.Proxy
+		}
+	}
+	return dialer
+}
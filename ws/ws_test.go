@@ -0,0 +1,215 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/abraham-corales/go-lib/rest"
+)
+
+type event struct {
+	Type  string `json:"type"`
+	Value int    `json:"value"`
+}
+
+type staticToken string
+
+func (t staticToken) Token(_ context.Context) (string, error) { return string(t), nil }
+
+var upgrader = websocket.Upgrader{}
+
+// newServer starts a server that runs handle for each connection, the number of the connection starts at 1
+func newServer(t *testing.T, handle func(n int, conn *websocket.Conn, r *http.Request)) *rest.RestClient {
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handle(int(atomic.AddInt32(&count, 1)), conn, r)
+	}))
+	t.Cleanup(server.Close)
+	return rest.NewCustomRestClient(rest.Config{
+		BaseURL:        server.URL,
+		DefaultHeaders: http.Header{"X-Api-Version": {"2"}},
+	})
+}
+
+func echo(conn *websocket.Conn) {
+	for {
+		mt, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if conn.WriteMessage(mt, data) != nil {
+			return
+		}
+	}
+}
+
+// Test typed messages and the headers and token of the RestClient
+func TestSendAndReceive(t *testing.T) {
+	handshakes := make(chan http.Header, 1)
+	client := newServer(t, func(n int, conn *websocket.Conn, r *http.Request) {
+		handshakes <- r.Header.Clone()
+		echo(conn)
+	})
+	client.WithTokenSource(staticToken("token-1"))
+
+	conn, err := Dial(context.Background(), client, "/stream", Options{Header: http.Header{"X-Trace": {"t"}}})
+	assert.Nil(t, err)
+	defer conn.Close()
+	handshake := <-handshakes
+	assert.Equal(t, "Bearer token-1", handshake.Get("Authorization"))
+	assert.Equal(t, "2", handshake.Get("X-Api-Version"))
+	assert.Equal(t, "t", handshake.Get("X-Trace"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 1; i <= 3; i++ {
+		assert.Nil(t, conn.Send(ctx, event{Type: "price", Value: i}))
+	}
+	for i := 1; i <= 3; i++ {
+		e, err := Receive[event](ctx, conn)
+		assert.Nil(t, err)
+		assert.Equal(t, event{Type: "price", Value: i}, e)
+	}
+
+	assert.Nil(t, conn.Close())
+	assert.Equal(t, ErrClosed, conn.Send(ctx, event{}))
+	_, err = conn.Receive(ctx)
+	assert.Equal(t, ErrClosed, err)
+}
+
+// Test the client reconnects when the connection is dropped and runs OnConnect again
+func TestReconnect(t *testing.T) {
+	client := newServer(t, func(n int, conn *websocket.Conn, r *http.Request) {
+		var sub event
+		if conn.ReadJSON(&sub) != nil {
+			return
+		}
+		conn.WriteJSON(event{Type: "subscribed", Value: n})
+		if n == 1 {
+			return
+		}
+		echo(conn)
+	})
+
+	var disconnects int32
+	conn, err := Dial(context.Background(), client, "/", Options{
+		MinBackoff: 10 * time.Millisecond,
+		OnConnect: func(ctx context.Context, send func(v interface{}) error) error {
+			return send(event{Type: "subscribe"})
+		},
+		OnDisconnect: func(err error) { atomic.AddInt32(&disconnects, 1) },
+	})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	first, err := Receive[event](ctx, conn)
+	assert.Nil(t, err)
+	assert.Equal(t, event{Type: "subscribed", Value: 1}, first)
+	second, err := Receive[event](ctx, conn)
+	assert.Nil(t, err)
+	assert.Equal(t, event{Type: "subscribed", Value: 2}, second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&disconnects))
+
+	assert.Nil(t, conn.Send(ctx, event{Type: "after", Value: 7}))
+	echoed, err := Receive[event](ctx, conn)
+	assert.Nil(t, err)
+	assert.Equal(t, event{Type: "after", Value: 7}, echoed)
+}
+
+// Test a connection that does not answer the pings is dropped
+func TestPingTimeout(t *testing.T) {
+	stop := make(chan struct{})
+	client := newServer(t, func(n int, conn *websocket.Conn, r *http.Request) {
+		if n == 1 {
+			// never reads, so the pings are not answered
+			<-stop
+			return
+		}
+		conn.WriteJSON(event{Type: "hello", Value: n})
+		echo(conn)
+	})
+	defer close(stop)
+
+	conn, err := Dial(context.Background(), client, "/", Options{
+		MinBackoff:   10 * time.Millisecond,
+		PingInterval: 50 * time.Millisecond,
+		PongTimeout:  50 * time.Millisecond,
+	})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 900*time.Millisecond)
+	defer cancel()
+	hello, err := Receive[event](ctx, conn)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, hello.Value)
+}
+
+// Test the send queue is bounded when the upstream does not read
+func TestBackpressure(t *testing.T) {
+	stop := make(chan struct{})
+	client := newServer(t, func(n int, conn *websocket.Conn, r *http.Request) {
+		<-stop
+	})
+	defer close(stop)
+
+	conn, err := Dial(context.Background(), client, "/", Options{SendQueueSize: 2})
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// the writer blocks once the socket buffers are full, then the queue fills up
+	payload := make([]byte, 1<<20)
+	err = nil
+	for i := 0; i < 256 && err == nil; i++ {
+		err = conn.TrySend(payload)
+		if err == nil {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	assert.Equal(t, ErrQueueFull, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(conn.Send(ctx, payload), context.DeadlineExceeded))
+}
+
+// Test Dial fails when the upstream is down and the client gives up after MaxReconnects
+func TestMaxReconnects(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conn.Close()
+		go server.Close()
+	}))
+	client := rest.NewCustomRestClient(rest.Config{BaseURL: server.URL})
+
+	conn, err := Dial(context.Background(), client, "/", Options{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond, MaxReconnects: 2})
+	assert.Nil(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = conn.Receive(ctx)
+	assert.NotNil(t, err)
+	assert.NotEqual(t, context.DeadlineExceeded, err)
+	assert.Equal(t, err, conn.Err())
+
+	_, err = Dial(context.Background(), client, "/", Options{})
+	assert.NotNil(t, err)
+}